@@ -7,7 +7,6 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/nerten/albumpicker/pkg/config"
-	"github.com/nerten/albumpicker/pkg/processor"
 )
 
 // Copy command
@@ -20,26 +19,51 @@ var copyCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(copyCmd)
+	// local flags
+	copyCmd.Flags().Bool("rescan", false, "ignore the library index and rescan the album directory")
+	addDryRunFlags(copyCmd)
+	addVerifyFlag(copyCmd)
 }
 
 // runCopyCommand executes the copy command
-func runCopyCommand(_ *cobra.Command, args []string) error {
+func runCopyCommand(cmd *cobra.Command, args []string) error {
+	result := newProcessResult()
+	err := copyAlbums(cmd, args[0], &result)
+	return writeResult(&result, err)
+}
+
+// copyAlbums copies albums found in the path and records them in the result
+func copyAlbums(cmd *cobra.Command, path string, result *processResult) error {
 	// load configuration
 	conf, err := config.LoadConfig()
 	if err != nil {
 		return err
 	}
+	applyVerifyFlag(cmd, conf)
+	if !isDryRun(cmd) {
+		if err := conf.CreateDestination(); err != nil {
+			return err
+		}
+	}
 
-	path := args[0]
 	// ensure album path is absolute
 	if !filepath.IsAbs(path) {
 		path = filepath.Join(conf.Source, path)
 	}
 
-	albums, err := processor.FindAllAlbums(path)
+	rescan, _ := cmd.Flags().GetBool("rescan")
+	ctx := commandContext(cmd)
+	albums, err := scanLibrary(ctx, conf, path, rescan)
 	if err != nil {
 		return fmt.Errorf("error scanning %s directory: %s", path, err)
 	}
+	result.setSelected(albums, conf)
+	if isDryRun(cmd) {
+		result.DryRun = true
+		result.Plan, err = showPlan(cmd, conf, albums, nil)
+		return err
+	}
+
 	// process the album
-	return processor.ProcessAlbums(albums, conf)
+	return processAlbums(ctx, albums, conf, result)
 }
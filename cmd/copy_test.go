@@ -68,6 +68,8 @@ func TestRunCopyCommand(t *testing.T) {
 			setup: func() {
 				viper.Set("source", sourceDir)
 				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
 				viper.Set("output_cover_filename", "album.jpg")
 				viper.Set("cover_filenames", []string{"album.jpg", "album.png", "cover.jpg", "cover.png"})
 				viper.Set("cover_height", 240)
@@ -80,6 +82,8 @@ func TestRunCopyCommand(t *testing.T) {
 			setup: func() {
 				viper.Set("source", "/nonexistent")
 				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
 			},
 			wantErr: true,
 		},
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/manifest"
+	"github.com/nerten/albumpicker/pkg/processor"
+)
+
+// managedAlbums returns albums in the destination directory recorded in the manifest,
+// sorted by the copy time, the oldest first
+func managedAlbums(conf *config.Config) ([]string, error) {
+	m, err := manifest.Load(conf.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	var relPaths []string
+	for relPath := range m.Albums {
+		if _, err := os.Stat(filepath.Join(conf.Destination, filepath.FromSlash(relPath))); err == nil {
+			relPaths = append(relPaths, relPath)
+		}
+	}
+	sort.Slice(relPaths, func(i, j int) bool {
+		a, b := m.Albums[relPaths[i]], m.Albums[relPaths[j]]
+		if !a.CopiedAt.Equal(b.CopiedAt) {
+			return a.CopiedAt.Before(b.CopiedAt)
+		}
+		return relPaths[i] < relPaths[j]
+	})
+
+	destAlbums := make([]string, 0, len(relPaths))
+	for _, relPath := range relPaths {
+		destAlbums = append(destAlbums, filepath.Join(conf.Destination, filepath.FromSlash(relPath)))
+	}
+	return destAlbums, nil
+}
+
+// albumsOnDevice returns albums in the destination directory, albums copied by albumpicker are found
+// by the manifest and the incomplete markers, so transcoded albums the scan doesn't recognise are included
+func albumsOnDevice(ctx context.Context, conf *config.Config) ([]string, error) {
+	found, err := processor.FindAllAlbums(ctx, conf.Destination, conf.AudioExtensions)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning destination directory: %s", err)
+	}
+	managed, err := managedAlbums(conf)
+	if err != nil {
+		return nil, err
+	}
+	incomplete, err := manifest.FindIncomplete(conf.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning destination directory: %s", err)
+	}
+	return slices.Concat(found, managed, incomplete), nil
+}
+
+// excludeAlbumsOnDevice removes albums which are already in the destination directory
+func excludeAlbumsOnDevice(albums []library.Album, destAlbums []string, conf *config.Config) []library.Album {
+	onDevice := make(map[string]bool, len(destAlbums))
+	for _, destAlbumPath := range destAlbums {
+		onDevice[filepath.Clean(destAlbumPath)] = true
+	}
+
+	var candidates []library.Album
+	for _, album := range albums {
+		if destAlbumPath, err := processor.DestinationPath(album.Path, conf); err == nil && !onDevice[destAlbumPath] {
+			candidates = append(candidates, album)
+		}
+	}
+	return candidates
+}
+
+// removeAlbums removes albums from the destination directory and its manifest
+func removeAlbums(destAlbums []string, conf *config.Config) error {
+	m, err := manifest.Load(conf.Destination)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("%s, creating a new manifest", err), "err", err)
+		m = manifest.New(conf.Destination)
+	}
+
+	for _, destAlbumPath := range destAlbums {
+		slog.Info(fmt.Sprintf("Removing album: %s", destAlbumPath), "path", destAlbumPath)
+		if err := processor.RemoveAlbum(destAlbumPath, conf.Destination); err != nil {
+			return fmt.Errorf("failed to remove album: %s", err)
+		}
+		if relPath, err := filepath.Rel(conf.Destination, destAlbumPath); err == nil {
+			m.Remove(relPath)
+		}
+	}
+
+	if err := m.Save(); err != nil {
+		slog.Warn(fmt.Sprintf("Could not save manifest: %s", err), "err", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/manifest"
+)
+
+func TestManagedAlbums(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_managed_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	conf := &config.Config{Destination: tmpDir}
+	now := time.Now()
+
+	// "unknown" is created by the user and "removed" was removed by the user
+	for _, name := range []string{"new", filepath.Join("artist", "old"), "unknown", "oldest"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := manifest.New(tmpDir)
+	m.Add("new", &manifest.Album{CopiedAt: now.Add(-time.Hour)})
+	m.Add(filepath.Join("artist", "old"), &manifest.Album{CopiedAt: now.Add(-24 * time.Hour)})
+	m.Add("oldest", &manifest.Album{CopiedAt: now.Add(-72 * time.Hour)})
+	m.Add("removed", &manifest.Album{CopiedAt: now.Add(-96 * time.Hour)})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := managedAlbums(conf)
+	if err != nil {
+		t.Fatalf("managedAlbums() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(tmpDir, "oldest"),
+		filepath.Join(tmpDir, "artist", "old"),
+		filepath.Join(tmpDir, "new"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("managedAlbums() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nerten/albumpicker/pkg/history"
+)
+
+// History command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past pick runs",
+	Args:  cobra.NoArgs,
+	RunE:  runHistoryCommand,
+}
+
+// History clear command
+var historyClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear the pick history",
+	Args:  cobra.NoArgs,
+	RunE:  runHistoryClearCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyClearCmd)
+	// local flags
+	historyCmd.Flags().IntP("limit", "n", 0, "number of most recent runs to list (default all)")
+}
+
+// loadHistory loads the pick history from the configured or default location
+func loadHistory(path string) (*history.History, error) {
+	if path == "" {
+		var err error
+		path, err = history.DefaultPath()
+		if err != nil {
+			return nil, fmt.Errorf("error finding history file: %s", err)
+		}
+	}
+	return history.Load(path)
+}
+
+// historyResult is the JSON document of the history command
+type historyResult struct {
+	Runs []history.Run `json:"runs"`
+	resultError
+}
+
+// runHistoryCommand executes the history command
+func runHistoryCommand(cmd *cobra.Command, _ []string) error {
+	result := &historyResult{Runs: []history.Run{}}
+	h, err := loadHistory(viper.GetString("history_file"))
+	if err != nil {
+		return writeResult(result, err)
+	}
+
+	runs := h.Runs
+	if limit, _ := cmd.Flags().GetInt("limit"); limit > 0 && limit < len(runs) {
+		runs = runs[len(runs)-limit:]
+	}
+	if jsonOutput() {
+		result.Runs = append(result.Runs, runs...)
+		return writeResult(result, nil)
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No pick runs recorded")
+		return nil
+	}
+
+	source := viper.GetString("source")
+	for _, run := range runs {
+		fmt.Printf("%s: %d albums", run.Time.Local().Format("2006-01-02 15:04:05"), len(run.Albums))
+		// runs recorded before seeds were introduced have no seed
+		if run.Seed != nil {
+			fmt.Printf(", seed %d", *run.Seed)
+		}
+		fmt.Println()
+		for _, album := range run.Albums {
+			// show albums relative to the source directory when possible
+			if rel, ok := strings.CutPrefix(album, filepath.Clean(source)+string(os.PathSeparator)); source != "" && ok {
+				album = rel
+			}
+			fmt.Printf("  %s\n", album)
+		}
+	}
+
+	return nil
+}
+
+// historyClearResult is the JSON document of the history clear command
+type historyClearResult struct {
+	// Cleared is the number of removed runs
+	Cleared int `json:"cleared"`
+	resultError
+}
+
+// runHistoryClearCommand executes the history clear command
+func runHistoryClearCommand(_ *cobra.Command, _ []string) error {
+	result := &historyClearResult{}
+	h, err := loadHistory(viper.GetString("history_file"))
+	if err != nil {
+		return writeResult(result, err)
+	}
+
+	cleared := len(h.Runs)
+	h.Clear()
+	if err := h.Save(); err != nil {
+		return writeResult(result, err)
+	}
+	result.Cleared = cleared
+
+	slog.Info("Pick history cleared")
+	return writeResult(result, nil)
+}
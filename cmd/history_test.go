@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nerten/albumpicker/pkg/history"
+)
+
+func TestHistoryCommands(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_history_cmd_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	historyFile := filepath.Join(tmpDir, "history.json")
+	viper.Reset()
+	viper.Set("history_file", historyFile)
+
+	// prepare history with a single run
+	h, err := history.Load(historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Add(history.Run{Time: time.Now(), Albums: []string{"/music/artist/album"}})
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().IntP("limit", "n", 0, "limit for testing")
+	if err := runHistoryCommand(cmd, nil); err != nil {
+		t.Errorf("runHistoryCommand() error = %v", err)
+	}
+
+	outputFormat = outputJSON
+	defer func() { outputFormat = outputText }()
+	var clearErr error
+	output := captureStdout(t, func() {
+		clearErr = runHistoryClearCommand(&cobra.Command{}, nil)
+	})
+	if clearErr != nil {
+		t.Fatalf("runHistoryClearCommand() error = %v", clearErr)
+	}
+	var result historyClearResult
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("history clear output %q is not a JSON document: %v", output, err)
+	}
+	if result.Cleared != 1 {
+		t.Errorf("cleared = %d, want 1", result.Cleared)
+	}
+
+	h, err = history.Load(historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Runs) != 0 {
+		t.Errorf("History was not cleared, got %d runs", len(h.Runs))
+	}
+}
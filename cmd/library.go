@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+)
+
+// scanLibrary finds all albums under root using the persistent library index
+func scanLibrary(ctx context.Context, conf *config.Config, root string, rescan bool) ([]library.Album, error) {
+	indexPath := conf.IndexFile
+	if indexPath == "" {
+		var err error
+		indexPath, err = library.DefaultPath()
+		if err != nil {
+			return nil, fmt.Errorf("error finding cache directory: %s", err)
+		}
+	}
+
+	idx, err := library.Load(indexPath)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("%s, rebuilding library index", err), "err", err)
+		idx = library.New(indexPath)
+	}
+	idx.SetExtensions(conf.AudioExtensions)
+	if rescan {
+		idx.Forget(root)
+	}
+	idx.Jobs = conf.ReadJobs
+
+	albums, err := idx.Scan(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := idx.Save(); err != nil {
+		slog.Warn(fmt.Sprintf("Could not save library index: %s", err), "err", err)
+	}
+
+	return albums, nil
+}
+
+// albumPaths returns paths of the albums
+func albumPaths(albums []library.Album) []string {
+	paths := make([]string, 0, len(albums))
+	for _, album := range albums {
+		paths = append(paths, album.Path)
+	}
+	return paths
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/nerten/albumpicker/pkg/logging"
+	"github.com/nerten/albumpicker/pkg/progress"
+)
+
+var (
+	quiet   bool
+	verbose bool
+)
+
+// cmdLogger is the logger of the running command, it is created by setupLogging
+var cmdLogger *logging.Logger
+
+// setupLogging creates the logger for the verbosity flags and the configured format
+// and log file, and makes it the default one
+func setupLogging() error {
+	level, err := logging.Level(quiet, verbose)
+	if err != nil {
+		return err
+	}
+	logger, err := logging.New(messageOutput(), os.Stderr, logging.Options{
+		Level:  level,
+		Format: viper.GetString("log_format"),
+		File:   viper.GetString("log_file"),
+	})
+	if err != nil {
+		return err
+	}
+
+	closeLogging()
+	cmdLogger = logger
+	slog.SetDefault(logger.Logger)
+	return nil
+}
+
+// closeLogging closes the log file of the command
+func closeLogging() {
+	if cmdLogger != nil {
+		cmdLogger.Close()
+		cmdLogger = nil
+	}
+}
+
+// newReporter returns the reporter of album processing, the terminal renderer draws progress
+// of default text messages to a terminal and other output is logged
+func newReporter() progress.Reporter {
+	if cmdLogger == nil {
+		return progress.NewLog(slog.Default())
+	}
+	out := messageOutput()
+	if quiet || verbose || viper.GetString("log_format") == logging.FormatJSON || !progress.IsTerminal(out) {
+		return progress.NewLog(cmdLogger.Logger)
+	}
+	// the log file still receives every event
+	return progress.Multi{progress.NewTerminal(out), progress.NewLog(cmdLogger.File)}
+}
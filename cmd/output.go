@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/processor"
+	"github.com/nerten/albumpicker/pkg/progress"
+)
+
+// Output formats of command results
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// outputFormat is set by the --output flag
+var outputFormat string
+
+// checkOutputFormat checks the value of the --output flag
+func checkOutputFormat() error {
+	switch outputFormat {
+	case "", outputText, outputJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q, use %s or %s", outputFormat, outputText, outputJSON)
+}
+
+// jsonOutput checks if command results are written as a JSON document
+func jsonOutput() bool {
+	return outputFormat == outputJSON
+}
+
+// messageOutput returns the file for human-readable messages, it's stderr
+// when stdout is reserved for the JSON document
+func messageOutput() *os.File {
+	if jsonOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// result is a JSON document of a command
+type result interface {
+	setError(err error)
+}
+
+// resultError is embedded by JSON documents to report the error which stopped the command
+type resultError struct {
+	Error string `json:"error,omitempty"`
+}
+
+func (r *resultError) setError(err error) {
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// writeResult writes the JSON document of the command to stdout when JSON output is enabled,
+// the error of the command is recorded in the document and returned
+func writeResult(doc result, err error) error {
+	if !jsonOutput() {
+		return err
+	}
+	doc.setError(err)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if encodeErr := enc.Encode(doc); encodeErr != nil && err == nil {
+		return fmt.Errorf("error writing JSON output: %s", encodeErr)
+	}
+	return err
+}
+
+// processResult is the JSON document of commands processing albums
+type processResult struct {
+	// Selected are albums chosen for processing
+	Selected []selectedAlbum `json:"selected"`
+	// Removed are paths removed from the destination directory before processing
+	Removed []string `json:"removed,omitempty"`
+	// DryRun is set when Plan was made instead of processing
+	DryRun bool            `json:"dry_run,omitempty"`
+	Plan   *processor.Plan `json:"plan,omitempty"`
+	// Albums are results of processed albums
+	Albums         []progress.AlbumResult `json:"albums"`
+	EstimatedBytes int64                  `json:"estimated_bytes"`
+	WrittenBytes   int64                  `json:"written_bytes"`
+	Warnings       []string               `json:"warnings,omitempty"`
+	resultError
+}
+
+// selectedAlbum is an album chosen for processing with its estimated size in the destination
+type selectedAlbum struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+// newProcessResult creates a document with empty lists
+func newProcessResult() processResult {
+	return processResult{Selected: []selectedAlbum{}, Albums: []progress.AlbumResult{}}
+}
+
+// setSelected records albums chosen for processing
+func (r *processResult) setSelected(albums []library.Album, conf *config.Config) {
+	r.Selected = make([]selectedAlbum, len(albums))
+	for i, album := range albums {
+		r.Selected[i] = selectedAlbum{Path: album.Path, Bytes: processor.EstimateAlbumSize(album, conf)}
+	}
+}
+
+// processAlbums processes albums reporting progress to the command reporter and records their results
+func processAlbums(ctx context.Context, albums []library.Album, conf *config.Config, result *processResult) error {
+	summary := progress.NewSummary()
+	err := processor.ProcessAlbums(progress.WithReporter(ctx, progress.Multi{newReporter(), summary}), albums, conf)
+	result.Albums = summary.Albums()
+	result.EstimatedBytes, result.WrittenBytes = summary.Bytes()
+	result.Warnings = summary.Warnings()
+	return err
+}
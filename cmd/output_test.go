@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nerten/albumpicker/pkg/progress"
+)
+
+// captureStdout returns everything fn writes to stdout
+func captureStdout(t *testing.T, fn func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = oldStdout }()
+
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- data
+	}()
+	fn()
+	w.Close()
+	return <-output
+}
+
+func TestJSONOutput(t *testing.T) {
+	// get project root directory
+	projectRoot, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatalf("Failed to get project root: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "albumpicker_output_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	albumDir := filepath.Join(sourceDir, "test-album")
+	if err := os.MkdirAll(albumDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(projectRoot, "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(albumDir, "01 - test.flac"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	viper.Set("source", sourceDir)
+	viper.Set("destination", destDir)
+	viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+
+	outputFormat = outputJSON
+	defer func() { outputFormat = outputText }()
+
+	// the first copy writes the album, the second one skips it
+	for _, wantStatus := range []string{progress.StatusCopied, progress.StatusSkipped} {
+		var copyErr error
+		output := captureStdout(t, func() {
+			copyErr = runCopyCommand(&cobra.Command{}, []string{albumDir})
+		})
+		if copyErr != nil {
+			t.Fatalf("runCopyCommand() error = %v", copyErr)
+		}
+
+		var result processResult
+		if err := json.Unmarshal(output, &result); err != nil {
+			t.Fatalf("copy output %q is not a JSON document: %v", output, err)
+		}
+		if len(result.Selected) != 1 || result.Selected[0].Path != albumDir {
+			t.Errorf("selected = %v, want %s", result.Selected, albumDir)
+		}
+		if len(result.Albums) != 1 || result.Albums[0].Status != wantStatus {
+			t.Fatalf("albums = %+v, want a single %s album", result.Albums, wantStatus)
+		}
+		if wantStatus == progress.StatusCopied && (result.Albums[0].Files != 1 || result.WrittenBytes == 0 || result.WrittenBytes != result.Albums[0].Bytes) {
+			t.Errorf("copied %d files and %d bytes of %d in total, want 1 file", result.Albums[0].Files, result.Albums[0].Bytes, result.WrittenBytes)
+		}
+	}
+
+	// a broken album is reported in the document and as the error
+	if err := os.Truncate(filepath.Join(destDir, "test-album", "01 - test.flac"), 10); err != nil {
+		t.Fatal(err)
+	}
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("checksums", false, "checksums flag for testing")
+	var statusErr error
+	output := captureStdout(t, func() {
+		statusErr = runStatusCommand(cmd, nil)
+	})
+	if statusErr == nil {
+		t.Error("runStatusCommand() expected error for broken album")
+	}
+	var status statusResult
+	if err := json.Unmarshal(output, &status); err != nil {
+		t.Fatalf("status output %q is not a JSON document: %v", output, err)
+	}
+	if len(status.Albums) != 1 || !status.Albums[0].Broken || status.Error == "" {
+		t.Errorf("status = %+v, want a single broken album and an error", status)
+	}
+}
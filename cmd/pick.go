@@ -2,20 +2,31 @@ package cmd
 
 import (
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/disk"
+	"github.com/nerten/albumpicker/pkg/history"
+	"github.com/nerten/albumpicker/pkg/library"
 	"github.com/nerten/albumpicker/pkg/processor"
+	"github.com/nerten/albumpicker/pkg/query"
+	"github.com/nerten/albumpicker/pkg/selector"
 )
 
 // Pick command
 var pickCmd = &cobra.Command{
 	Use:   "pick",
-	Short: "Randomly select and copy FLAC albums",
+	Short: "Randomly select and copy music albums",
 	RunE:  runPickCommand,
 }
 
@@ -24,56 +35,452 @@ func init() {
 	// local flags
 	pickCmd.Flags().IntP("count", "n", 0, "number of albums to select (default 10)")
 	pickCmd.Flags().Bool("wipe", false, "wipe destination directory before copying albums. Attention!!! Destructive action!")
+	pickCmd.Flags().Bool("sync", false, "remove only albums in destination directory which are not selected and copy only new ones")
+	pickCmd.Flags().Int("rotate", 0, "replace this number of albums copied to destination directory least recently with new ones")
+	pickCmd.Flags().Bool("rescan", false, "ignore the library index and rescan the whole source directory")
+	pickCmd.Flags().String("exclude-recent", "", "don't pick albums picked during this period, e.g. 30d, 2w or 12h")
+	pickCmd.Flags().Int("exclude-last-runs", 0, "don't pick albums picked during this number of last runs")
+	pickCmd.Flags().String("size", "", "select albums up to this total size instead of a fixed count, e.g. 20GB")
+	pickCmd.Flags().Bool("fill", false, "select albums to fill the free space of the destination directory")
+	pickCmd.Flags().String("fill-reserve", "", "free space to leave in the destination directory with --fill (default 100MB)")
+	pickCmd.Flags().StringSlice("genre", nil, "pick only albums of this genre (can be repeated)")
+	pickCmd.Flags().StringSlice("artist", nil, "pick only albums of this artist (can be repeated)")
+	pickCmd.Flags().String("year", "", "pick only albums released in this year or range of years, e.g. 1975 or 1970-1979")
+	pickCmd.Flags().StringSlice("label", nil, "pick only albums released by this label (can be repeated)")
+	pickCmd.Flags().StringSlice("releasetype", nil, "pick only albums of this release type, e.g. album or live (can be repeated)")
+	pickCmd.Flags().StringP("query", "q", "", "pick only albums matching the filter expression or the saved query with this name")
+	pickCmd.Flags().String("strategy", "", "selection strategy: "+strings.Join(selector.Strategies, ", ")+" (default uniform)")
+	pickCmd.Flags().String("weight-file", "", "file with album weights for the file selection strategy")
+	pickCmd.Flags().Int("max-per-artist", 0, "maximum number of albums of a single artist (default no limit)")
+	pickCmd.Flags().Int("max-per-genre", 0, "maximum number of albums of a single genre (default no limit)")
+	pickCmd.Flags().Uint64("seed", 0, "seed of the random generator to reproduce a previous pick with the same library and options, "+
+		"picks with the age strategy or excluded recent albums also depend on the date and the pick history (default random)")
+	addDryRunFlags(pickCmd)
+	addVerifyFlag(pickCmd)
 
 	// bind flags to viper
-	err := viper.BindPFlag("albums_count", pickCmd.Flags().Lookup("count"))
-	if err != nil {
-		panic(err.Error())
+	m := map[string]string{
+		"albums_count":       "count",
+		"exclude_recent":     "exclude-recent",
+		"exclude_last_runs":  "exclude-last-runs",
+		"size_budget":        "size",
+		"fill":               "fill",
+		"fill_reserve":       "fill-reserve",
+		"selection_strategy": "strategy",
+		"weight_file":        "weight-file",
+		"max_per_artist":     "max-per-artist",
+		"max_per_genre":      "max-per-genre",
+	}
+	for key, name := range m {
+		err := viper.BindPFlag(key, pickCmd.Flags().Lookup(name))
+		if err != nil {
+			panic(err.Error())
+		}
 	}
 }
 
+// pickResult is the JSON document of the pick command
+type pickResult struct {
+	Seed uint64 `json:"seed"`
+	processResult
+}
+
 // runPickCommand executes the pick command
 func runPickCommand(cmd *cobra.Command, _ []string) error {
+	result := &pickResult{processResult: newProcessResult()}
+	err := pickAlbums(cmd, result)
+	return writeResult(result, err)
+}
+
+// pickAlbums selects and copies albums and records them in the result
+func pickAlbums(cmd *cobra.Command, result *pickResult) error {
 	// load configuration
 	conf, err := config.LoadConfig()
 	if err != nil {
 		return err
 	}
+	applyVerifyFlag(cmd, conf)
+	if !isDryRun(cmd) {
+		if err := conf.CreateDestination(); err != nil {
+			return err
+		}
+	}
 
+	// a dry run may plan copying to a destination directory which doesn't exist yet
 	destination, err := os.ReadDir(conf.Destination)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("error finding destination directory: %s", err)
 	}
 
 	// find all albums in source directory
-	fmt.Println("Scanning source directory for FLAC albums...")
-	albums, err := processor.FindAllAlbums(conf.Source)
+	ctx := commandContext(cmd)
+	slog.Info("Scanning source directory for albums...")
+	rescan, _ := cmd.Flags().GetBool("rescan")
+	albums, err := scanLibrary(ctx, conf, conf.Source, rescan)
 	if err != nil {
 		return fmt.Errorf("error scanning source directory: %s", err)
 	}
 
 	if len(albums) == 0 {
-		return fmt.Errorf("no FLAC albums found in source directory")
+		return fmt.Errorf("no albums found in source directory")
+	}
+
+	slog.Info(fmt.Sprintf("Found %d albums in total", len(albums)), "albums", len(albums))
+
+	// filter albums by tags
+	filter, err := albumFilter(cmd)
+	if err != nil {
+		return err
+	}
+	if !filter.IsEmpty() {
+		albums = filter.Apply(albums)
+		if len(albums) == 0 {
+			return fmt.Errorf("no albums match the filters")
+		}
+		slog.Info(fmt.Sprintf("%d albums match the filters", len(albums)), "albums", len(albums))
 	}
 
-	fmt.Printf("Found %d albums in total\n", len(albums))
+	// filter albums by the query
+	if queryText, _ := cmd.Flags().GetString("query"); queryText != "" {
+		q, err := parseQuery(queryText, conf)
+		if err != nil {
+			return err
+		}
+		albums = applyQuery(albums, q)
+		if len(albums) == 0 {
+			return fmt.Errorf("no albums match the query: %s", q)
+		}
+		slog.Info(fmt.Sprintf("%d albums match the query: %s", len(albums), q), "albums", len(albums), "query", q.String())
+	}
+
+	// exclude recently picked albums
+	h, err := loadHistory(conf.HistoryFile)
+	if err != nil {
+		return err
+	}
+	candidates := albums
+	if conf.ExcludeRecent > 0 || conf.ExcludeLastRuns > 0 {
+		candidates = excludeRecentAlbums(candidates, h, conf)
+		if len(candidates) == 0 {
+			return fmt.Errorf("all albums were picked recently")
+		}
+		slog.Info(fmt.Sprintf("%d albums were not picked recently", len(candidates)), "albums", len(candidates))
+	}
+
+	// find what can be removed from the destination directory
+	wipe, _ := cmd.Flags().GetBool("wipe")
+	sync, _ := cmd.Flags().GetBool("sync")
+	rotate, _ := cmd.Flags().GetInt("rotate")
+	var removable []string
+	switch {
+	case wipe && sync:
+		return fmt.Errorf("--wipe and --sync can't be used together")
+	case rotate < 0:
+		return fmt.Errorf("number of albums to rotate must be positive")
+	case rotate > 0 && (wipe || sync):
+		return fmt.Errorf("--rotate can't be used together with --wipe or --sync")
+	case rotate > 0:
+		onDevice, err := albumsOnDevice(ctx, conf)
+		if err != nil {
+			return err
+		}
+		// only albums copied by albumpicker are rotated
+		removable, err = managedAlbums(conf)
+		if err != nil {
+			return err
+		}
+		removable = removable[:min(rotate, len(removable))]
+		candidates = excludeAlbumsOnDevice(candidates, onDevice, conf)
+		if len(candidates) == 0 {
+			return fmt.Errorf("all albums are already in destination directory")
+		}
+	case wipe:
+		for _, d := range destination {
+			removable = append(removable, path.Join(conf.Destination, d.Name()))
+		}
+	case sync:
+		// only albums copied by albumpicker are removed
+		removable, err = managedAlbums(conf)
+		if err != nil {
+			return err
+		}
+	}
 
 	// select random albums
-	fmt.Printf("Selecting %d random albums...\n", conf.AlbumsCount)
-	selectedAlbums := processor.SelectRandomAlbums(albums, conf.AlbumsCount)
+	budget, err := sizeBudget(conf, removable)
+	if err != nil {
+		return err
+	}
+	weight, err := albumWeight(conf, candidates, h)
+	if err != nil {
+		return err
+	}
+	seed := rand.Uint64()
+	if cmd.Flags().Changed("seed") {
+		seed, _ = cmd.Flags().GetUint64("seed")
+	}
+	slog.Info(fmt.Sprintf("Seed: %d", seed), "seed", seed)
+	result.Seed = seed
+	opts := selector.Options{
+		Count: conf.AlbumsCount,
+		Size: func(album library.Album) int64 {
+			return processor.EstimateAlbumSize(album, conf)
+		},
+		Weight:       weight,
+		MaxPerArtist: conf.MaxPerArtist,
+		MaxPerGenre:  conf.MaxPerGenre,
+		Rand:         selector.NewRand(seed),
+	}
+	if rotate > 0 {
+		if budget > 0 {
+			return fmt.Errorf("--rotate can't be used together with a size budget")
+		}
+		opts.Count = rotate
+	}
+	if budget > 0 {
+		opts.Count = 0
+		opts.Budget = budget
+		slog.Info(fmt.Sprintf("Selecting random albums up to %s...", config.FormatSize(budget)), "budget", budget)
+	} else {
+		if opts.Count <= 0 {
+			return fmt.Errorf("albums count must be positive")
+		}
+		slog.Info(fmt.Sprintf("Selecting %d random albums...", opts.Count), "albums", opts.Count)
+	}
+	selection := selector.Select(candidates, opts)
+	if opts.Count > 0 && len(selection.Albums) < opts.Count && selection.SkippedByArtist+selection.SkippedByGenre > 0 {
+		slog.Warn(fmt.Sprintf("Only %d of %d albums could be selected, %d albums were skipped by the per-artist limit and %d by the per-genre limit",
+			len(selection.Albums), opts.Count, selection.SkippedByArtist, selection.SkippedByGenre),
+			"albums", len(selection.Albums), "count", opts.Count,
+			"skipped_by_artist", selection.SkippedByArtist, "skipped_by_genre", selection.SkippedByGenre)
+	}
+	if len(selection.Albums) == 0 {
+		return fmt.Errorf("no albums fit into %s", config.FormatSize(budget))
+	}
+	slog.Info(fmt.Sprintf("Selected %d albums, estimated size %s", len(selection.Albums), config.FormatSize(selection.Size)),
+		"albums", len(selection.Albums), "bytes", selection.Size)
+	selectedAlbums := albumPaths(selection.Albums)
+	result.setSelected(selection.Albums, conf)
+
+	remove := removable
+	if sync {
+		remove, err = staleAlbums(removable, selectedAlbums, conf)
+		if err != nil {
+			return err
+		}
+		slog.Info(fmt.Sprintf("Keeping %d albums already in destination directory, removing %d albums",
+			len(removable)-len(remove), len(remove)), "kept", len(removable)-len(remove), "removed", len(remove))
+	}
+
+	if isDryRun(cmd) {
+		result.DryRun = true
+		result.Plan, err = showPlan(cmd, conf, selection.Albums, remove)
+		return err
+	}
 
-	// check wipe flag
-	if wipe, _ := cmd.Flags().GetBool("wipe"); wipe {
+	// don't remove anything if the run is interrupted before copying
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	result.Removed = remove
+	if wipe {
 		// wipe destination directory
-		fmt.Printf("Wiping destination directory: %s\n", conf.Destination)
-		for _, d := range destination {
-			if err := os.RemoveAll(path.Join(conf.Destination, d.Name())); err != nil {
+		slog.Info(fmt.Sprintf("Wiping destination directory: %s", conf.Destination), "path", conf.Destination)
+		for _, p := range remove {
+			if err := os.RemoveAll(p); err != nil {
 				return fmt.Errorf("failed to wipe destination directory: %s", err)
 			}
 		}
 	}
+	if sync || rotate > 0 {
+		// remove albums which are not selected anymore or rotated out
+		if err := removeAlbums(remove, conf); err != nil {
+			return err
+		}
+	}
 
 	// process albums
-	fmt.Printf("Processing selected %d albums...\n", len(selectedAlbums))
-	return processor.ProcessAlbums(selectedAlbums, conf)
+	slog.Info(fmt.Sprintf("Processing selected %d albums...", len(selectedAlbums)), "albums", len(selectedAlbums))
+	processErr := processAlbums(ctx, selection.Albums, conf, &result.processResult)
+	if ctx.Err() != nil {
+		// interrupted runs aren't recorded, so excluding recent picks doesn't skip albums never copied
+		slog.Warn(fmt.Sprintf("The run is not recorded in the pick history, repeat it with --seed %d", seed), "seed", seed)
+		return processErr
+	}
+
+	// record the run in the history
+	h.Add(history.Run{Time: time.Now(), Seed: &seed, Albums: selectedAlbums})
+	if err := h.Save(); err != nil {
+		slog.Warn(fmt.Sprintf("Could not save pick history: %s", err), "err", err)
+	}
+
+	return processErr
+}
+
+// albumFilter creates the tag filter from the command flags
+func albumFilter(cmd *cobra.Command) (library.Filter, error) {
+	var filter library.Filter
+	filter.Genres, _ = cmd.Flags().GetStringSlice("genre")
+	filter.Artists, _ = cmd.Flags().GetStringSlice("artist")
+	filter.Labels, _ = cmd.Flags().GetStringSlice("label")
+	filter.ReleaseTypes, _ = cmd.Flags().GetStringSlice("releasetype")
+
+	year, _ := cmd.Flags().GetString("year")
+	var err error
+	filter.YearFrom, filter.YearTo, err = library.ParseYearRange(year)
+	if err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// parseQuery parses the filter expression, which can be the name of a query saved in the config
+func parseQuery(queryText string, conf *config.Config) (*query.Query, error) {
+	// viper lowercases map keys, so saved query names are case-insensitive
+	if saved, ok := conf.Queries[strings.ToLower(queryText)]; ok {
+		queryText = saved
+	}
+
+	q, err := query.Parse(queryText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %s", queryText, err)
+	}
+	return q, nil
+}
+
+// applyQuery returns albums matching the query
+func applyQuery(albums []library.Album, q *query.Query) []library.Album {
+	var matched []library.Album
+	for _, album := range albums {
+		if q.Match(album) {
+			matched = append(matched, album)
+		}
+	}
+	return matched
+}
+
+// albumWeight returns the weight function of the configured selection strategy
+func albumWeight(conf *config.Config, albums []library.Album, h *history.History) (func(library.Album) float64, error) {
+	switch conf.SelectionStrategy {
+	case "", selector.StrategyUniform:
+		return nil, nil
+	case selector.StrategyRating:
+		return selector.RatingWeight(conf.RatingScale), nil
+	case selector.StrategyAge:
+		return selector.AgeWeight(h.LastPicked(), time.Now()), nil
+	case selector.StrategyArtist:
+		return selector.ArtistWeight(albums), nil
+	case selector.StrategyFile:
+		if conf.WeightFile == "" {
+			return nil, fmt.Errorf("weight file is required for the %s selection strategy", selector.StrategyFile)
+		}
+		return selector.LoadWeightFile(conf.WeightFile, conf.Source)
+	default:
+		return nil, fmt.Errorf("unknown selection strategy %q, available strategies: %s",
+			conf.SelectionStrategy, strings.Join(selector.Strategies, ", "))
+	}
+}
+
+// sizeBudget returns the maximum size of selected albums, 0 means the albums count is used instead,
+// the space of removable paths in the destination directory is counted as free
+func sizeBudget(conf *config.Config, removable []string) (int64, error) {
+	if !conf.Fill {
+		return conf.SizeBudget, nil
+	}
+
+	free, err := disk.Free(existingDir(conf.Destination))
+	if err != nil {
+		return 0, fmt.Errorf("error getting free space of destination directory: %s", err)
+	}
+	for _, p := range removable {
+		used, err := dirSize(p)
+		if err != nil {
+			return 0, fmt.Errorf("error getting size of destination directory: %s", err)
+		}
+		free += used
+	}
+
+	budget := free - conf.FillReserve
+	if conf.SizeBudget > 0 && conf.SizeBudget < budget {
+		budget = conf.SizeBudget
+	}
+	if budget <= 0 {
+		return 0, fmt.Errorf("not enough free space in destination directory: %s available, %s reserved",
+			config.FormatSize(free), config.FormatSize(conf.FillReserve))
+	}
+	slog.Info(fmt.Sprintf("Free space in destination directory: %s", config.FormatSize(free)), "bytes", free)
+
+	return budget, nil
+}
+
+// existingDir returns the path or its closest existing parent, which is on the same filesystem
+// as the path will be once it's created
+func existingDir(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// dirSize returns the total size of files in the directory
+func dirSize(root string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// staleAlbums returns albums in the destination directory which are not among the selected albums
+func staleAlbums(destAlbums, selected []string, conf *config.Config) ([]string, error) {
+	keep := make(map[string]bool, len(selected))
+	for _, albumPath := range selected {
+		destAlbumPath, err := processor.DestinationPath(albumPath, conf)
+		if err != nil {
+			return nil, err
+		}
+		keep[destAlbumPath] = true
+	}
+
+	var stale []string
+	for _, destAlbumPath := range destAlbums {
+		if !keep[filepath.Clean(destAlbumPath)] {
+			stale = append(stale, destAlbumPath)
+		}
+	}
+	return stale, nil
+}
+
+// excludeRecentAlbums removes albums picked recently according to the history
+func excludeRecentAlbums(albums []library.Album, h *history.History, conf *config.Config) []library.Album {
+	var since time.Time
+	if conf.ExcludeRecent > 0 {
+		since = time.Now().Add(-conf.ExcludeRecent)
+	}
+	recent := h.RecentAlbums(since, conf.ExcludeLastRuns)
+
+	var candidates []library.Album
+	for _, album := range albums {
+		if !recent[album.Path] {
+			candidates = append(candidates, album)
+		}
+	}
+	return candidates
 }
@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/nerten/albumpicker/pkg/history"
+	"github.com/nerten/albumpicker/pkg/manifest"
 )
 
 func TestRunPickCommand(t *testing.T) {
@@ -79,6 +84,8 @@ func TestRunPickCommand(t *testing.T) {
 			setup: func(cmd *cobra.Command) {
 				viper.Set("source", sourceDir)
 				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
 				viper.Set("albums_count", 1)
 				viper.Set("cover_filenames", []string{"cover.jpg"})
 				viper.Set("output_cover_filename", "cover.jpg")
@@ -109,6 +116,8 @@ func TestRunPickCommand(t *testing.T) {
 			setup: func(cmd *cobra.Command) {
 				viper.Set("source", sourceDir)
 				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
 				viper.Set("albums_count", 1)
 				cmd.Flags().Set("wipe", "true")
 			},
@@ -126,11 +135,240 @@ func TestRunPickCommand(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "exclude recently picked albums",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				viper.Set("exclude_last_runs", 1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "pick with too small size budget",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("size_budget", "100B")
+			},
+			wantErr: true,
+		},
+		{
+			name: "pick with matching genre",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				cmd.Flags().StringSlice("genre", nil, "genre flag for testing")
+				cmd.Flags().Set("genre", "test disc")
+			},
+			wantErr: false,
+		},
+		{
+			name: "pick with not matching genre",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				cmd.Flags().StringSlice("genre", nil, "genre flag for testing")
+				cmd.Flags().Set("genre", "Jazz")
+			},
+			wantErr: true,
+		},
+		{
+			name: "pick with saved query",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				viper.Set("queries", map[string]string{"favourites": `genre:test AND year>=2020 AND NOT artist:"Various Artists"`})
+				cmd.Flags().String("query", "", "query flag for testing")
+				cmd.Flags().Set("query", "favourites")
+			},
+			wantErr: false,
+		},
+		{
+			name: "pick with invalid query",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				cmd.Flags().String("query", "", "query flag for testing")
+				cmd.Flags().Set("query", "genre:rock AND")
+			},
+			wantErr: true,
+		},
+		{
+			name: "pick with unknown strategy",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				viper.Set("selection_strategy", "popularity")
+			},
+			wantErr: true,
+		},
+		{
+			name: "pick with seed",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				cmd.Flags().Uint64("seed", 0, "seed flag for testing")
+				cmd.Flags().Set("seed", "12345")
+			},
+			wantErr: false,
+			check: func(t *testing.T) {
+				h, err := history.Load(filepath.Join(tmpDir, "history.json"))
+				if err != nil {
+					t.Fatalf("Failed to load history: %v", err)
+				}
+				if len(h.Runs) == 0 || h.Runs[len(h.Runs)-1].Seed == nil || *h.Runs[len(h.Runs)-1].Seed != 12345 {
+					t.Errorf("Seed was not recorded in the history: %+v", h.Runs)
+				}
+			},
+		},
+		{
+			name: "dry run with wipe flag",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				cmd.Flags().Set("wipe", "true")
+				cmd.Flags().Bool("dry-run", false, "dry run flag for testing")
+				cmd.Flags().String("plan-file", "", "plan file flag for testing")
+				cmd.Flags().Set("plan-file", filepath.Join(tmpDir, "plan.json"))
+			},
+			wantErr: false,
+			check: func(t *testing.T) {
+				// check that destination was not wiped
+				if _, err := os.Stat(destTestFile); err != nil {
+					t.Errorf("Existing file was removed during dry run: %v", err)
+				}
+				if _, err := os.Stat(filepath.Join(tmpDir, "plan.json")); err != nil {
+					t.Errorf("Plan file was not written: %v", err)
+				}
+			},
+		},
+		{
+			name: "dry run to missing destination",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", filepath.Join(tmpDir, "device", "Music"))
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("fill", true)
+				viper.Set("fill_reserve", "1MB")
+				cmd.Flags().Bool("dry-run", false, "dry run flag for testing")
+				cmd.Flags().Set("dry-run", "true")
+			},
+			wantErr: false,
+			check: func(t *testing.T) {
+				if _, err := os.Stat(filepath.Join(tmpDir, "device")); !os.IsNotExist(err) {
+					t.Errorf("Dry run created the destination directory: %v", err)
+				}
+			},
+		},
+		{
+			name: "pick with sync flag",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				// an album copied by albumpicker which is not in the source directory anymore
+				// and an album copied by the user
+				for _, name := range []string{"stale-album", "user-album"} {
+					album := filepath.Join(destDir, name)
+					if err := os.MkdirAll(album, 0o755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(filepath.Join(album, "01.flac"), []byte("test data"), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				m, err := manifest.Load(destDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m.Add("stale-album", &manifest.Album{Source: filepath.Join(sourceDir, "stale-album"), CopiedAt: time.Now()})
+				if err := m.Save(); err != nil {
+					t.Fatal(err)
+				}
+				cmd.Flags().Bool("sync", false, "sync flag for testing")
+				cmd.Flags().Set("sync", "true")
+			},
+			wantErr: false,
+			check: func(t *testing.T) {
+				if _, err := os.Stat(filepath.Join(destDir, "stale-album")); !os.IsNotExist(err) {
+					t.Errorf("Stale album was not removed")
+				}
+				if _, err := os.Stat(filepath.Join(destDir, "user-album")); err != nil {
+					t.Errorf("Album not copied by albumpicker was removed: %v", err)
+				}
+				if _, err := os.Stat(filepath.Join(destDir, "test-album")); err != nil {
+					t.Errorf("Selected album is missing: %v", err)
+				}
+				// files which are not albums are kept
+				if _, err := os.Stat(destTestFile); err != nil {
+					t.Errorf("Existing file was removed: %v", err)
+				}
+			},
+		},
+		{
+			name: "pick with wipe and sync flags",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				viper.Set("albums_count", 1)
+				cmd.Flags().Set("wipe", "true")
+				cmd.Flags().Bool("sync", false, "sync flag for testing")
+				cmd.Flags().Set("sync", "true")
+			},
+			wantErr: true,
+		},
+		{
+			name: "pick with rotate flag",
+			setup: func(cmd *cobra.Command) {
+				viper.Set("source", sourceDir)
+				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+				cmd.Flags().Int("rotate", 0, "rotate flag for testing")
+				cmd.Flags().Set("rotate", "1")
+			},
+			// the only album of the library is already in destination directory
+			wantErr: true,
+		},
 		{
 			name: "invalid source directory",
 			setup: func(cmd *cobra.Command) {
 				viper.Set("source", "/nonexistent/path")
 				viper.Set("destination", destDir)
+				viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+				viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
 			},
 			wantErr: true,
 		},
@@ -179,3 +417,79 @@ func TestRunPickCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestRunPickCommandRotateTranscoded(t *testing.T) {
+	// get project root directory
+	projectRoot, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatalf("Failed to get project root: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "albumpicker_rotate_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	for _, name := range []string{"copied-album", "new-album"} {
+		if err := os.MkdirAll(filepath.Join(sourceDir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := copyFile(filepath.Join(projectRoot, "test_data", "01 - test.flac"), filepath.Join(sourceDir, name, "01 - test.flac")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the copied album was transcoded, the scan doesn't recognise its files
+	copiedDir := filepath.Join(destDir, "copied-album")
+	if err := os.MkdirAll(copiedDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(copiedDir, "01 - test.m4a"), []byte("test aac data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := manifest.New(destDir)
+	m.Add("copied-album", &manifest.Album{Source: filepath.Join(sourceDir, "copied-album"), CopiedAt: time.Now()})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	viper.Set("source", sourceDir)
+	viper.Set("destination", destDir)
+	viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+	viper.Set("history_file", filepath.Join(tmpDir, "history.json"))
+	viper.Set("albums_count", 2)
+	viper.Set("output_format", "aac")
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("wipe", false, "wipe flag for testing")
+	cmd.Flags().Int("rotate", 0, "rotate flag for testing")
+	cmd.Flags().Set("rotate", "1")
+	addDryRunFlags(cmd)
+	cmd.Flags().Set("dry-run", "true")
+
+	outputFormat = outputJSON
+	defer func() { outputFormat = outputText }()
+	var pickErr error
+	output := captureStdout(t, func() {
+		pickErr = runPickCommand(cmd, nil)
+	})
+	if pickErr != nil {
+		t.Fatalf("runPickCommand() error = %v", pickErr)
+	}
+
+	// the copied album is rotated out and not selected again
+	var result processResult
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("pick output %q is not a JSON document: %v", output, err)
+	}
+	if len(result.Selected) != 1 || result.Selected[0].Path != filepath.Join(sourceDir, "new-album") {
+		t.Errorf("selected = %v, want only new-album", result.Selected)
+	}
+	if result.Plan == nil || len(result.Plan.Wipe) != 1 || result.Plan.Wipe[0] != copiedDir {
+		t.Errorf("plan = %+v, want copied-album removed", result.Plan)
+	}
+}
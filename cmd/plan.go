@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/processor"
+)
+
+// addDryRunFlags adds flags for planning without touching the destination directory
+func addDryRunFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("dry-run", false, "print planned actions without touching the destination directory")
+	cmd.Flags().String("plan-file", "", "write planned actions as JSON to this file, implies --dry-run")
+}
+
+// isDryRun checks if the command only plans actions
+func isDryRun(cmd *cobra.Command) bool {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	planFile, _ := cmd.Flags().GetString("plan-file")
+	return dryRun || planFile != ""
+}
+
+// showPlan prints the planned actions and writes them to the plan file if it's set,
+// with JSON output the plan is returned for the command document instead of printing it
+func showPlan(cmd *cobra.Command, conf *config.Config, albums []library.Album, wipe []string) (*processor.Plan, error) {
+	plan, err := processor.PlanAlbums(albums, wipe, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Dry run, the destination directory is not changed")
+	if !jsonOutput() {
+		plan.Print(os.Stdout)
+	}
+
+	if planFile, _ := cmd.Flags().GetString("plan-file"); planFile != "" {
+		if err := plan.WriteFile(planFile); err != nil {
+			return nil, err
+		}
+		slog.Info(fmt.Sprintf("Plan written to %s", planFile), "path", planFile)
+	}
+
+	return plan, nil
+}
@@ -1,13 +1,21 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/transcode"
 )
 
 var cfgFile string
@@ -17,11 +25,11 @@ var osExit = os.Exit
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "albumpicker",
-	Short: "Utility for randomly selecting and copying FLAC albums",
+	Short: "Utility for randomly selecting and copying music albums",
 	Long: `A console utility written in Go for randomly selecting and copying music albums
-in FLAC format. The utility allows specifying the number of albums to copy,
-provides functionality to remove image metadata from FLAC files, and
-copies album covers with size optimization.`,
+in FLAC, MP3, Ogg Vorbis and Opus formats. The utility allows specifying the number
+of albums to copy, provides functionality to remove embedded pictures from audio files,
+and copies album covers with size optimization.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -31,27 +39,76 @@ copies album covers with size optimization.`,
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute(version string) {
 	rootCmd.Version = version
-	err := rootCmd.Execute()
+	err := rootCmd.ExecuteContext(interruptContext())
+	closeLogging()
 	if err != nil {
 		osExit(1)
 	}
 }
 
+// interruptContext returns a context cancelled by the first SIGINT or SIGTERM,
+// the next signal terminates the process at once
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		fmt.Fprintln(os.Stderr, "Interrupted, removing unfinished files, press Ctrl-C again to quit immediately")
+		cancel()
+	}()
+	return ctx
+}
+
+// commandContext returns the context of the command, commands run directly by tests have none
+func commandContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is $HOME/.config/albumpicker/config.yaml)")
 
-	rootCmd.PersistentFlags().StringP("source", "s", "", "source directory with FLAC albums")
+	rootCmd.PersistentFlags().StringP("source", "s", "", "source directory with music albums")
 	rootCmd.PersistentFlags().StringP("destination", "d", "", "destination directory for copied albums")
 	rootCmd.PersistentFlags().Int("height", 0, "cover image height in pixels (default 240)")
 	rootCmd.PersistentFlags().String("cover-name", "", "output cover file name")
+	rootCmd.PersistentFlags().IntP("jobs", "j", 0, "number of albums and files processed concurrently (default number of CPUs)")
+	rootCmd.PersistentFlags().Int("read-jobs", 0, "maximum number of source files read concurrently (default --jobs)")
+	rootCmd.PersistentFlags().Int("write-jobs", 0, "maximum number of files written to destination concurrently (default 1)")
+	rootCmd.PersistentFlags().String("transcode-format", "", "format FLAC files are transcoded to: "+strings.Join(transcode.FormatNames(), ", ")+" (default flac)")
+	rootCmd.PersistentFlags().Int("bitrate", 0, "bitrate of transcoded files in kbit/s (default depends on the format)")
+	rootCmd.PersistentFlags().String("encoder", "", "encoder transcoding files: "+strings.Join(transcode.Backends(), ", ")+" (default ffmpeg)")
+	rootCmd.PersistentFlags().Bool("downsample", false, "convert FLAC files above --sample-rate or --bit-depth down to them")
+	rootCmd.PersistentFlags().Int("sample-rate", 0, "highest sample rate of downsampled FLAC files in Hz (default 44100)")
+	rootCmd.PersistentFlags().Int("bit-depth", 0, "highest bit depth of downsampled FLAC files (default 16)")
+	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "print only warnings and errors")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "print every written file and other details")
+	rootCmd.PersistentFlags().String("log-format", "", "format of log messages: text or json (default text)")
+	rootCmd.PersistentFlags().String("log-file", "", "file receiving log records of all levels")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "format of command results: text or json, messages go to stderr with json")
 
 	m := map[string]string{
 		"source":                "source",
 		"destination":           "destination",
 		"cover_height":          "height",
 		"output_cover_filename": "cover-name",
+		"jobs":                  "jobs",
+		"read_jobs":             "read-jobs",
+		"write_jobs":            "write-jobs",
+		"output_format":         "transcode-format",
+		"output_bitrate":        "bitrate",
+		"encoder":               "encoder",
+		"downsample":            "downsample",
+		"sample_rate":           "sample-rate",
+		"bit_depth":             "bit-depth",
+		"log_format":            "log-format",
+		"log_file":              "log-file",
 	}
 	for key, name := range m {
 		err := viper.BindPFlag(key, rootCmd.PersistentFlags().Lookup(name))
@@ -69,6 +126,37 @@ func initConfig() {
 	viper.SetDefault("cover_filenames", []string{"album.jpg", "album.png", "cover.jpg", "cover.png"})
 	viper.SetDefault("output_cover_filename", "cover.jpg")
 	viper.SetDefault("cover_height", 240)
+	viper.SetDefault("index_file", "")
+	viper.SetDefault("history_file", "")
+	viper.SetDefault("exclude_recent", "")
+	viper.SetDefault("exclude_last_runs", 0)
+	viper.SetDefault("size_budget", "")
+	viper.SetDefault("fill", false)
+	viper.SetDefault("fill_reserve", "100MB")
+	viper.SetDefault("queries", map[string]string{})
+	viper.SetDefault("selection_strategy", "uniform")
+	viper.SetDefault("weight_file", "")
+	viper.SetDefault("max_per_artist", 0)
+	viper.SetDefault("max_per_genre", 0)
+	viper.SetDefault("rating_scale", 0)
+	viper.SetDefault("verify", false)
+	viper.SetDefault("jobs", 0)
+	viper.SetDefault("read_jobs", 0)
+	viper.SetDefault("write_jobs", 0)
+	viper.SetDefault("output_format", "flac")
+	viper.SetDefault("output_bitrate", 0)
+	viper.SetDefault("encoder", "ffmpeg")
+	viper.SetDefault("downsample", false)
+	viper.SetDefault("sample_rate", 44100)
+	viper.SetDefault("bit_depth", 16)
+	viper.SetDefault("audio_extensions", audio.DefaultExtensions())
+	viper.SetDefault("flac_front_cover", false)
+	viper.SetDefault("flac_drop_application", false)
+	viper.SetDefault("flac_drop_cuesheet", false)
+	viper.SetDefault("flac_drop_seektable", false)
+	viper.SetDefault("flac_padding", "")
+	viper.SetDefault("log_format", "text")
+	viper.SetDefault("log_file", "")
 
 	if cfgFile != "" {
 		// use config file from the flag
@@ -78,14 +166,16 @@ func initConfig() {
 		home, err := os.UserHomeDir()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error finding home directory: %s\n", err)
-			os.Exit(1)
+			osExit(1)
+			return
 		}
 		// search config in home directory
 		configDir := filepath.Join(home, ".config", "albumpicker")
 		if _, err := os.Stat(configDir); os.IsNotExist(err) {
 			if err := os.MkdirAll(configDir, 0o755); err != nil {
 				fmt.Fprintf(os.Stderr, "Error creation config directory: %s\n", err)
-				os.Exit(1)
+				osExit(1)
+				return
 			}
 		}
 
@@ -102,7 +192,19 @@ func initConfig() {
 		}
 	}
 
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Fprintf(os.Stdout, "Config file: %s\n", viper.ConfigFileUsed())
+	configErr := viper.ReadInConfig()
+
+	if err := checkOutputFormat(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		osExit(1)
+		return
+	}
+	if err := setupLogging(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up logging: %s\n", err)
+		osExit(1)
+		return
+	}
+	if configErr == nil {
+		slog.Info(fmt.Sprintf("Config file: %s", viper.ConfigFileUsed()), "path", viper.ConfigFileUsed())
 	}
 }
@@ -232,3 +232,30 @@ cover_height: 300
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+func TestInitConfigInvalidLogFormat(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_log_format_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	customConfigPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(customConfigPath, []byte("log_format: xml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfgFile = customConfigPath
+	defer func() { cfgFile = "" }()
+
+	// the process isn't exited in tests, initConfig stops at the error
+	exitCode := 0
+	oldOsExit := osExit
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = oldOsExit }()
+
+	viper.Reset()
+	initConfig()
+	if exitCode != 1 {
+		t.Errorf("initConfig() exit code = %d, want 1", exitCode)
+	}
+}
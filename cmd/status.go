@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/disk"
+	"github.com/nerten/albumpicker/pkg/manifest"
+	"github.com/nerten/albumpicker/pkg/processor"
+)
+
+// Status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show albums in the destination directory",
+	Args:  cobra.NoArgs,
+	RunE:  runStatusCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	// local flags
+	statusCmd.Flags().Bool("checksums", false, "compare checksums of all files with the manifest, it reads every file")
+}
+
+// statusResult is the JSON document of the status command
+type statusResult struct {
+	Destination string        `json:"destination"`
+	Albums      []statusAlbum `json:"albums"`
+	// Foreign are albums in the destination directory which albumpicker didn't copy
+	Foreign []string `json:"foreign"`
+	Bytes   int64    `json:"bytes"`
+	// FreeBytes is omitted when the free space is unknown
+	FreeBytes *int64 `json:"free_bytes,omitempty"`
+	resultError
+}
+
+// statusAlbum is an album recorded in the destination manifest
+type statusAlbum struct {
+	Album    string    `json:"album"`
+	Source   string    `json:"source"`
+	Files    int       `json:"files"`
+	Bytes    int64     `json:"bytes"`
+	CopiedAt time.Time `json:"copied_at"`
+	Broken   bool      `json:"broken,omitempty"`
+	Problems []string  `json:"problems,omitempty"`
+}
+
+// runStatusCommand executes the status command
+func runStatusCommand(cmd *cobra.Command, _ []string) error {
+	result := &statusResult{Albums: []statusAlbum{}, Foreign: []string{}}
+	if err := destinationStatus(cmd, result); err != nil {
+		return writeResult(result, err)
+	}
+	if !jsonOutput() {
+		printStatus(result)
+	}
+
+	var err error
+	if broken := result.broken(); broken > 0 {
+		err = fmt.Errorf("%d albums are broken", broken)
+	}
+	return writeResult(result, err)
+}
+
+// printStatus prints albums in the destination directory
+func printStatus(result *statusResult) {
+	fmt.Printf("Destination directory: %s\n", result.Destination)
+	for _, album := range result.Albums {
+		if album.Broken {
+			fmt.Printf("  %s: broken\n", album.Album)
+			for _, problem := range album.Problems {
+				fmt.Printf("    %s\n", problem)
+			}
+			continue
+		}
+		fmt.Printf("  %s: %d files, %s, copied %s\n", album.Album, album.Files,
+			config.FormatSize(album.Bytes), album.CopiedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	for _, relPath := range result.Foreign {
+		fmt.Printf("  %s: not copied by albumpicker\n", relPath)
+	}
+
+	fmt.Printf("%d albums copied by albumpicker, %s in total\n", len(result.Albums), config.FormatSize(result.Bytes))
+	if result.FreeBytes != nil {
+		fmt.Printf("Free space in destination directory: %s\n", config.FormatSize(*result.FreeBytes))
+	}
+}
+
+// broken returns the number of broken albums
+func (r *statusResult) broken() int {
+	var broken int
+	for _, album := range r.Albums {
+		if album.Broken {
+			broken++
+		}
+	}
+	return broken
+}
+
+// destinationStatus checks albums in the destination directory and records them in the result
+func destinationStatus(cmd *cobra.Command, result *statusResult) error {
+	// load configuration
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	result.Destination = conf.Destination
+
+	m, err := manifest.Load(conf.Destination)
+	if err != nil {
+		return err
+	}
+	checksums, _ := cmd.Flags().GetBool("checksums")
+
+	relPaths := make([]string, 0, len(m.Albums))
+	for relPath := range m.Albums {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	for _, relPath := range relPaths {
+		album := m.Albums[relPath]
+		status := statusAlbum{
+			Album:    relPath,
+			Source:   album.Source,
+			Files:    len(album.Files),
+			Bytes:    album.Size(),
+			CopiedAt: album.CopiedAt,
+		}
+		for _, err := range album.Check(filepath.Join(conf.Destination, filepath.FromSlash(relPath)), checksums) {
+			status.Broken = true
+			status.Problems = append(status.Problems, err.Error())
+		}
+		result.Albums = append(result.Albums, status)
+		result.Bytes += status.Bytes
+	}
+
+	// albums in the destination directory which albumpicker didn't copy, there are none before the first copy.
+	// Interrupted first copies are found by their markers, so transcoded albums the scan doesn't recognise are found too
+	var destAlbums, incomplete []string
+	if _, err := os.Stat(conf.Destination); err == nil {
+		destAlbums, err = processor.FindAllAlbums(commandContext(cmd), conf.Destination, conf.AudioExtensions)
+		if err != nil {
+			return fmt.Errorf("error scanning destination directory: %s", err)
+		}
+		incomplete, err = manifest.FindIncomplete(conf.Destination)
+		if err != nil {
+			return fmt.Errorf("error scanning destination directory: %s", err)
+		}
+	}
+	for _, destAlbumPath := range incomplete {
+		relPath, err := filepath.Rel(conf.Destination, destAlbumPath)
+		if err != nil {
+			continue
+		}
+		// the first copy of the album was interrupted before it was recorded
+		if _, ok := m.Get(relPath); !ok {
+			result.Albums = append(result.Albums, statusAlbum{Album: filepath.ToSlash(relPath), Broken: true,
+				Problems: []string{"copying was interrupted"}})
+		}
+	}
+	for _, destAlbumPath := range destAlbums {
+		relPath, err := filepath.Rel(conf.Destination, destAlbumPath)
+		if err != nil {
+			continue
+		}
+		if _, ok := m.Get(relPath); ok || manifest.IsIncomplete(destAlbumPath) {
+			continue
+		}
+		result.Foreign = append(result.Foreign, filepath.ToSlash(relPath))
+	}
+
+	if free, err := disk.Free(conf.Destination); err == nil {
+		result.FreeBytes = &free
+	}
+	return nil
+}
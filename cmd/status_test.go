@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nerten/albumpicker/pkg/manifest"
+)
+
+func TestRunStatusCommand(t *testing.T) {
+	// get project root directory
+	projectRoot, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatalf("Failed to get project root: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "albumpicker_status_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// copy an album, so the manifest is written
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	albumDir := filepath.Join(sourceDir, "test-album")
+	if err := os.MkdirAll(albumDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(projectRoot, "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(albumDir, "01 - test.flac"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	viper.Set("source", sourceDir)
+	viper.Set("destination", destDir)
+	viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+	if err := runCopyCommand(&cobra.Command{}, []string{albumDir}); err != nil {
+		t.Fatalf("runCopyCommand() error = %v", err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("checksums", false, "checksums flag for testing")
+	cmd.Flags().Set("checksums", "true")
+	if err := runStatusCommand(cmd, nil); err != nil {
+		t.Errorf("runStatusCommand() error = %v", err)
+	}
+
+	// a truncated file makes the album broken
+	if err := os.Truncate(filepath.Join(destDir, "test-album", "01 - test.flac"), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := runStatusCommand(cmd, nil); err == nil {
+		t.Error("runStatusCommand() expected error for broken album")
+	}
+
+	// so does an interrupted first copy, which isn't recorded in the manifest yet
+	if err := os.Remove(filepath.Join(destDir, manifest.FileName)); err != nil {
+		t.Fatal(err)
+	}
+	if err := runStatusCommand(cmd, nil); err != nil {
+		t.Errorf("runStatusCommand() error = %v for an album not copied by albumpicker", err)
+	}
+	if err := manifest.MarkIncomplete(filepath.Join(destDir, "test-album")); err != nil {
+		t.Fatal(err)
+	}
+	if err := runStatusCommand(cmd, nil); err == nil {
+		t.Error("runStatusCommand() expected error for interrupted album")
+	}
+
+	// an interrupted transcoded album is found by its marker, its files aren't recognised by the scan
+	transcodedDir := filepath.Join(destDir, "transcoded-album")
+	if err := os.MkdirAll(transcodedDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(transcodedDir, "01 - test.m4a"), []byte("test aac data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := manifest.MarkIncomplete(transcodedDir); err != nil {
+		t.Fatal(err)
+	}
+	var result statusResult
+	if err := destinationStatus(cmd, &result); err != nil {
+		t.Fatalf("destinationStatus() error = %v", err)
+	}
+	interrupted := map[string]bool{}
+	for _, album := range result.Albums {
+		interrupted[album.Album] = album.Broken
+	}
+	if !interrupted["test-album"] || !interrupted["transcoded-album"] || len(result.Foreign) != 0 {
+		t.Errorf("destinationStatus() albums = %+v, foreign = %v, want both albums interrupted", result.Albums, result.Foreign)
+	}
+}
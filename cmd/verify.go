@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/flacstream"
+	"github.com/nerten/albumpicker/pkg/library"
+)
+
+// Verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify [path]",
+	Short: "Check FLAC files in the destination directory against their STREAMINFO MD5",
+	Long: `Decode audio of every FLAC file in the destination directory, or in the given path,
+and compare it with the MD5 checksum stored in STREAMINFO to find corrupted copies.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runVerifyCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// addVerifyFlag adds the flag enabling verification of written files to the command
+func addVerifyFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("verify", false, "decode written FLAC files and compare them with their STREAMINFO MD5")
+}
+
+// applyVerifyFlag overrides the verify config option with the flag of the command
+func applyVerifyFlag(cmd *cobra.Command, conf *config.Config) {
+	if cmd.Flags().Changed("verify") {
+		conf.Verify, _ = cmd.Flags().GetBool("verify")
+	}
+}
+
+// verifyResult is the JSON document of the verify command
+type verifyResult struct {
+	Files int `json:"files"`
+	// Corrupted are files whose audio doesn't match the checksum, Unverified are files without checksum
+	Corrupted  []verifyProblem `json:"corrupted"`
+	Unverified []verifyProblem `json:"unverified"`
+	resultError
+}
+
+// verifyProblem is a FLAC file which failed verification
+type verifyProblem struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// runVerifyCommand executes the verify command
+func runVerifyCommand(cmd *cobra.Command, args []string) error {
+	result := &verifyResult{Corrupted: []verifyProblem{}, Unverified: []verifyProblem{}}
+	err := verifyFiles(cmd, args, result)
+	if err == nil && len(result.Corrupted) > 0 {
+		err = fmt.Errorf("%d FLAC files are corrupted", len(result.Corrupted))
+	}
+	return writeResult(result, err)
+}
+
+// verifyFiles verifies FLAC files in the path and records them in the result
+func verifyFiles(cmd *cobra.Command, args []string, result *verifyResult) error {
+	// extensions are read without the rest of the configuration, which isn't needed for a given path
+	extensions, err := config.ParseExtensions(viper.GetStringSlice("audio_extensions"))
+	if err != nil {
+		return fmt.Errorf("invalid audio_extensions: %s", err)
+	}
+
+	var root string
+	if len(args) > 0 {
+		root = args[0]
+	} else {
+		// load configuration
+		conf, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
+		root = conf.Destination
+	}
+
+	ctx := commandContext(cmd)
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !library.IsAudioFile(entry, extensions) || !audio.IsFLAC(path) {
+			return nil
+		}
+
+		result.Files++
+		relPath, _ := filepath.Rel(root, path)
+		err = flacstream.Verify(path)
+		switch {
+		case errors.Is(err, flacstream.ErrNoChecksum):
+			result.Unverified = append(result.Unverified, verifyProblem{File: relPath, Error: err.Error()})
+			if !jsonOutput() {
+				fmt.Printf("  %s: can't be verified, %s\n", relPath, err)
+			}
+		case err != nil:
+			result.Corrupted = append(result.Corrupted, verifyProblem{File: relPath, Error: err.Error()})
+			if !jsonOutput() {
+				fmt.Printf("  %s: corrupted, %s\n", relPath, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error scanning %s: %s", root, err)
+	}
+
+	slog.Info(fmt.Sprintf("Verified %d FLAC files", result.Files), "files", result.Files)
+	return nil
+}
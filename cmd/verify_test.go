@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestRunVerifyCommand(t *testing.T) {
+	// get project root directory
+	projectRoot, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatalf("Failed to get project root: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "albumpicker_verify_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	albumDir := filepath.Join(sourceDir, "test-album")
+	if err := os.MkdirAll(albumDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(projectRoot, "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(albumDir, "01 - test.flac"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// copy the album verifying written files
+	viper.Reset()
+	viper.Set("source", sourceDir)
+	viper.Set("destination", destDir)
+	viper.Set("index_file", filepath.Join(tmpDir, "index.json"))
+	copyCmd := &cobra.Command{}
+	addVerifyFlag(copyCmd)
+	copyCmd.Flags().Set("verify", "true")
+	if err := runCopyCommand(copyCmd, []string{albumDir}); err != nil {
+		t.Fatalf("runCopyCommand() error = %v", err)
+	}
+
+	if err := runVerifyCommand(&cobra.Command{}, nil); err != nil {
+		t.Errorf("runVerifyCommand() error = %v", err)
+	}
+
+	// flip a bit of the last audio frame
+	destFile := filepath.Join(destDir, "test-album", "01 - test.flac")
+	copied, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied[len(copied)-5] ^= 0x40
+	if err := os.WriteFile(destFile, copied, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runVerifyCommand(&cobra.Command{}, nil); err == nil {
+		t.Error("runVerifyCommand() expected error for corrupted file")
+	}
+	if err := runVerifyCommand(&cobra.Command{}, []string{filepath.Join(destDir, "test-album")}); err == nil {
+		t.Error("runVerifyCommand() expected error for corrupted file in the given path")
+	}
+}
@@ -2,7 +2,10 @@ module github.com/nerten/albumpicker
 
 go 1.24.1
 
-require github.com/spf13/cobra v1.9.1
+require (
+	github.com/go-flac/flacvorbis v0.2.0
+	github.com/spf13/cobra v1.9.1
+)
 
 require (
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
@@ -15,7 +18,6 @@ require (
 	github.com/subosito/gotenv v1.6.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/image v0.26.0 // indirect
-	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -26,4 +28,5 @@ require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 	github.com/spf13/viper v1.20.1
+	golang.org/x/sys v0.32.0
 )
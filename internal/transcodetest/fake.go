@@ -0,0 +1,43 @@
+package transcodetest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/transcode"
+)
+
+// Fake is an encoder for tests, it writes a text file describing the profile and the tags
+// instead of audio
+type Fake struct {
+	profile transcode.Profile
+	mu      sync.Mutex
+	// Sources are the encoded files in the order of encoding
+	Sources []string
+}
+
+// NewFake creates the fake encoder
+func NewFake(profile transcode.Profile) *Fake {
+	return &Fake{profile: profile}
+}
+
+// Encode writes the description of the transcoded file to dest
+func (e *Fake) Encode(ctx context.Context, source, dest string, tags []audio.Tag) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.Sources = append(e.Sources, source)
+	e.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "fake %s %dk\n", e.profile.Format.Name, e.profile.Bitrate)
+	for _, field := range tags {
+		fmt.Fprintf(&b, "%s=%s\n", field.Name, field.Value)
+	}
+	return os.WriteFile(dest, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,43 @@
+package transcodetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/transcode"
+)
+
+func TestFake(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_transcodetest_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	mp3, _ := transcode.NewProfile("mp3", 0)
+	fake := NewFake(mp3)
+	dest := filepath.Join(tmpDir, "out.mp3")
+	if err := fake.Encode(context.Background(), "in.flac", dest, []audio.Tag{{Name: "ALBUMARTIST", Value: "Artist"}}); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "fake mp3 256k\nALBUMARTIST=Artist\n"; string(data) != want {
+		t.Errorf("Encode() wrote %q, want %q", data, want)
+	}
+	if !slices.Equal(fake.Sources, []string{"in.flac"}) {
+		t.Errorf("Sources = %v", fake.Sources)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := fake.Encode(ctx, "in.flac", dest, nil); err == nil {
+		t.Error("Encode() with cancelled context returned no error")
+	}
+}
@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-flac/go-flac"
+)
+
+// Tag is a single tag field, names are upper-cased Vorbis comment names
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Metadata is the information read from tags and stream headers of an audio file
+type Metadata struct {
+	// Tags are fields in their original order, repeated fields hold multiple values
+	Tags []Tag
+	// Stripped is the number of bytes StripArt removes from the file
+	Stripped int64
+	// Duration is the length of audio, zero when it's unknown
+	Duration time.Duration
+	// SampleRate and BitsPerSample are the audio format, BitsPerSample is zero for lossy formats
+	SampleRate    int
+	BitsPerSample int
+	// FrontCover is set when the file embeds a front cover picture
+	FrontCover bool
+	// FrontCoverSize is the size of the first front cover block with its header and FrontCoverHeight
+	// is the height of its image, which is zero when the image can't be decoded
+	FrontCoverSize   int64
+	FrontCoverHeight int
+	// Application, CueSheet and SeekTable are sizes of these FLAC metadata blocks with their headers
+	Application int64
+	CueSheet    int64
+	SeekTable   int64
+}
+
+// StripOptions select metadata kept by StripArt besides what it always keeps, the zero value
+// removes all embedded pictures and padding. Only FLAC files follow the options
+type StripOptions struct {
+	// FrontCover keeps the first front cover, it's passed to ResizeCover when it's set
+	// and removed when ResizeCover fails
+	FrontCover  bool
+	ResizeCover func(picture *Picture) error
+	// DropApplication, DropCueSheet and DropSeekTable remove these metadata blocks
+	DropApplication bool
+	DropCueSheet    bool
+	DropSeekTable   bool
+	// Padding is the size of the PADDING block added as the last block, zero adds none
+	Padding int64
+	// Transform is called with the file once its blocks are selected, it may rewrite audio frames.
+	// Its error stops StripArt
+	Transform func(file *flac.File) error
+}
+
+// Handler reads and rewrites files of a single audio format
+type Handler interface {
+	// Name returns the name of the format
+	Name() string
+	// Extensions returns lower-case file name extensions of the format
+	Extensions() []string
+	// Detect checks if the beginning of a file is of the format
+	Detect(head []byte) bool
+	// StripArt returns the content of a file without embedded pictures and padding, metadata
+	// is kept, dropped or added as selected by opts
+	StripArt(data []byte, opts StripOptions) ([]byte, error)
+	// ReadMetadata reads tags and stream properties of the file without reading all of its audio
+	ReadMetadata(path string) (*Metadata, error)
+}
+
+// handlers are all supported formats
+var handlers = []Handler{FLAC{}, MP3{}, Ogg{}}
+
+// detectSize is the number of bytes read to recognise files with ambiguous extensions
+const detectSize = 16
+
+// Handlers returns handlers of all supported formats
+func Handlers() []Handler {
+	return handlers
+}
+
+// DefaultExtensions returns extensions of all supported formats
+func DefaultExtensions() []string {
+	var extensions []string
+	for _, h := range handlers {
+		extensions = append(extensions, h.Extensions()...)
+	}
+	return extensions
+}
+
+// HasExtension checks if the file name has one of the extensions, case-insensitively,
+// nil extensions are the default ones
+func HasExtension(name string, extensions []string) bool {
+	if extensions == nil {
+		extensions = DefaultExtensions()
+	}
+	ext := filepath.Ext(name)
+	for _, e := range extensions {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForName returns the handler of the file by its extension, case-insensitively,
+// it's nil for extensions of no supported format
+func ForName(name string) Handler {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, h := range handlers {
+		if slices.Contains(h.Extensions(), ext) {
+			return h
+		}
+	}
+	return nil
+}
+
+// Detect returns the handler of the file by its extension, files with an ambiguous extension,
+// which belongs to no supported format, are recognised by their first bytes
+func Detect(path string) (Handler, error) {
+	if h := ForName(path); h != nil {
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, detectSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("unsupported audio file: %s", filepath.Base(path))
+	}
+	for _, h := range handlers {
+		if h.Detect(head[:n]) {
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported audio file: %s", filepath.Base(path))
+}
+
+// IsFLAC checks if the file is a FLAC file by its extension or content
+func IsFLAC(path string) bool {
+	h, err := Detect(path)
+	if err != nil {
+		return false
+	}
+	_, ok := h.(FLAC)
+	return ok
+}
+
+// ReadMetadata reads metadata of the file with the handler of its format
+func ReadMetadata(path string) (*Metadata, error) {
+	h, err := Detect(path)
+	if err != nil {
+		return nil, err
+	}
+	return h.ReadMetadata(path)
+}
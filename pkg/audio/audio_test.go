@@ -0,0 +1,88 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestForName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"01 - track.flac", "flac"},
+		{"01 - TRACK.FLAC", "flac"},
+		{"01 - track.mp3", "mp3"},
+		{"01 - track.ogg", "ogg"},
+		{"01 - track.opus", "ogg"},
+		{"01 - track.m4a", ""},
+		{"01 - track.wv", ""},
+		{"cover.jpg", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if h := ForName(tt.name); h != nil {
+				got = h.Name()
+			}
+			if got != tt.want {
+				t.Errorf("ForName(%s) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	if !IsFLAC("01 - track.flac") || IsFLAC("01 - track.mp3") {
+		t.Error("IsFLAC() doesn't match FLAC files only")
+	}
+	if _, err := ReadMetadata("01 - track.m4a"); err == nil {
+		t.Error("ReadMetadata() expected error for an unsupported file")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_detect_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		// the extension decides for supported formats
+		{"track.flac", "test flac data", "flac", false},
+		{"track.Mp3", "", "mp3", false},
+		// ambiguous extensions are recognised by content
+		{"track.fla", "fLaC\x00\x00\x00\x22", "flac", false},
+		{"track.audio", "ID3\x04\x00\x00\x00\x00\x00\x00", "mp3", false},
+		{"track.audio2", "OggS\x00\x02", "ogg", false},
+		{"notes.fla", "test notes", "", true},
+		{"empty.fla", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			h, err := Detect(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Detect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && h.Name() != tt.want {
+				t.Errorf("Detect() = %s, want %s", h.Name(), tt.want)
+			}
+			if got := IsFLAC(path); got != (tt.want == "flac") {
+				t.Errorf("IsFLAC() = %v", got)
+			}
+		})
+	}
+
+	if !HasExtension("TRACK.FLAC", nil) || HasExtension("track.fla", nil) || !HasExtension("track.FLA", []string{".fla"}) {
+		t.Error("HasExtension() doesn't match extensions case-insensitively")
+	}
+}
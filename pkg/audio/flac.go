@@ -0,0 +1,262 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-flac/flacvorbis"
+	"github.com/go-flac/go-flac"
+)
+
+// FLAC metadata block types, see https://xiph.org/flac/format.html#metadata_block_header
+const (
+	blockStreamInfo    = 0
+	blockPadding       = 1
+	blockApplication   = 2
+	blockSeekTable     = 3
+	blockVorbisComment = 4
+	blockCueSheet      = 5
+	blockPicture       = 6
+)
+
+// FLACFormat is the name of the FLAC format
+const FLACFormat = "flac"
+
+// FLAC handles FLAC files, PICTURE and PADDING blocks are removed from them
+type FLAC struct{}
+
+// Name returns the name of the format
+func (FLAC) Name() string {
+	return FLACFormat
+}
+
+// Extensions returns file name extensions of the format
+func (FLAC) Extensions() []string {
+	return []string{".flac"}
+}
+
+// Detect checks if the file starts with the FLAC stream marker
+func (FLAC) Detect(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("fLaC"))
+}
+
+// StripArt removes all PICTURE and PADDING blocks and the blocks dropped by opts. The first front cover
+// is kept when opts.FrontCover is set and PADDING of opts.Padding is added as the last block
+func (FLAC) StripArt(data []byte, opts StripOptions) ([]byte, error) {
+	file, err := flac.ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing FLAC file: %s", err)
+	}
+
+	var newMetadata []*flac.MetaDataBlock
+	frontCover := false
+	for _, block := range file.Meta {
+		switch {
+		case block.Type == flac.Padding:
+			continue
+		case block.Type == flac.Picture:
+			if !opts.FrontCover || frontCover {
+				continue
+			}
+			picture, err := ParsePicture(block.Data)
+			if err != nil || picture.Type != PictureFrontCover {
+				continue
+			}
+			if opts.ResizeCover != nil {
+				if err := opts.ResizeCover(picture); err != nil {
+					continue
+				}
+			}
+			block = &flac.MetaDataBlock{Type: flac.Picture, Data: picture.Marshal()}
+			frontCover = true
+		case block.Type == flac.Application && opts.DropApplication:
+			continue
+		case block.Type == flac.CueSheet && opts.DropCueSheet:
+			continue
+		case block.Type == flac.SeekTable && opts.DropSeekTable:
+			continue
+		}
+		newMetadata = append(newMetadata, block)
+	}
+	if opts.Padding > 0 {
+		newMetadata = append(newMetadata, &flac.MetaDataBlock{Type: flac.Padding, Data: make([]byte, opts.Padding)})
+	}
+	file.Meta = newMetadata
+
+	if opts.Transform != nil {
+		if err := opts.Transform(file); err != nil {
+			return nil, err
+		}
+	}
+	return file.Marshal(), nil
+}
+
+// ReadMetadata reads metadata blocks of the FLAC file without reading audio frames
+func (FLAC) ReadMetadata(path string) (*Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(f, head); err != nil {
+		return nil, err
+	}
+	if string(head) != "fLaC" {
+		return nil, fmt.Errorf("not a FLAC file")
+	}
+
+	meta := &Metadata{}
+	header := make([]byte, 4)
+	offset := int64(len(head))
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			return nil, err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		length := int64(binary.BigEndian.Uint32(header) & 0xffffff)
+		offset += 4 + length
+
+		switch blockType {
+		case blockStreamInfo:
+			data := make([]byte, length)
+			if _, err := io.ReadFull(f, data); err != nil {
+				return nil, err
+			}
+			meta.Duration = streamDuration(data)
+			meta.SampleRate, meta.BitsPerSample = streamFormat(data)
+		case blockPicture:
+			meta.Stripped += 4 + length
+			if length < 4 {
+				break
+			}
+			// only the picture type and the image header of the first front cover are read
+			pictureType, height, err := readPictureHeader(io.LimitReader(f, length), !meta.FrontCover)
+			if err != nil {
+				return nil, err
+			}
+			if pictureType == PictureFrontCover && !meta.FrontCover {
+				meta.FrontCover = true
+				meta.FrontCoverSize = 4 + length
+				meta.FrontCoverHeight = height
+			}
+		case blockPadding:
+			meta.Stripped += 4 + length
+		case blockApplication:
+			meta.Application += 4 + length
+		case blockSeekTable:
+			meta.SeekTable += 4 + length
+		case blockVorbisComment:
+			data := make([]byte, length)
+			if _, err := io.ReadFull(f, data); err != nil {
+				return nil, err
+			}
+			tags, err := parseVorbisComment(data)
+			if err != nil {
+				return nil, err
+			}
+			meta.Tags = append(meta.Tags, tags...)
+		case blockCueSheet:
+			meta.CueSheet += 4 + length
+		}
+		// skip the rest of block data, so large pictures are never read
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		if last {
+			return meta, nil
+		}
+	}
+}
+
+// readPictureHeader reads the picture type of PICTURE block data, the height of a front cover image
+// is read from its header when frontCover is set. The height is zero when the image can't be decoded
+func readPictureHeader(r io.Reader, frontCover bool) (uint32, int, error) {
+	field := make([]byte, 4)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return 0, 0, err
+	}
+	pictureType := binary.BigEndian.Uint32(field)
+	if !frontCover || pictureType != PictureFrontCover {
+		return pictureType, 0, nil
+	}
+
+	br := bufio.NewReader(r)
+	// skip MIME type and description, then width, height, depth and colors
+	for range 2 {
+		if _, err := io.ReadFull(br, field); err != nil {
+			return pictureType, 0, nil
+		}
+		if _, err := br.Discard(int(binary.BigEndian.Uint32(field))); err != nil {
+			return pictureType, 0, nil
+		}
+	}
+	if _, err := br.Discard(4 * 4); err != nil {
+		return pictureType, 0, nil
+	}
+	if _, err := io.ReadFull(br, field); err != nil {
+		return pictureType, 0, nil
+	}
+	config, _, err := image.DecodeConfig(io.LimitReader(br, int64(binary.BigEndian.Uint32(field))))
+	if err != nil {
+		return pictureType, 0, nil
+	}
+	return pictureType, config.Height, nil
+}
+
+// streamDuration returns the length of audio described by STREAMINFO block data
+func streamDuration(data []byte) time.Duration {
+	if len(data) < 18 {
+		return 0
+	}
+	// 20 bits of sample rate, 3 bits of channels, 5 bits of bits per sample and 36 bits of samples
+	sampleRate := uint64(data[10])<<12 | uint64(data[11])<<4 | uint64(data[12])>>4
+	samples := uint64(data[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(data[14:18]))
+	if sampleRate == 0 {
+		return 0
+	}
+	return time.Duration(samples * uint64(time.Second) / sampleRate)
+}
+
+// streamFormat returns the sample rate and bits per sample of audio described by STREAMINFO block data
+func streamFormat(data []byte) (int, int) {
+	if len(data) < 14 {
+		return 0, 0
+	}
+	sampleRate := int(data[10])<<12 | int(data[11])<<4 | int(data[12])>>4
+	bitsPerSample := (int(data[12]&0x01)<<4 | int(data[13])>>4) + 1
+	return sampleRate, bitsPerSample
+}
+
+// parseVorbisComment parses VORBIS_COMMENT block data
+func parseVorbisComment(data []byte) ([]Tag, error) {
+	comment, err := flacvorbis.ParseFromMetaDataBlock(flac.MetaDataBlock{Type: flac.VorbisComment, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Vorbis comment: %s", err)
+	}
+	return commentTags(comment.Comments), nil
+}
+
+// commentTags converts "NAME=value" fields of a Vorbis comment to tags
+func commentTags(comments []string) []Tag {
+	var tags []Tag
+	for _, field := range comments {
+		if name, value, ok := strings.Cut(field, "="); ok {
+			tags = append(tags, Tag{Name: strings.ToUpper(name), Value: value})
+		}
+	}
+	return tags
+}
@@ -0,0 +1,206 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-flac/go-flac"
+)
+
+func TestFLACReadMetadata(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_flac_metadata_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// test FLAC file has a 9151 bytes PICTURE and a 66179 bytes PADDING block
+	meta, err := FLAC{}.ReadMetadata(filepath.Join("..", "..", "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	if want := int64(4 + 9151 + 4 + 66179); meta.Stripped != want {
+		t.Errorf("ReadMetadata() stripped = %d, want %d", meta.Stripped, want)
+	}
+	// 44100 samples at 44.1 kHz
+	if meta.Duration != time.Second {
+		t.Errorf("ReadMetadata() duration = %v, want 1s", meta.Duration)
+	}
+	if meta.SampleRate != 44100 || meta.BitsPerSample != 16 {
+		t.Errorf("ReadMetadata() format = %d Hz %d bits, want 44100 Hz 16 bits", meta.SampleRate, meta.BitsPerSample)
+	}
+	wantTags := map[string]string{
+		"ALBUMARTIST": "Test album artist",
+		"GENRE":       "Test Disc",
+		"DATE":        "2024",
+	}
+	for name, want := range wantTags {
+		if got := tagValues(meta.Tags, name); len(got) != 1 || got[0] != want {
+			t.Errorf("ReadMetadata() tag %s = %v, want %s", name, got, want)
+		}
+	}
+
+	// broken files
+	notFlac := filepath.Join(tmpDir, "not.flac")
+	if err := os.WriteFile(notFlac, []byte("test flac data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(tmpDir, "truncated.flac")
+	if err := os.WriteFile(truncated, []byte("fLaC\x00\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{notFlac, truncated, filepath.Join(tmpDir, "missing.flac")} {
+		if _, err := (FLAC{}).ReadMetadata(path); err == nil {
+			t.Errorf("ReadMetadata(%s) expected error", filepath.Base(path))
+		}
+	}
+}
+
+func TestFLACStripArt(t *testing.T) {
+	path := filepath.Join("..", "..", "test_data", "01 - test.flac")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(FLAC{}).Detect(data) {
+		t.Fatal("Detect() = false for a FLAC file")
+	}
+
+	stripped, err := FLAC{}.StripArt(data, StripOptions{})
+	if err != nil {
+		t.Fatalf("StripArt() error = %v", err)
+	}
+	meta, err := FLAC{}.ReadMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)-len(stripped)) != meta.Stripped {
+		t.Errorf("StripArt() removed %d bytes, ReadMetadata() reported %d", len(data)-len(stripped), meta.Stripped)
+	}
+
+	if _, err := (FLAC{}).StripArt([]byte("test flac data"), StripOptions{}); err == nil {
+		t.Error("StripArt() expected error for a broken file")
+	}
+}
+
+func TestFLACStripOptions(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_flac_options_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := flac.ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+	var vorbis *flac.MetaDataBlock
+	for _, block := range file.Meta {
+		if block.Type == flac.VorbisComment {
+			vorbis = block
+		}
+	}
+	cover := &Picture{Type: PictureFrontCover, MIME: "image/png", Width: 200, Height: 100, Depth: 32, Data: buf.Bytes()}
+	back := &Picture{Type: 4, MIME: "image/png", Data: buf.Bytes()}
+	file.Meta = []*flac.MetaDataBlock{
+		file.Meta[0],
+		{Type: flac.SeekTable, Data: make([]byte, 18)},
+		{Type: flac.Application, Data: []byte("test application data")},
+		{Type: flac.CueSheet, Data: make([]byte, 432)},
+		vorbis,
+		{Type: flac.Picture, Data: back.Marshal()},
+		{Type: flac.Picture, Data: cover.Marshal()},
+		{Type: flac.Padding, Data: make([]byte, 4096)},
+	}
+	data = file.Marshal()
+	path := filepath.Join(tmpDir, "01 - test.flac")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := FLAC{}.ReadMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !meta.FrontCover || meta.FrontCoverSize != int64(4+len(cover.Marshal())) || meta.FrontCoverHeight != 100 {
+		t.Errorf("ReadMetadata() front cover = %v, %d bytes, height %d, want %d bytes, height 100",
+			meta.FrontCover, meta.FrontCoverSize, meta.FrontCoverHeight, 4+len(cover.Marshal()))
+	}
+	if meta.SeekTable != 4+18 || meta.Application != 4+21 || meta.CueSheet != 4+432 {
+		t.Errorf("ReadMetadata() seektable, application, cuesheet = %d, %d, %d, want 22, 25, 436",
+			meta.SeekTable, meta.Application, meta.CueSheet)
+	}
+
+	var resized, transformed bool
+	opts := StripOptions{
+		FrontCover: true,
+		ResizeCover: func(picture *Picture) error {
+			resized = picture.Type == PictureFrontCover
+			return nil
+		},
+		DropApplication: true,
+		DropCueSheet:    true,
+		DropSeekTable:   true,
+		Padding:         1024,
+		Transform: func(file *flac.File) error {
+			transformed = len(file.Meta) == 4
+			return nil
+		},
+	}
+	stripped, err := FLAC{}.StripArt(data, opts)
+	if err != nil {
+		t.Fatalf("StripArt() error = %v", err)
+	}
+	if !resized || !transformed {
+		t.Errorf("StripArt() resized the cover = %v, transformed the file = %v, want both", resized, transformed)
+	}
+	file, err = flac.ParseBytes(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []flac.BlockType
+	for _, block := range file.Meta {
+		types = append(types, block.Type)
+	}
+	want := []flac.BlockType{flac.StreamInfo, flac.VorbisComment, flac.Picture, flac.Padding}
+	if !slices.Equal(types, want) {
+		t.Errorf("StripArt() blocks = %v, want %v", types, want)
+	}
+
+	// covers failing to resize are removed and transform errors stop StripArt
+	opts.ResizeCover = func(*Picture) error { return errors.New("test error") }
+	opts.Transform = nil
+	if stripped, err := (FLAC{}).StripArt(data, opts); err != nil || bytes.Contains(stripped, cover.Data) {
+		t.Errorf("StripArt() kept a cover which failed to resize, error = %v", err)
+	}
+	opts.Transform = func(*flac.File) error { return errors.New("test error") }
+	if _, err := (FLAC{}).StripArt(data, opts); err == nil {
+		t.Error("StripArt() expected the error of Transform")
+	}
+}
+
+// tagValues returns values of the tag in their original order
+func tagValues(tags []Tag, name string) []string {
+	var values []string
+	for _, tag := range tags {
+		if tag.Name == name {
+			values = append(values, tag.Value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,309 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// ID3v2 header flags, see https://id3.org/id3v2.4.0-structure
+const (
+	id3Unsync         = 0x80
+	id3ExtendedHeader = 0x40
+	id3Footer         = 0x10
+)
+
+// id3Tag is a parsed ID3v2 tag
+type id3Tag struct {
+	major byte
+	flags byte
+	// size is the size of the whole tag including its header, footer and padding
+	size   int
+	frames []id3Frame
+}
+
+// id3Frame is a single frame of the tag, data is stored as it's found in the tag
+type id3Frame struct {
+	id    string
+	flags [2]byte
+	data  []byte
+}
+
+// id3Size returns the size of the ID3v2 tag at the beginning of data from its header,
+// which is zero when data doesn't start with a tag
+func id3Size(header []byte) int {
+	if len(header) < 10 || string(header[:3]) != "ID3" {
+		return 0
+	}
+	size := 10 + syncsafe(header[6:10])
+	if header[3] >= 4 && header[5]&id3Footer != 0 {
+		size += 10
+	}
+	return size
+}
+
+// parseID3 parses the ID3v2 tag at the beginning of data
+func parseID3(data []byte) (*id3Tag, error) {
+	size := id3Size(data)
+	if size == 0 {
+		return nil, fmt.Errorf("no ID3v2 tag")
+	}
+	if size > len(data) {
+		return nil, fmt.Errorf("ID3v2 tag is truncated")
+	}
+	tag := &id3Tag{major: data[3], flags: data[5], size: size}
+	if tag.major < 2 || tag.major > 4 {
+		return nil, fmt.Errorf("unsupported ID3v2.%d tag", tag.major)
+	}
+
+	body := data[10 : 10+syncsafe(data[6:10])]
+	// before ID3v2.4 unsynchronisation applies to the whole tag, later to single frames
+	if tag.flags&id3Unsync != 0 && tag.major < 4 {
+		body = bytes.ReplaceAll(body, []byte{0xff, 0x00}, []byte{0xff})
+	}
+	if tag.flags&id3ExtendedHeader != 0 {
+		switch tag.major {
+		case 2:
+			return nil, fmt.Errorf("compressed ID3v2.2 tags are not supported")
+		case 3:
+			if len(body) < 4 {
+				return nil, fmt.Errorf("ID3v2 extended header is truncated")
+			}
+			body = body[min(4+int(binary.BigEndian.Uint32(body)), len(body)):]
+		case 4:
+			if len(body) < 4 {
+				return nil, fmt.Errorf("ID3v2 extended header is truncated")
+			}
+			body = body[min(syncsafe(body), len(body)):]
+		}
+	}
+
+	headerSize := 10
+	if tag.major == 2 {
+		headerSize = 6
+	}
+	for len(body) >= headerSize && body[0] != 0 {
+		var frame id3Frame
+		var length int
+		switch tag.major {
+		case 2:
+			frame.id = string(body[:3])
+			length = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			frame.id = string(body[:4])
+			length = int(binary.BigEndian.Uint32(body[4:]))
+			frame.flags = [2]byte{body[8], body[9]}
+		case 4:
+			frame.id = string(body[:4])
+			length = syncsafe(body[4:])
+			frame.flags = [2]byte{body[8], body[9]}
+		}
+		if !validFrameID(frame.id) {
+			return nil, fmt.Errorf("invalid ID3v2 frame %q", frame.id)
+		}
+		if length > len(body)-headerSize {
+			return nil, fmt.Errorf("ID3v2 frame %s is truncated", frame.id)
+		}
+		frame.data = body[headerSize : headerSize+length]
+		tag.frames = append(tag.frames, frame)
+		body = body[headerSize+length:]
+	}
+	return tag, nil
+}
+
+// validFrameID checks if the frame ID consists of upper-case letters and digits
+func validFrameID(id string) bool {
+	for _, c := range id {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// marshal encodes the tag without padding, the extended header and the footer,
+// the tag is no longer unsynchronised as a whole
+func (tag *id3Tag) marshal() []byte {
+	var body []byte
+	for _, frame := range tag.frames {
+		switch tag.major {
+		case 2:
+			n := len(frame.data)
+			body = append(body, frame.id...)
+			body = append(body, byte(n>>16), byte(n>>8), byte(n))
+		case 3:
+			body = append(body, frame.id...)
+			body = binary.BigEndian.AppendUint32(body, uint32(len(frame.data)))
+			body = append(body, frame.flags[:]...)
+		case 4:
+			body = append(body, frame.id...)
+			body = appendSyncsafe(body, len(frame.data))
+			body = append(body, frame.flags[:]...)
+		}
+		body = append(body, frame.data...)
+	}
+
+	flags := tag.flags &^ (id3ExtendedHeader | id3Footer)
+	if tag.major < 4 {
+		flags &^= id3Unsync
+	}
+	data := []byte{'I', 'D', '3', tag.major, 0, flags}
+	data = appendSyncsafe(data, len(body))
+	return append(data, body...)
+}
+
+// withoutPictures returns a copy of the tag without APIC frames, PIC in ID3v2.2
+func (tag *id3Tag) withoutPictures() *id3Tag {
+	stripped := *tag
+	stripped.frames = nil
+	for _, frame := range tag.frames {
+		if frame.id != "APIC" && frame.id != "PIC" {
+			stripped.frames = append(stripped.frames, frame)
+		}
+	}
+	return &stripped
+}
+
+// id3TagNames maps text frames to Vorbis comment names, three-letter IDs are ID3v2.2 ones
+var id3TagNames = map[string]string{
+	"TIT2": "TITLE", "TT2": "TITLE",
+	"TPE1": "ARTIST", "TP1": "ARTIST",
+	"TPE2": "ALBUMARTIST", "TP2": "ALBUMARTIST",
+	"TALB": "ALBUM", "TAL": "ALBUM",
+	"TCON": "GENRE", "TCO": "GENRE",
+	"TRCK": "TRACKNUMBER", "TRK": "TRACKNUMBER",
+	"TPOS": "DISCNUMBER", "TPA": "DISCNUMBER",
+	"TDRC": "DATE", "TYER": "DATE", "TYE": "DATE",
+	"TCOM": "COMPOSER", "TCM": "COMPOSER",
+	"TCOP": "COPYRIGHT", "TCR": "COPYRIGHT",
+	"TPUB": "ORGANIZATION", "TPB": "ORGANIZATION",
+}
+
+// totalTagNames are Vorbis comments with the total of "n/total" track and disc numbers
+var totalTagNames = map[string]string{
+	"TRACKNUMBER": "TRACKTOTAL",
+	"DISCNUMBER":  "DISCTOTAL",
+}
+
+// tags converts text, TXXX and COMM frames to Vorbis comments
+func (tag *id3Tag) tags() []Tag {
+	var tags []Tag
+	for _, frame := range tag.frames {
+		data, ok := frame.content(tag.major)
+		if !ok || len(data) == 0 {
+			continue
+		}
+		switch {
+		case frame.id == "TXXX" || frame.id == "TXX":
+			// a description followed by values
+			values := decodeText(data)
+			if len(values) > 1 && values[0] != "" {
+				for _, value := range values[1:] {
+					tags = append(tags, Tag{Name: strings.ToUpper(values[0]), Value: value})
+				}
+			}
+		case frame.id == "COMM" || frame.id == "COM":
+			// a language, a description and the comment, only comments without description are kept
+			if len(data) < 4 {
+				continue
+			}
+			values := decodeText(append([]byte{data[0]}, data[4:]...))
+			if len(values) > 1 && values[0] == "" {
+				tags = append(tags, Tag{Name: "COMMENT", Value: values[1]})
+			}
+		case id3TagNames[frame.id] != "":
+			name := id3TagNames[frame.id]
+			for _, value := range decodeText(data) {
+				number, total, ok := strings.Cut(value, "/")
+				if ok && totalTagNames[name] != "" {
+					tags = append(tags, Tag{Name: name, Value: number}, Tag{Name: totalTagNames[name], Value: total})
+					continue
+				}
+				tags = append(tags, Tag{Name: name, Value: value})
+			}
+		}
+	}
+	return tags
+}
+
+// content returns the frame data without unsynchronisation and prefixes added by frame flags,
+// it's false for compressed and encrypted frames
+func (frame id3Frame) content(major byte) ([]byte, bool) {
+	data := frame.data
+	switch major {
+	case 3:
+		if frame.flags[1]&0xc0 != 0 {
+			return nil, false
+		}
+		if frame.flags[1]&0x20 != 0 && len(data) > 0 {
+			data = data[1:]
+		}
+	case 4:
+		if frame.flags[1]&0x0c != 0 {
+			return nil, false
+		}
+		if frame.flags[1]&0x40 != 0 && len(data) > 0 {
+			data = data[1:]
+		}
+		if frame.flags[1]&0x02 != 0 {
+			data = bytes.ReplaceAll(data, []byte{0xff, 0x00}, []byte{0xff})
+		}
+		if frame.flags[1]&0x01 != 0 && len(data) >= 4 {
+			data = data[4:]
+		}
+	}
+	return data, true
+}
+
+// decodeText decodes NUL separated strings of a text frame, the first byte is the text encoding
+func decodeText(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	encoding, data := data[0], data[1:]
+
+	var text string
+	switch encoding {
+	case 1, 2:
+		bigEndian := encoding == 2
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			unit := binary.LittleEndian.Uint16(data[i:])
+			if bigEndian {
+				unit = binary.BigEndian.Uint16(data[i:])
+			}
+			switch unit {
+			case 0xfeff:
+				continue
+			case 0xfffe:
+				// the byte order mark of the other byte order
+				bigEndian = !bigEndian
+				continue
+			}
+			units = append(units, unit)
+		}
+		text = string(utf16.Decode(units))
+	case 3:
+		text = string(data)
+	default:
+		// ISO-8859-1 maps to the first 256 code points
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+	return strings.Split(strings.TrimRight(text, "\x00"), "\x00")
+}
+
+// syncsafe decodes a 28-bit integer stored in 4 bytes of 7 bits
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// appendSyncsafe appends a 28-bit integer as 4 bytes of 7 bits
+func appendSyncsafe(b []byte, v int) []byte {
+	return append(b, byte(v>>21&0x7f), byte(v>>14&0x7f), byte(v>>7&0x7f), byte(v&0x7f))
+}
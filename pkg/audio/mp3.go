@@ -0,0 +1,214 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// mp3SearchLimit is how far past the ID3v2 tag the first MPEG frame is searched for
+const mp3SearchLimit = 64 * 1024
+
+// MP3 handles MP3 files, APIC frames and padding are removed from their ID3v2 tag
+type MP3 struct{}
+
+// Name returns the name of the format
+func (MP3) Name() string {
+	return "mp3"
+}
+
+// Extensions returns file name extensions of the format
+func (MP3) Extensions() []string {
+	return []string{".mp3"}
+}
+
+// Detect checks if the file starts with an ID3v2 tag or an MPEG audio frame
+func (MP3) Detect(head []byte) bool {
+	if bytes.HasPrefix(head, []byte("ID3")) {
+		return true
+	}
+	_, ok := parseMPEGHeader(head)
+	return ok
+}
+
+// StripArt removes APIC frames and padding from the ID3v2 tag, files without a tag are returned unchanged.
+// Options are ignored
+func (MP3) StripArt(data []byte, _ StripOptions) ([]byte, error) {
+	if id3Size(data) == 0 {
+		return data, nil
+	}
+	tag, err := parseID3(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ID3v2 tag: %s", err)
+	}
+	return append(tag.withoutPictures().marshal(), data[tag.size:]...), nil
+}
+
+// ReadMetadata reads the ID3v2 tag and the first MPEG frame of the file
+func (MP3) ReadMetadata(path string) (*Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{}
+	audioStart := int64(0)
+	if size := id3Size(header); size > 0 {
+		data := make([]byte, size)
+		copy(data, header)
+		if _, err := io.ReadFull(f, data[len(header):]); err != nil {
+			return nil, err
+		}
+		tag, err := parseID3(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing ID3v2 tag: %s", err)
+		}
+		meta.Tags = tag.tags()
+		meta.Stripped = int64(size - len(tag.withoutPictures().marshal()))
+		audioStart = int64(size)
+	}
+
+	// the duration is optional, a file without a recognisable first frame still has its tags
+	if _, err := f.Seek(audioStart, io.SeekStart); err != nil {
+		return nil, err
+	}
+	head := make([]byte, mp3SearchLimit)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	audioSize := stat.Size() - audioStart
+	if stat.Size() >= 128 {
+		trailer := make([]byte, 3)
+		if _, err := f.ReadAt(trailer, stat.Size()-128); err == nil && string(trailer) == "TAG" {
+			audioSize -= 128
+		}
+	}
+	meta.Duration, meta.SampleRate = mpegDuration(head, audioSize)
+	return meta, nil
+}
+
+// mpegHeader is a decoded MPEG audio frame header
+type mpegHeader struct {
+	version    int // 1 for MPEG-1, 2 for MPEG-2 and MPEG-2.5
+	sampleRate int
+	bitrate    int // bits per second
+	mono       bool
+	size       int // frame size in bytes
+}
+
+var (
+	mpeg1Bitrates = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mpeg2Bitrates = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	sampleRates   = [3]int{44100, 48000, 32000}
+)
+
+// parseMPEGHeader decodes an MPEG Layer III frame header, free format frames are not supported
+func parseMPEGHeader(b []byte) (mpegHeader, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mpegHeader{}, false
+	}
+	versionBits := b[1] >> 3 & 0x03
+	layerBits := b[1] >> 1 & 0x03
+	bitrateIndex := b[2] >> 4
+	rateIndex := b[2] >> 2 & 0x03
+	if versionBits == 1 || layerBits != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mpegHeader{}, false
+	}
+
+	h := mpegHeader{version: 1, sampleRate: sampleRates[rateIndex], mono: b[3]>>6 == 3}
+	switch versionBits {
+	case 3:
+		h.bitrate = mpeg1Bitrates[bitrateIndex] * 1000
+	case 2:
+		h.version = 2
+		h.sampleRate /= 2
+		h.bitrate = mpeg2Bitrates[bitrateIndex] * 1000
+	case 0:
+		// MPEG-2.5
+		h.version = 2
+		h.sampleRate /= 4
+		h.bitrate = mpeg2Bitrates[bitrateIndex] * 1000
+	}
+	padding := int(b[2] >> 1 & 0x01)
+	h.size = h.samples()/8*h.bitrate/h.sampleRate + padding
+	return h, true
+}
+
+// samples returns the number of samples in a frame
+func (h mpegHeader) samples() int {
+	if h.version == 1 {
+		return 1152
+	}
+	return 576
+}
+
+// sideInfoSize returns the size of side information following the frame header
+func (h mpegHeader) sideInfoSize() int {
+	switch {
+	case h.version == 1 && h.mono:
+		return 17
+	case h.version == 1:
+		return 32
+	case h.mono:
+		return 9
+	default:
+		return 17
+	}
+}
+
+// mpegDuration finds the first frame in head and returns the duration and sample rate of the stream,
+// the frame count is taken from a Xing, Info or VBRI header, otherwise a constant bitrate is assumed
+func mpegDuration(head []byte, audioSize int64) (time.Duration, int) {
+	for i := 0; i+4 <= len(head); i++ {
+		h, ok := parseMPEGHeader(head[i:])
+		if !ok {
+			continue
+		}
+		// a real frame is followed by another one unless it's the last one read
+		if next := i + h.size; next+4 <= len(head) {
+			if _, ok := parseMPEGHeader(head[next:]); !ok {
+				continue
+			}
+		}
+
+		frame := head[i:]
+		var frames uint32
+		if xing := 4 + h.sideInfoSize(); len(frame) >= xing+12 {
+			tag := string(frame[xing : xing+4])
+			if (tag == "Xing" || tag == "Info") && frame[xing+7]&0x01 != 0 {
+				frames = binary.BigEndian.Uint32(frame[xing+8:])
+			}
+		}
+		if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+			frames = binary.BigEndian.Uint32(frame[36+14:])
+		}
+		if frames > 0 {
+			samples := uint64(frames) * uint64(h.samples())
+			return time.Duration(samples * uint64(time.Second) / uint64(h.sampleRate)), h.sampleRate
+		}
+
+		size := audioSize - int64(i)
+		if size <= 0 {
+			return 0, h.sampleRate
+		}
+		return time.Duration(float64(size*8) / float64(h.bitrate) * float64(time.Second)), h.sampleRate
+	}
+	return 0, 0
+}
@@ -0,0 +1,213 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testFrame is an ID3v2 frame of a test file
+type testFrame struct {
+	id   string
+	data string
+}
+
+// buildID3 encodes an ID3v2 tag of the major version with the frames followed by padding
+func buildID3(major byte, frames []testFrame, padding int) []byte {
+	var body []byte
+	for _, frame := range frames {
+		n := len(frame.data)
+		body = append(body, frame.id...)
+		switch major {
+		case 2:
+			body = append(body, byte(n>>16), byte(n>>8), byte(n))
+		case 3:
+			body = binary.BigEndian.AppendUint32(body, uint32(n))
+			body = append(body, 0, 0)
+		case 4:
+			body = appendSyncsafe(body, n)
+			body = append(body, 0, 0)
+		}
+		body = append(body, frame.data...)
+	}
+	body = append(body, make([]byte, padding)...)
+	return append(appendSyncsafe([]byte{'I', 'D', '3', major, 0, 0}, len(body)), body...)
+}
+
+// mpegFrames returns n frames of MPEG-1 Layer III at 128 kbps and 44.1 kHz,
+// the first frame holds a Xing header when xingFrames isn't zero
+func mpegFrames(n int, xingFrames uint32) []byte {
+	var data []byte
+	for i := range n {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+		if i == 0 && xingFrames > 0 {
+			copy(frame[36:], "Xing")
+			binary.BigEndian.PutUint32(frame[40:], 1)
+			binary.BigEndian.PutUint32(frame[44:], xingFrames)
+		}
+		data = append(data, frame...)
+	}
+	return data
+}
+
+// utf16Text encodes a text frame in UTF-16 with a little-endian byte order mark
+func utf16Text(s string) string {
+	data := []byte{1, 0xff, 0xfe}
+	for _, r := range s {
+		data = binary.LittleEndian.AppendUint16(data, uint16(r))
+	}
+	return string(data)
+}
+
+func TestMP3(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_mp3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	picture := string(bytes.Repeat([]byte{0xff, 0xd8}, 4000))
+	cbr := time.Duration(20 * 417 * 8 * float64(time.Second) / 128000)
+
+	tests := []struct {
+		name         string
+		tag          []byte
+		xingFrames   uint32
+		wantTags     map[string][]string
+		wantDuration time.Duration
+	}{
+		{
+			name: "ID3v2.2",
+			tag: buildID3(2, []testFrame{
+				{"TT2", "\x00Title"},
+				{"TP2", "\x00Album artist"},
+				{"TRK", "\x003/10"},
+				{"TXX", "\x00replaygain_album_gain\x00-6.5 dB"},
+				{"PIC", "\x00JPG\x03\x00" + picture},
+			}, 256),
+			wantTags: map[string][]string{
+				"TITLE":                 {"Title"},
+				"ALBUMARTIST":           {"Album artist"},
+				"TRACKNUMBER":           {"3"},
+				"TRACKTOTAL":            {"10"},
+				"REPLAYGAIN_ALBUM_GAIN": {"-6.5 dB"},
+			},
+			wantDuration: cbr,
+		},
+		{
+			name: "ID3v2.3",
+			tag: buildID3(3, []testFrame{
+				{"TIT2", utf16Text("Tïtle")},
+				{"APIC", "\x00image/jpeg\x00\x03\x00" + picture},
+				{"TCON", "\x00Jazz"},
+				{"COMM", "\x00eng\x00A comment"},
+				{"APIC", "\x00image/png\x00\x04\x00" + picture},
+			}, 1024),
+			xingFrames: 1000,
+			wantTags: map[string][]string{
+				"TITLE":   {"Tïtle"},
+				"GENRE":   {"Jazz"},
+				"COMMENT": {"A comment"},
+			},
+			wantDuration: 1000 * 1152 * time.Second / 44100,
+		},
+		{
+			name: "ID3v2.4",
+			tag: buildID3(4, []testFrame{
+				{"TPE1", "\x03First\x00Second"},
+				{"TDRC", "\x032024"},
+				{"APIC", "\x03image/jpeg\x00\x03\x00" + picture},
+			}, 0),
+			wantTags: map[string][]string{
+				"ARTIST": {"First", "Second"},
+				"DATE":   {"2024"},
+			},
+			wantDuration: cbr,
+		},
+		{
+			name:         "no tag",
+			wantTags:     map[string][]string{},
+			wantDuration: cbr,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames := 20
+			if tt.xingFrames > 0 {
+				frames = 2
+			}
+			audio := mpegFrames(frames, tt.xingFrames)
+			data := append(slices.Clone(tt.tag), audio...)
+			path := filepath.Join(tmpDir, tt.name+".mp3")
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if !(MP3{}).Detect(data) {
+				t.Error("Detect() = false")
+			}
+
+			meta, err := MP3{}.ReadMetadata(path)
+			if err != nil {
+				t.Fatalf("ReadMetadata() error = %v", err)
+			}
+			got := make(map[string][]string)
+			for _, tag := range meta.Tags {
+				got[tag.Name] = append(got[tag.Name], tag.Value)
+			}
+			if len(got) != len(tt.wantTags) {
+				t.Errorf("ReadMetadata() tags = %v, want %v", got, tt.wantTags)
+			}
+			for name, want := range tt.wantTags {
+				if !slices.Equal(got[name], want) {
+					t.Errorf("ReadMetadata() tag %s = %v, want %v", name, got[name], want)
+				}
+			}
+			if meta.Duration != tt.wantDuration {
+				t.Errorf("ReadMetadata() duration = %v, want %v", meta.Duration, tt.wantDuration)
+			}
+			if meta.SampleRate != 44100 {
+				t.Errorf("ReadMetadata() sample rate = %d, want 44100", meta.SampleRate)
+			}
+
+			stripped, err := MP3{}.StripArt(data, StripOptions{})
+			if err != nil {
+				t.Fatalf("StripArt() error = %v", err)
+			}
+			if int64(len(data)-len(stripped)) != meta.Stripped {
+				t.Errorf("StripArt() removed %d bytes, ReadMetadata() reported %d", len(data)-len(stripped), meta.Stripped)
+			}
+			if !bytes.HasSuffix(stripped, audio) {
+				t.Error("StripArt() changed audio frames")
+			}
+			if bytes.Contains(stripped, []byte(picture[:100])) {
+				t.Error("StripArt() kept the picture")
+			}
+
+			// the stripped file keeps its tags
+			if err := os.WriteFile(path, stripped, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			again, err := MP3{}.ReadMetadata(path)
+			if err != nil {
+				t.Fatalf("ReadMetadata() of the stripped file error = %v", err)
+			}
+			if !slices.Equal(again.Tags, meta.Tags) || again.Stripped != 0 {
+				t.Errorf("stripped file tags = %v stripped %d, want %v stripped 0", again.Tags, again.Stripped, meta.Tags)
+			}
+		})
+	}
+
+	// a tag claiming more data than the file holds
+	broken := buildID3(3, []testFrame{{"TIT2", "\x00Title"}}, 0)
+	broken[9] += 100
+	if _, err := (MP3{}).StripArt(broken, StripOptions{}); err == nil {
+		t.Error("StripArt() expected error for a truncated tag")
+	}
+}
@@ -0,0 +1,449 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// oggContinued marks a page starting with the continuation of a packet
+	oggContinued = 0x01
+	// oggMaxPage is the largest possible page, used to find the last page of a file
+	oggMaxPage = 27 + 255 + 255*255
+	// oggNoGranule is the granule position of pages where no packet ends
+	oggNoGranule = ^uint64(0)
+)
+
+// oggArtComments are Vorbis comments holding embedded pictures
+var oggArtComments = []string{"METADATA_BLOCK_PICTURE", "COVERART", "COVERARTMIME"}
+
+// Ogg handles Ogg Vorbis and Opus files, picture comments are removed from them
+type Ogg struct{}
+
+// Name returns the name of the format
+func (Ogg) Name() string {
+	return "ogg"
+}
+
+// Extensions returns file name extensions of the format
+func (Ogg) Extensions() []string {
+	return []string{".ogg", ".oga", ".opus"}
+}
+
+// Detect checks if the file starts with an Ogg page
+func (Ogg) Detect(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("OggS"))
+}
+
+// StripArt removes METADATA_BLOCK_PICTURE and COVERART comments. The header pages are rebuilt
+// and the following pages renumbered, audio data is kept as is. Options are ignored
+func (Ogg) StripArt(data []byte, _ StripOptions) ([]byte, error) {
+	r := bytes.NewReader(data)
+	headers, err := readOggHeaders(r)
+	if err != nil {
+		return nil, err
+	}
+	rebuilt, err := headers.strip()
+	if err != nil {
+		return nil, err
+	}
+
+	out := append(headers.pages[0].marshal(), rebuilt.data...)
+	shift := uint32(rebuilt.pages - (len(headers.pages) - 1))
+	for {
+		page, err := readOggPage(r)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if page.serial != headers.serial {
+			return nil, fmt.Errorf("multiplexed Ogg streams are not supported")
+		}
+		page.sequence += shift
+		out = append(out, page.marshal()...)
+	}
+}
+
+// ReadMetadata reads the header pages and the last page of the file
+func (Ogg) ReadMetadata(path string) (*Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	headers, err := readOggHeaders(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	rebuilt, err := headers.strip()
+	if err != nil {
+		return nil, err
+	}
+	comment, err := parseOggComment(headers.packets[1], headers.commentMagic())
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{}
+	for _, tag := range commentTags(comment.comments) {
+		if !isArtComment(tag.Name) {
+			meta.Tags = append(meta.Tags, tag)
+		}
+	}
+	var size int64
+	for _, page := range headers.pages[1:] {
+		size += int64(page.size())
+	}
+	meta.Stripped = size - int64(len(rebuilt.data))
+
+	id := headers.packets[0]
+	preSkip, rate := uint64(0), uint64(0)
+	switch headers.codec {
+	case "vorbis":
+		if len(id) >= 16 {
+			rate = uint64(binary.LittleEndian.Uint32(id[12:]))
+		}
+	case "opus":
+		// Opus always decodes at 48kHz, the header holds the rate of the original input
+		rate = 48000
+		if len(id) >= 12 {
+			preSkip = uint64(binary.LittleEndian.Uint16(id[10:]))
+		}
+	}
+	meta.SampleRate = int(rate)
+
+	granule, err := lastGranule(f, headers.serial)
+	if err != nil {
+		return nil, err
+	}
+	if rate > 0 && granule != oggNoGranule && granule > preSkip {
+		meta.Duration = time.Duration(float64(granule-preSkip) / float64(rate) * float64(time.Second))
+	}
+	return meta, nil
+}
+
+// oggPage is a single page of an Ogg stream
+type oggPage struct {
+	flags    byte
+	granule  uint64
+	serial   uint32
+	sequence uint32
+	lacing   []byte
+	body     []byte
+}
+
+// readOggPage reads the next page, it returns io.EOF at the end of the stream
+func readOggPage(r io.Reader) (*oggPage, error) {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("Ogg page is truncated")
+		}
+		return nil, err
+	}
+	if string(header[:4]) != "OggS" || header[4] != 0 {
+		return nil, fmt.Errorf("invalid Ogg page")
+	}
+	page := &oggPage{
+		flags:    header[5],
+		granule:  binary.LittleEndian.Uint64(header[6:]),
+		serial:   binary.LittleEndian.Uint32(header[14:]),
+		sequence: binary.LittleEndian.Uint32(header[18:]),
+		lacing:   make([]byte, header[26]),
+	}
+	if _, err := io.ReadFull(r, page.lacing); err != nil {
+		return nil, fmt.Errorf("Ogg page is truncated")
+	}
+	size := 0
+	for _, v := range page.lacing {
+		size += int(v)
+	}
+	page.body = make([]byte, size)
+	if _, err := io.ReadFull(r, page.body); err != nil {
+		return nil, fmt.Errorf("Ogg page is truncated")
+	}
+	if !bytes.Equal(header[22:26], page.marshal()[22:26]) {
+		return nil, fmt.Errorf("Ogg page %d has an invalid checksum", page.sequence)
+	}
+	return page, nil
+}
+
+// size returns the encoded size of the page
+func (page *oggPage) size() int {
+	return 27 + len(page.lacing) + len(page.body)
+}
+
+// marshal encodes the page with its checksum
+func (page *oggPage) marshal() []byte {
+	data := make([]byte, 0, page.size())
+	data = append(data, 'O', 'g', 'g', 'S', 0, page.flags)
+	data = binary.LittleEndian.AppendUint64(data, page.granule)
+	data = binary.LittleEndian.AppendUint32(data, page.serial)
+	data = binary.LittleEndian.AppendUint32(data, page.sequence)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = append(data, byte(len(page.lacing)))
+	data = append(data, page.lacing...)
+	data = append(data, page.body...)
+	binary.LittleEndian.PutUint32(data[22:], oggCRC(data))
+	return data
+}
+
+// oggCRCTable is the table of the CRC-32 variant used by Ogg, polynomial 0x04c11db7 without reflection
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// oggCRC returns the checksum of the encoded page, its checksum field must be zero
+func oggCRC(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// oggHeaders are the header packets of a logical stream and the pages holding them
+type oggHeaders struct {
+	codec   string
+	serial  uint32
+	packets [][]byte
+	pages   []*oggPage
+}
+
+// readOggHeaders reads pages until all header packets of the first logical stream are complete,
+// Vorbis has identification, comment and setup headers, Opus identification and comment headers
+func readOggHeaders(r io.Reader) (*oggHeaders, error) {
+	headers := &oggHeaders{}
+	want := 0
+	var packet []byte
+	for want == 0 || len(headers.packets) < want {
+		page, err := readOggPage(r)
+		if err == io.EOF {
+			return nil, fmt.Errorf("Ogg headers are truncated")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(headers.pages) == 0 {
+			headers.serial = page.serial
+		} else if page.serial != headers.serial {
+			return nil, fmt.Errorf("multiplexed Ogg streams are not supported")
+		}
+		headers.pages = append(headers.pages, page)
+
+		offset := 0
+		for i, v := range page.lacing {
+			if want > 0 && len(headers.packets) == want {
+				return nil, fmt.Errorf("Ogg audio data shares a page with headers")
+			}
+			packet = append(packet, page.body[offset:offset+int(v)]...)
+			offset += int(v)
+			if v == 255 {
+				continue
+			}
+			headers.packets = append(headers.packets, packet)
+			packet = nil
+
+			if len(headers.packets) == 1 {
+				switch {
+				case bytes.HasPrefix(headers.packets[0], []byte("\x01vorbis")):
+					headers.codec, want = "vorbis", 3
+				case bytes.HasPrefix(headers.packets[0], []byte("OpusHead")):
+					headers.codec, want = "opus", 2
+				default:
+					return nil, fmt.Errorf("unsupported Ogg codec")
+				}
+				// the identification header is alone on the first page
+				if i != len(page.lacing)-1 || len(headers.pages) != 1 {
+					return nil, fmt.Errorf("invalid Ogg identification header page")
+				}
+			}
+		}
+	}
+	return headers, nil
+}
+
+// commentMagic returns the prefix of the comment header of the codec
+func (headers *oggHeaders) commentMagic() string {
+	if headers.codec == "opus" {
+		return "OpusTags"
+	}
+	return "\x03vorbis"
+}
+
+// rebuiltPages are encoded header pages following the identification header page
+type rebuiltPages struct {
+	data  []byte
+	pages int
+}
+
+// strip paginates the header packets following the identification header without art comments
+func (headers *oggHeaders) strip() (*rebuiltPages, error) {
+	comment, err := parseOggComment(headers.packets[1], headers.commentMagic())
+	if err != nil {
+		return nil, err
+	}
+	var comments []string
+	for _, field := range comment.comments {
+		name, _, _ := strings.Cut(field, "=")
+		if !isArtComment(name) {
+			comments = append(comments, field)
+		}
+	}
+	comment.comments = comments
+
+	packets := append([][]byte{comment.marshal()}, headers.packets[2:]...)
+	rebuilt := &rebuiltPages{}
+	for _, page := range paginate(packets, headers.serial, 1) {
+		rebuilt.data = append(rebuilt.data, page.marshal()...)
+		rebuilt.pages++
+	}
+	return rebuilt, nil
+}
+
+// paginate splits header packets into pages numbered from sequence
+func paginate(packets [][]byte, serial, sequence uint32) []*oggPage {
+	var pages []*oggPage
+	page := &oggPage{serial: serial, sequence: sequence, granule: oggNoGranule}
+	for _, packet := range packets {
+		for {
+			n := min(len(packet), 255)
+			page.lacing = append(page.lacing, byte(n))
+			page.body = append(page.body, packet[:n]...)
+			packet = packet[n:]
+			if n < 255 {
+				// header pages where a packet ends have a zero granule position
+				page.granule = 0
+			}
+			if len(page.lacing) == 255 {
+				pages = append(pages, page)
+				sequence++
+				page = &oggPage{serial: serial, sequence: sequence, granule: oggNoGranule}
+				if n == 255 {
+					page.flags = oggContinued
+				}
+			}
+			if n < 255 {
+				break
+			}
+		}
+	}
+	if len(page.lacing) > 0 {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// oggComment is a parsed Vorbis or Opus comment header
+type oggComment struct {
+	magic    string
+	vendor   string
+	comments []string
+	// trailing is the Vorbis framing bit or Opus padding data
+	trailing []byte
+}
+
+// parseOggComment parses the comment header packet starting with magic
+func parseOggComment(packet []byte, magic string) (*oggComment, error) {
+	if !bytes.HasPrefix(packet, []byte(magic)) {
+		return nil, fmt.Errorf("invalid Ogg comment header")
+	}
+	data := packet[len(magic):]
+	next := func() (string, error) {
+		if len(data) < 4 {
+			return "", fmt.Errorf("Ogg comment header is truncated")
+		}
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-4) {
+			return "", fmt.Errorf("Ogg comment header is truncated")
+		}
+		s := string(data[4 : 4+n])
+		data = data[4+n:]
+		return s, nil
+	}
+
+	comment := &oggComment{magic: magic}
+	var err error
+	if comment.vendor, err = next(); err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("Ogg comment header is truncated")
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	for range count {
+		field, err := next()
+		if err != nil {
+			return nil, err
+		}
+		comment.comments = append(comment.comments, field)
+	}
+	comment.trailing = data
+	return comment, nil
+}
+
+// marshal encodes the comment header packet
+func (comment *oggComment) marshal() []byte {
+	data := []byte(comment.magic)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(comment.vendor)))
+	data = append(data, comment.vendor...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(comment.comments)))
+	for _, field := range comment.comments {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(field)))
+		data = append(data, field...)
+	}
+	return append(data, comment.trailing...)
+}
+
+// isArtComment checks if the comment name is one of the picture comments
+func isArtComment(name string) bool {
+	for _, art := range oggArtComments {
+		if strings.EqualFold(name, art) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastGranule returns the granule position of the last page of the stream from the end of the file
+func lastGranule(f *os.File, serial uint32) (uint64, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	offset := max(stat.Size()-oggMaxPage, 0)
+	tail := make([]byte, stat.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		page, err := readOggPage(bytes.NewReader(tail[i:]))
+		if err == nil && page.serial == serial && page.granule != oggNoGranule {
+			return page.granule, nil
+		}
+	}
+	return oggNoGranule, nil
+}
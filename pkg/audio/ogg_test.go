@@ -0,0 +1,204 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildComment encodes a comment header packet
+func buildComment(magic string, comments []string, trailing string) []byte {
+	comment := &oggComment{magic: magic, vendor: "test vendor", comments: comments, trailing: []byte(trailing)}
+	return comment.marshal()
+}
+
+// buildOgg encodes a stream of the header packets followed by audio pages with the granule positions
+func buildOgg(serial uint32, headers [][]byte, granules []uint64) []byte {
+	first := &oggPage{flags: 0x02, serial: serial, lacing: []byte{byte(len(headers[0]))}, body: headers[0]}
+	data := first.marshal()
+	pages := paginate(headers[1:], serial, 1)
+	for _, page := range pages {
+		data = append(data, page.marshal()...)
+	}
+	sequence := uint32(len(pages) + 1)
+	for i, granule := range granules {
+		page := &oggPage{granule: granule, serial: serial, sequence: sequence, lacing: []byte{100, 50}}
+		page.body = bytes.Repeat([]byte{byte(i + 1)}, 150)
+		if i == len(granules)-1 {
+			page.flags = 0x04
+		}
+		data = append(data, page.marshal()...)
+		sequence++
+	}
+	return data
+}
+
+// readAllPages reads all pages of the stream
+func readAllPages(t *testing.T, data []byte) []*oggPage {
+	t.Helper()
+	r := bytes.NewReader(data)
+	var pages []*oggPage
+	for r.Len() > 0 {
+		page, err := readOggPage(r)
+		if err != nil {
+			t.Fatalf("readOggPage() error = %v", err)
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+func TestOgg(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_ogg_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// a picture large enough to span several pages
+	picture := strings.Repeat("QUJD", 40000)
+
+	opusHead := []byte("OpusHead\x01\x02")
+	opusHead = binary.LittleEndian.AppendUint16(opusHead, 312)
+	opusHead = binary.LittleEndian.AppendUint32(opusHead, 44100)
+	opusHead = append(opusHead, 0, 0, 0)
+
+	vorbisID := []byte("\x01vorbis\x00\x00\x00\x00\x02")
+	vorbisID = binary.LittleEndian.AppendUint32(vorbisID, 44100)
+	vorbisID = append(vorbisID, make([]byte, 12)...)
+	vorbisID = append(vorbisID, 0xb8, 0x01)
+	vorbisSetup := append([]byte("\x05vorbis"), bytes.Repeat([]byte{0x42}, 3000)...)
+
+	tests := []struct {
+		name         string
+		headers      [][]byte
+		granules     []uint64
+		wantTags     []Tag
+		wantRate     int
+		wantDuration time.Duration
+	}{
+		{
+			name: "opus",
+			headers: [][]byte{
+				opusHead,
+				buildComment("OpusTags", []string{"TITLE=Title", "METADATA_BLOCK_PICTURE=" + picture, "artist=Artist"}, "\x00padding"),
+			},
+			granules:     []uint64{24312, 48312},
+			wantTags:     []Tag{{"TITLE", "Title"}, {"ARTIST", "Artist"}},
+			wantRate:     48000,
+			wantDuration: time.Second,
+		},
+		{
+			name: "vorbis",
+			headers: [][]byte{
+				vorbisID,
+				buildComment("\x03vorbis", []string{"coverartmime=image/jpeg", "coverart=" + picture, "ALBUM=Album"}, "\x01"),
+				vorbisSetup,
+			},
+			granules:     []uint64{44100, 88200},
+			wantTags:     []Tag{{"ALBUM", "Album"}},
+			wantRate:     44100,
+			wantDuration: 2 * time.Second,
+		},
+		{
+			name: "no pictures",
+			headers: [][]byte{
+				opusHead,
+				buildComment("OpusTags", []string{"TITLE=Title"}, ""),
+			},
+			granules:     []uint64{48312},
+			wantTags:     []Tag{{"TITLE", "Title"}},
+			wantRate:     48000,
+			wantDuration: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildOgg(0x1234, tt.headers, tt.granules)
+			path := filepath.Join(tmpDir, tt.name+".ogg")
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if !(Ogg{}).Detect(data) {
+				t.Error("Detect() = false")
+			}
+
+			meta, err := Ogg{}.ReadMetadata(path)
+			if err != nil {
+				t.Fatalf("ReadMetadata() error = %v", err)
+			}
+			if !slices.Equal(meta.Tags, tt.wantTags) {
+				t.Errorf("ReadMetadata() tags = %v, want %v", meta.Tags, tt.wantTags)
+			}
+			if meta.SampleRate != tt.wantRate || meta.Duration != tt.wantDuration {
+				t.Errorf("ReadMetadata() = %d Hz %v, want %d Hz %v", meta.SampleRate, meta.Duration, tt.wantRate, tt.wantDuration)
+			}
+
+			stripped, err := Ogg{}.StripArt(data, StripOptions{})
+			if err != nil {
+				t.Fatalf("StripArt() error = %v", err)
+			}
+			if int64(len(data)-len(stripped)) != meta.Stripped {
+				t.Errorf("StripArt() removed %d bytes, ReadMetadata() reported %d", len(data)-len(stripped), meta.Stripped)
+			}
+
+			// pages are numbered in sequence and audio pages are kept
+			pages := readAllPages(t, stripped)
+			for i, page := range pages {
+				if page.sequence != uint32(i) {
+					t.Errorf("page %d has sequence number %d", i, page.sequence)
+				}
+			}
+			original := readAllPages(t, data)
+			audio := original[len(original)-len(tt.granules):]
+			for i, page := range pages[len(pages)-len(audio):] {
+				if page.granule != audio[i].granule || !bytes.Equal(page.body, audio[i].body) {
+					t.Errorf("audio page %d changed", i)
+				}
+			}
+
+			headers, err := readOggHeaders(bytes.NewReader(stripped))
+			if err != nil {
+				t.Fatalf("readOggHeaders() of the stripped file error = %v", err)
+			}
+			if !bytes.Equal(headers.packets[0], tt.headers[0]) {
+				t.Error("StripArt() changed the identification header")
+			}
+			comment, err := parseOggComment(headers.packets[1], headers.commentMagic())
+			if err != nil {
+				t.Fatal(err)
+			}
+			originalComment, _ := parseOggComment(tt.headers[1], headers.commentMagic())
+			if !bytes.Equal(comment.trailing, originalComment.trailing) {
+				t.Errorf("StripArt() trailing data = %q, want %q", comment.trailing, originalComment.trailing)
+			}
+			for _, field := range comment.comments {
+				if strings.Contains(field, picture[:100]) || strings.HasPrefix(strings.ToUpper(field), "COVERARTMIME=") {
+					t.Errorf("StripArt() kept picture comment %.40s", field)
+				}
+			}
+			if len(tt.headers) > 2 && !bytes.Equal(headers.packets[2], tt.headers[2]) {
+				t.Error("StripArt() changed the setup header")
+			}
+		})
+	}
+
+	// other codecs are not supported
+	flac := buildOgg(1, [][]byte{[]byte("\x7fFLAC"), []byte("comment")}, []uint64{100})
+	if _, err := (Ogg{}).StripArt(flac, StripOptions{}); err == nil {
+		t.Error("StripArt() expected error for an unsupported codec")
+	}
+	// damaged pages are detected by their checksum
+	damaged := buildOgg(1, [][]byte{opusHead, buildComment("OpusTags", nil, "")}, []uint64{100})
+	damaged[len(damaged)-1] ^= 0xff
+	if _, err := (Ogg{}).StripArt(damaged, StripOptions{}); err == nil {
+		t.Error("StripArt() expected error for a damaged page")
+	}
+}
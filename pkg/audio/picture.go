@@ -0,0 +1,82 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// PictureFrontCover is the picture type of front covers, see https://xiph.org/flac/format.html#metadata_block_picture
+const PictureFrontCover = 3
+
+// Picture is the content of a FLAC PICTURE block, which is also stored base64 encoded
+// in METADATA_BLOCK_PICTURE Vorbis comments
+type Picture struct {
+	Type        uint32
+	MIME        string
+	Description string
+	Width       uint32
+	Height      uint32
+	// Depth is the color depth in bits per pixel, Colors is the number of colors of indexed images
+	Depth  uint32
+	Colors uint32
+	Data   []byte
+}
+
+// ParsePicture parses PICTURE block data
+func ParsePicture(data []byte) (*Picture, error) {
+	r := pictureReader{data: data}
+	picture := &Picture{
+		Type:        r.uint32(),
+		MIME:        string(r.bytes()),
+		Description: string(r.bytes()),
+		Width:       r.uint32(),
+		Height:      r.uint32(),
+		Depth:       r.uint32(),
+		Colors:      r.uint32(),
+		Data:        r.bytes(),
+	}
+	if r.truncated {
+		return nil, fmt.Errorf("PICTURE block is truncated")
+	}
+	return picture, nil
+}
+
+// Marshal encodes the picture as PICTURE block data
+func (p *Picture) Marshal() []byte {
+	data := binary.BigEndian.AppendUint32(nil, p.Type)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(p.MIME)))
+	data = append(data, p.MIME...)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(p.Description)))
+	data = append(data, p.Description...)
+	for _, v := range []uint32{p.Width, p.Height, p.Depth, p.Colors, uint32(len(p.Data))} {
+		data = binary.BigEndian.AppendUint32(data, v)
+	}
+	return append(data, p.Data...)
+}
+
+// pictureReader reads big-endian fields of a picture, reads past the end set truncated
+type pictureReader struct {
+	data      []byte
+	truncated bool
+}
+
+func (r *pictureReader) uint32() uint32 {
+	if len(r.data) < 4 {
+		r.truncated = true
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *pictureReader) bytes() []byte {
+	n := r.uint32()
+	if uint64(n) > uint64(len(r.data)) {
+		r.truncated = true
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
@@ -0,0 +1,34 @@
+package audio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPicture(t *testing.T) {
+	picture := &Picture{
+		Type:        PictureFrontCover,
+		MIME:        "image/png",
+		Description: "Front",
+		Width:       600,
+		Height:      480,
+		Depth:       32,
+		Data:        []byte("picture data"),
+	}
+	data := picture.Marshal()
+
+	parsed, err := ParsePicture(data)
+	if err != nil {
+		t.Fatalf("ParsePicture() error = %v", err)
+	}
+	if !reflect.DeepEqual(parsed, picture) {
+		t.Errorf("ParsePicture() = %+v, want %+v", parsed, picture)
+	}
+
+	// every cut of the block is detected
+	for i := range len(data) {
+		if _, err := ParsePicture(data[:i]); err == nil {
+			t.Errorf("ParsePicture() of %d bytes expected error", i)
+		}
+	}
+}
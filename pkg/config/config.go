@@ -3,30 +3,134 @@ package config
 import (
 	"fmt"
 	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/transcode"
 )
 
 // Config is a set of parameters for albumpicker
 type Config struct {
-	Source          string
-	Destination     string
-	AlbumsCount     int
-	CoverFilenames  []string
-	OutputCoverName string
-	CoverHeight     int
+	Source            string
+	Destination       string
+	AlbumsCount       int
+	CoverFilenames    []string
+	OutputCoverName   string
+	CoverHeight       int
+	IndexFile         string
+	HistoryFile       string
+	ExcludeRecent     time.Duration
+	ExcludeLastRuns   int
+	SizeBudget        int64
+	Fill              bool
+	FillReserve       int64
+	Queries           map[string]string
+	SelectionStrategy string
+	WeightFile        string
+	MaxPerArtist      int
+	MaxPerGenre       int
+	// RatingScale is the maximum of RATING tags for the rating strategy, 0 detects it for every album
+	RatingScale float64
+	// Verify enables decoding of written FLAC files to compare them with their STREAMINFO MD5
+	Verify bool
+	// Jobs is the number of albums and files processed concurrently
+	Jobs int
+	// ReadJobs and WriteJobs limit concurrent reads of source files and writes to the destination
+	ReadJobs  int
+	WriteJobs int
+	// OutputFormat is flac to copy FLAC files or a lossy format they are transcoded to
+	OutputFormat string
+	// OutputBitrate is the bitrate of transcoded files in kbit/s, zero means the format default
+	OutputBitrate int
+	// Encoder is the name of the encoder backend transcoding files
+	Encoder string
+	// Downsample enables conversion of FLAC files exceeding SampleRate or BitDepth down to them
+	Downsample bool
+	SampleRate int
+	BitDepth   int
+	// AudioExtensions are extensions of audio files, lower-cased with a leading dot and matched case-insensitively
+	AudioExtensions []string
+	// FLACFrontCover keeps the first front cover PICTURE block of FLAC files resized to CoverHeight,
+	// other pictures are always removed
+	FLACFrontCover bool
+	// FLACDropApplication, FLACDropCueSheet and FLACDropSeekTable remove these metadata blocks from FLAC files
+	FLACDropApplication bool
+	FLACDropCueSheet    bool
+	FLACDropSeekTable   bool
+	// FLACPadding is the size of the PADDING block added to FLAC files, zero adds none
+	FLACPadding int64
 }
 
 // LoadConfig loads and validates the configuration from viper
 func LoadConfig() (*Config, error) {
 	config := &Config{
-		Source:          viper.GetString("source"),
-		Destination:     viper.GetString("destination"),
-		AlbumsCount:     viper.GetInt("albums_count"),
-		CoverFilenames:  viper.GetStringSlice("cover_filenames"),
-		OutputCoverName: viper.GetString("output_cover_filename"),
-		CoverHeight:     viper.GetInt("cover_height"),
+		Source:            viper.GetString("source"),
+		Destination:       viper.GetString("destination"),
+		AlbumsCount:       viper.GetInt("albums_count"),
+		CoverFilenames:    viper.GetStringSlice("cover_filenames"),
+		OutputCoverName:   viper.GetString("output_cover_filename"),
+		CoverHeight:       viper.GetInt("cover_height"),
+		IndexFile:         viper.GetString("index_file"),
+		HistoryFile:       viper.GetString("history_file"),
+		ExcludeLastRuns:   viper.GetInt("exclude_last_runs"),
+		Fill:              viper.GetBool("fill"),
+		Queries:           viper.GetStringMapString("queries"),
+		SelectionStrategy: viper.GetString("selection_strategy"),
+		WeightFile:        viper.GetString("weight_file"),
+		MaxPerArtist:      viper.GetInt("max_per_artist"),
+		MaxPerGenre:       viper.GetInt("max_per_genre"),
+		RatingScale:       viper.GetFloat64("rating_scale"),
+		Verify:            viper.GetBool("verify"),
+		Jobs:              viper.GetInt("jobs"),
+		ReadJobs:          viper.GetInt("read_jobs"),
+		WriteJobs:         viper.GetInt("write_jobs"),
+		OutputFormat:      strings.ToLower(viper.GetString("output_format")),
+		OutputBitrate:     viper.GetInt("output_bitrate"),
+		Encoder:           viper.GetString("encoder"),
+		Downsample:        viper.GetBool("downsample"),
+		SampleRate:        viper.GetInt("sample_rate"),
+		BitDepth:          viper.GetInt("bit_depth"),
+		// FLAC metadata blocks kept in written files
+		FLACFrontCover:      viper.GetBool("flac_front_cover"),
+		FLACDropApplication: viper.GetBool("flac_drop_application"),
+		FLACDropCueSheet:    viper.GetBool("flac_drop_cuesheet"),
+		FLACDropSeekTable:   viper.GetBool("flac_drop_seektable"),
+	}
+
+	excludeRecent, err := ParseDuration(viper.GetString("exclude_recent"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude_recent: %s", err)
+	}
+	config.ExcludeRecent = excludeRecent
+
+	sizeBudget, err := ParseSize(viper.GetString("size_budget"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid size_budget: %s", err)
+	}
+	config.SizeBudget = sizeBudget
+
+	fillReserve, err := ParseSize(viper.GetString("fill_reserve"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid fill_reserve: %s", err)
+	}
+	config.FillReserve = fillReserve
+
+	audioExtensions, err := ParseExtensions(viper.GetStringSlice("audio_extensions"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid audio_extensions: %s", err)
 	}
+	config.AudioExtensions = audioExtensions
+
+	flacPadding, err := ParseSize(viper.GetString("flac_padding"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid flac_padding: %s", err)
+	}
+	config.FLACPadding = flacPadding
 
 	// validate config
 	if config.Source == "" {
@@ -35,18 +139,86 @@ func LoadConfig() (*Config, error) {
 	if config.Destination == "" {
 		return nil, fmt.Errorf("destination directory not specified")
 	}
+	if config.ExcludeLastRuns < 0 {
+		return nil, fmt.Errorf("exclude_last_runs must not be negative")
+	}
+	if config.MaxPerArtist < 0 || config.MaxPerGenre < 0 {
+		return nil, fmt.Errorf("max_per_artist and max_per_genre must not be negative")
+	}
+	if config.RatingScale < 0 {
+		return nil, fmt.Errorf("rating_scale must not be negative")
+	}
+	if config.Jobs < 0 || config.ReadJobs < 0 || config.WriteJobs < 0 {
+		return nil, fmt.Errorf("jobs, read_jobs and write_jobs must not be negative")
+	}
+	if config.OutputFormat == "" {
+		config.OutputFormat = transcode.FLAC
+	}
+	if config.OutputFormat != transcode.FLAC {
+		if _, err := transcode.NewProfile(config.OutputFormat, config.OutputBitrate); err != nil {
+			return nil, err
+		}
+	}
+	if config.Downsample {
+		if config.SampleRate <= 0 || config.SampleRate > 655350 {
+			return nil, fmt.Errorf("sample_rate must be between 1 and 655350 Hz")
+		}
+		if config.BitDepth < 8 || config.BitDepth > 24 {
+			return nil, fmt.Errorf("bit_depth must be between 8 and 24 bits")
+		}
+	}
+	// the length of a metadata block is a 24-bit number
+	if config.FLACPadding >= 1<<24 {
+		return nil, fmt.Errorf("flac_padding must be less than 16 MiB")
+	}
+	// zero means the default: a job per CPU, reads limited only by jobs and a single writer
+	if config.Jobs == 0 {
+		config.Jobs = runtime.NumCPU()
+	}
+	if config.ReadJobs == 0 {
+		config.ReadJobs = config.Jobs
+	}
+	if config.WriteJobs == 0 {
+		config.WriteJobs = 1
+	}
 
 	// check if source directory exists
 	if _, err := os.Stat(config.Source); os.IsNotExist(err) {
 		return nil, fmt.Errorf("source directory does not exist: %s", config.Source)
 	}
 
-	// create destination directory if it doesn't exist
-	if _, err := os.Stat(config.Destination); os.IsNotExist(err) {
-		if err := os.MkdirAll(config.Destination, 0o755); err != nil {
-			return nil, fmt.Errorf("failed to create destination directory: %s", err)
+	return config, nil
+}
+
+// CreateDestination creates the destination directory if it doesn't exist,
+// it's left to commands writing to it, so dry runs don't touch the disk
+func (c *Config) CreateDestination() error {
+	if _, err := os.Stat(c.Destination); os.IsNotExist(err) {
+		if err := os.MkdirAll(c.Destination, 0o755); err != nil {
+			return fmt.Errorf("failed to create destination directory: %s", err)
 		}
 	}
+	return nil
+}
 
-	return config, nil
+// ParseExtensions normalizes file name extensions to sorted lower-case ones with a leading dot,
+// an empty list means the extensions of all supported audio formats
+func ParseExtensions(extensions []string) ([]string, error) {
+	if len(extensions) == 0 {
+		extensions = audio.DefaultExtensions()
+	}
+
+	var parsed []string
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if ext == "." || strings.ContainsAny(ext[1:], `./\`) {
+			return nil, fmt.Errorf("invalid extension %q", ext)
+		}
+		parsed = append(parsed, ext)
+	}
+	slices.Sort(parsed)
+	return slices.Compact(parsed), nil
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -31,6 +32,10 @@ func TestLoadConfig(t *testing.T) {
 	viper.Set("output_cover_filename", "output.jpg")
 	viper.Set("cover_height", 480)
 	viper.Set("concurrency", 2)
+	viper.Set("jobs", 3)
+	viper.Set("output_format", "Opus")
+	viper.Set("audio_extensions", []string{"FLAC", ".fla", ".flac"})
+	viper.Set("flac_padding", "8KiB")
 
 	// test LoadConfig
 	cfg, err := LoadConfig()
@@ -50,6 +55,11 @@ func TestLoadConfig(t *testing.T) {
 		{"AlbumsCount", cfg.AlbumsCount, 5, "wrong albums count"},
 		{"OutputCoverName", cfg.OutputCoverName, "output.jpg", "wrong output cover name"},
 		{"CoverHeight", cfg.CoverHeight, 480, "wrong cover height"},
+		{"Jobs", cfg.Jobs, 3, "wrong number of jobs"},
+		{"ReadJobs", cfg.ReadJobs, 3, "wrong number of read jobs"},
+		{"WriteJobs", cfg.WriteJobs, 1, "wrong number of write jobs"},
+		{"OutputFormat", cfg.OutputFormat, "opus", "wrong output format"},
+		{"FLACPadding", cfg.FLACPadding, int64(8192), "wrong FLAC padding"},
 	}
 
 	for _, tt := range tests {
@@ -64,6 +74,9 @@ func TestLoadConfig(t *testing.T) {
 	if len(cfg.CoverFilenames) != 1 || cfg.CoverFilenames[0] != "test.jpg" {
 		t.Errorf("wrong cover filenames: got %v, want %v", cfg.CoverFilenames, []string{"test.jpg"})
 	}
+	if want := []string{".fla", ".flac"}; !slices.Equal(cfg.AudioExtensions, want) {
+		t.Errorf("wrong audio extensions: got %v, want %v", cfg.AudioExtensions, want)
+	}
 }
 
 func TestLoadConfigValidation(t *testing.T) {
@@ -96,6 +109,47 @@ func TestLoadConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "unknown output format",
+			setup: func() {
+				viper.Set("source", "testsrc")
+				viper.Set("destination", "testdest")
+				viper.Set("output_format", "wma")
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported bit depth",
+			setup: func() {
+				viper.Set("source", "testsrc")
+				viper.Set("destination", "testdest")
+				viper.Set("output_format", "flac")
+				viper.Set("downsample", true)
+				viper.Set("sample_rate", 44100)
+				viper.Set("bit_depth", 32)
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid audio extension",
+			setup: func() {
+				viper.Set("source", "testsrc")
+				viper.Set("destination", "testdest")
+				viper.Set("downsample", false)
+				viper.Set("audio_extensions", []string{".flac", "music/flac"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "padding exceeding a metadata block",
+			setup: func() {
+				viper.Set("source", os.TempDir())
+				viper.Set("destination", os.TempDir())
+				viper.Set("audio_extensions", []string{".flac"})
+				viper.Set("flac_padding", "16MiB")
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -108,3 +162,31 @@ func TestLoadConfigValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestParseExtensions(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions []string
+		want       []string
+		wantErr    bool
+	}{
+		{"default", nil, []string{".flac", ".mp3", ".oga", ".ogg", ".opus"}, false},
+		{"normalized", []string{"FLAC", " .Mp3 ", ".flac"}, []string{".flac", ".mp3"}, false},
+		{"ambiguous", []string{".flac", ".fla"}, []string{".fla", ".flac"}, false},
+		{"empty", []string{".flac", ""}, nil, true},
+		{"path", []string{"a/flac"}, nil, true},
+		{"double", []string{".tar.flac"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExtensions(tt.extensions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExtensions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseExtensions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration parses a duration like time.ParseDuration does,
+// additionally accepting days ("30d") and weeks ("2w")
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		if number, ok := strings.CutSuffix(s, suffix); ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if !validNumber(n, err, float64(unit), math.MaxInt64) {
+				return 0, fmt.Errorf("invalid duration: %s", s)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return d, nil
+}
+
+// ParseSize parses a size like "20GB", "500MiB" or "1024" (bytes)
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	// longer suffixes go first, so "MB" isn't matched as "B"
+	units := []struct {
+		suffix string
+		size   float64
+	}{
+		{"KIB", 1 << 10},
+		{"MIB", 1 << 20},
+		{"GIB", 1 << 30},
+		{"TIB", 1 << 40},
+		{"KB", 1e3},
+		{"MB", 1e6},
+		{"GB", 1e9},
+		{"TB", 1e12},
+		{"K", 1e3},
+		{"M", 1e6},
+		{"G", 1e9},
+		{"T", 1e12},
+		{"B", 1},
+	}
+
+	unit := 1.0
+	number := s
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(s, u.suffix); ok {
+			number, unit = n, u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if !validNumber(n, err, unit, math.MaxInt64) {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return int64(n * unit), nil
+}
+
+// validNumber checks that a parsed number is not negative, infinite or NaN
+// and doesn't exceed limit when multiplied by unit
+func validNumber(n float64, err error, unit, limit float64) bool {
+	return err == nil && n >= 0 && !math.IsInf(n, 0) && !math.IsNaN(n) && n*unit < limit
+}
+
+// FormatSize formats a number of bytes in a human-readable form
+func FormatSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"-5h", 0, true},
+		{"month", 0, true},
+		{"infd", 0, true},
+		{"+Infw", 0, true},
+		{"NaNd", 0, true},
+		{"1e300d", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDuration(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuration(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1024", 1024, false},
+		{"20GB", 20e9, false},
+		{"20 gb", 20e9, false},
+		{"1.5G", 1.5e9, false},
+		{"500MiB", 500 << 20, false},
+		{"100B", 100, false},
+		{"GB", 0, true},
+		{"-1GB", 0, true},
+		{"20XB", 0, true},
+		{"inf", 0, true},
+		{"+InfGB", 0, true},
+		{"NaN", 0, true},
+		{"NaNB", 0, true},
+		{"1e30TB", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		input int64
+		want  string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1500, "1.5 kB"},
+		{20e9, "20.0 GB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatSize(tt.input); got != tt.want {
+			t.Errorf("FormatSize(%d) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
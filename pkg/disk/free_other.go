@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package disk
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Free returns the number of bytes available to the user on the filesystem containing path
+func Free(_ string) (int64, error) {
+	return 0, fmt.Errorf("free space detection is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,26 @@
+package disk
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFree(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_disk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	free, err := Free(tmpDir)
+	if err != nil {
+		t.Fatalf("Free() error = %v", err)
+	}
+	if free <= 0 {
+		t.Errorf("Free() = %d, want positive value", free)
+	}
+
+	if _, err := Free("/nonexistent/albumpicker/path"); err == nil {
+		t.Error("Free() expected error for nonexistent path")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package disk
+
+import "syscall"
+
+// Free returns the number of bytes available to the user on the filesystem containing path
+func Free(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package disk
+
+import "golang.org/x/sys/windows"
+
+// Free returns the number of bytes available to the user on the filesystem containing path
+func Free(path string) (int64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return int64(available), nil
+}
@@ -0,0 +1,136 @@
+package flacstream
+
+import (
+	"io"
+	"math/bits"
+)
+
+// bitReader reads big-endian bit fields from a byte slice, a reader with src appends bytes
+// of src to the slice as they are needed
+type bitReader struct {
+	data []byte
+	// pos is the offset of the next byte to load into the cache
+	pos int
+	// cache holds n unread bits aligned to the most significant bit, the rest of bits are zero
+	cache uint64
+	n     uint
+
+	src io.ByteReader
+	// err is the error which stopped reading src
+	err error
+	// base is the offset of data in the stream, bytes before it were discarded
+	base int
+}
+
+// refill loads whole bytes into the cache while there is room for them
+func (r *bitReader) refill() {
+	for r.n <= 56 && (r.pos < len(r.data) || r.load()) {
+		r.cache |= uint64(r.data[r.pos]) << (56 - r.n)
+		r.pos++
+		r.n += 8
+	}
+}
+
+// load appends the next byte of src to data and reports whether there was one
+func (r *bitReader) load() bool {
+	if r.src == nil || r.err != nil {
+		return false
+	}
+	b, err := r.src.ReadByte()
+	if err != nil {
+		r.err = err
+		return false
+	}
+	r.data = append(r.data, b)
+	return true
+}
+
+// readBits reads an unsigned value of k bits, k must not exceed 56
+func (r *bitReader) readBits(k uint) (uint64, error) {
+	if k == 0 {
+		return 0, nil
+	}
+	if r.n < k {
+		r.refill()
+		if r.n < k {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	v := r.cache >> (64 - k)
+	r.cache <<= k
+	r.n -= k
+	return v, nil
+}
+
+// readSigned reads a two's complement value of k bits
+func (r *bitReader) readSigned(k uint) (int64, error) {
+	v, err := r.readBits(k)
+	if err != nil || k == 0 {
+		return 0, err
+	}
+	return int64(v<<(64-k)) >> (64 - k), nil
+}
+
+// readUnary counts zero bits up to the next one bit, which is consumed too
+func (r *bitReader) readUnary() (uint64, error) {
+	var count uint64
+	for {
+		if r.n == 0 {
+			r.refill()
+			if r.n == 0 {
+				return 0, io.ErrUnexpectedEOF
+			}
+		}
+		zeros := uint(bits.LeadingZeros64(r.cache))
+		if zeros >= r.n {
+			count += uint64(r.n)
+			r.cache = 0
+			r.n = 0
+			continue
+		}
+		r.cache <<= zeros + 1
+		r.n -= zeros + 1
+		return count + uint64(zeros), nil
+	}
+}
+
+// align skips bits up to the next byte boundary
+func (r *bitReader) align() {
+	skip := r.n % 8
+	r.cache <<= skip
+	r.n -= skip
+}
+
+// offset returns the offset of the next unread byte, the reader must be byte-aligned
+func (r *bitReader) offset() int {
+	return r.base + r.pos - int(r.n/8)
+}
+
+// bytes returns the data between the offsets, which must not be discarded
+func (r *bitReader) bytes(start, end int) []byte {
+	return r.data[start-r.base : end-r.base]
+}
+
+// atEnd checks if all data is read, the reader must be byte-aligned
+func (r *bitReader) atEnd() bool {
+	return r.n == 0 && r.pos >= len(r.data) && !r.load()
+}
+
+// discard drops data read from src before the next unread byte, so only the frame being
+// decoded is kept in memory. The reader must be byte-aligned
+func (r *bitReader) discard() {
+	if r.src == nil {
+		return
+	}
+	unread := r.pos - int(r.n/8)
+	r.data = r.data[:copy(r.data, r.data[unread:])]
+	r.pos -= unread
+	r.base += unread
+}
+
+// seek moves the reader to the byte offset, which must not be discarded
+func (r *bitReader) seek(offset int) {
+	r.pos = offset - r.base
+	r.cache = 0
+	r.n = 0
+}
@@ -0,0 +1,51 @@
+package flacstream
+
+// bitWriter writes big-endian bit fields to a byte slice
+type bitWriter struct {
+	data []byte
+	// cache holds n bits not yet written to data in its low-order bits
+	cache uint64
+	n     uint
+}
+
+// writeBits writes the k low-order bits of v, k must not exceed 64
+func (w *bitWriter) writeBits(v uint64, k uint) {
+	for k > 0 {
+		chunk := min(k, 32)
+		k -= chunk
+		w.cache = w.cache<<chunk | v>>k&(1<<chunk-1)
+		w.n += chunk
+		for w.n >= 8 {
+			w.n -= 8
+			w.data = append(w.data, byte(w.cache>>w.n))
+		}
+		w.cache &= 1<<w.n - 1
+	}
+}
+
+// writeSigned writes a two's complement value of k bits
+func (w *bitWriter) writeSigned(v int64, k uint) {
+	w.writeBits(uint64(v), k)
+}
+
+// writeUnary writes v zero bits followed by a one bit
+func (w *bitWriter) writeUnary(v uint64) {
+	for ; v > 32; v -= 32 {
+		w.writeBits(0, 32)
+	}
+	w.writeBits(1, uint(v)+1)
+}
+
+// align pads the stream with zero bits up to the next byte boundary
+func (w *bitWriter) align() {
+	if w.n > 0 {
+		w.writeBits(0, 8-w.n)
+	}
+}
+
+// reset discards all written bits
+func (w *bitWriter) reset() {
+	w.data = w.data[:0]
+	w.cache = 0
+	w.n = 0
+}
@@ -0,0 +1,103 @@
+package flacstream
+
+import (
+	"context"
+	"io"
+	"math"
+)
+
+// Target is the highest sample rate and bit depth of converted streams
+type Target struct {
+	SampleRate    int
+	BitsPerSample int
+}
+
+// Exceeds checks if the stream has a higher sample rate or more bits per sample than the target
+func (t Target) Exceeds(info StreamInfo) bool {
+	return info.SampleRate > t.SampleRate || info.BitsPerSample > t.BitsPerSample
+}
+
+// Convert decodes audio frames of the stream, lowers its sample rate and bit depth to the target ones
+// and encodes it again, it returns STREAMINFO and audio frames of the new stream.
+// Resampled and shortened samples are dithered, audio within the target is encoded unchanged
+func Convert(ctx context.Context, info StreamInfo, frames []byte, target Target) (StreamInfo, []byte, error) {
+	d, err := NewFrameDecoder(info, frames)
+	if err != nil {
+		return StreamInfo{}, nil, err
+	}
+	sampleRate := min(info.SampleRate, target.SampleRate)
+	bitsPerSample := min(info.BitsPerSample, target.BitsPerSample)
+	enc, err := NewEncoder(sampleRate, info.Channels, bitsPerSample)
+	if err != nil {
+		return StreamInfo{}, nil, err
+	}
+	var resampler *Resampler
+	if sampleRate < info.SampleRate {
+		if resampler, err = NewResampler(info.SampleRate, sampleRate, info.Channels); err != nil {
+			return StreamInfo{}, nil, err
+		}
+	}
+	ditherer := NewDitherer(bitsPerSample)
+
+	write := func(samples [][]float64) error {
+		quantized := make([][]int32, len(samples))
+		for ch := range samples {
+			quantized[ch] = ditherer.Quantize(samples[ch])
+		}
+		return enc.Write(quantized)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return StreamInfo{}, nil, err
+		}
+		frame, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return StreamInfo{}, nil, err
+		}
+
+		// the last frame may contain more samples than the stream declares
+		blockSize := frame.BlockSize
+		if info.TotalSamples > 0 && d.decoded > info.TotalSamples {
+			blockSize -= int(d.decoded - info.TotalSamples)
+		}
+
+		if resampler == nil && frame.BitsPerSample <= bitsPerSample {
+			samples := make([][]int32, len(frame.Samples))
+			for ch := range samples {
+				samples[ch] = frame.Samples[ch][:blockSize]
+			}
+			if err := enc.Write(samples); err != nil {
+				return StreamInfo{}, nil, err
+			}
+			continue
+		}
+
+		// samples are measured in least significant bits of the output
+		scale := math.Ldexp(1, bitsPerSample-frame.BitsPerSample)
+		samples := make([][]float64, len(frame.Samples))
+		for ch := range samples {
+			samples[ch] = make([]float64, blockSize)
+			for i, v := range frame.Samples[ch][:blockSize] {
+				samples[ch][i] = float64(v) * scale
+			}
+		}
+		if resampler != nil {
+			samples = resampler.Process(samples)
+		}
+		if err := write(samples); err != nil {
+			return StreamInfo{}, nil, err
+		}
+	}
+
+	if resampler != nil {
+		if err := write(resampler.Flush()); err != nil {
+			return StreamInfo{}, nil, err
+		}
+	}
+	newInfo, newFrames := enc.Finish()
+	return newInfo, newFrames, nil
+}
@@ -0,0 +1,174 @@
+package flacstream
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// streamData builds a FLAC stream of STREAMINFO and audio frames
+func streamData(info StreamInfo, frames []byte) []byte {
+	data := append([]byte("fLaC"), 0x80, 0, 0, 34)
+	data = append(data, info.Marshal()...)
+	return append(data, frames...)
+}
+
+// decodeAll decodes all samples of every channel of the stream
+func decodeAll(t *testing.T, data []byte) (StreamInfo, [][]int32) {
+	t.Helper()
+	d, err := NewDecoder(data)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	samples := make([][]int32, d.Info.Channels)
+	for {
+		frame, err := d.Next()
+		if err != nil {
+			break
+		}
+		for ch := range samples {
+			samples[ch] = append(samples[ch], frame.Samples[ch]...)
+		}
+	}
+	if err := VerifyData(data); err != nil {
+		t.Fatalf("VerifyData() error = %v", err)
+	}
+	return d.Info, samples
+}
+
+// sine returns n samples of a sine wave of the frequency and amplitude in bits per sample
+func sine(n, sampleRate int, freq, amplitude float64, bitsPerSample int) []int32 {
+	samples := make([]int32, n)
+	for i := range samples {
+		samples[i] = int32(math.Round(amplitude * math.Ldexp(1, bitsPerSample-1) * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))))
+	}
+	return samples
+}
+
+// rms is the root mean square of samples skipping the edges, where the filter sees silence
+func rms(samples []int32) float64 {
+	edge := len(samples) / 10
+	var sum float64
+	for _, v := range samples[edge : len(samples)-edge] {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(len(samples)-2*edge))
+}
+
+func TestEncoder(t *testing.T) {
+	frames := testFrames()
+	enc, err := NewEncoder(44100, 2, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want [2][]int32
+	for _, frame := range frames {
+		// blocks of the encoder don't match the written chunks
+		if err := enc.Write([][]int32{frame[0], frame[1]}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		for ch := range want {
+			want[ch] = append(want[ch], frame[ch]...)
+		}
+	}
+	// a long silent tail codes frame numbers in several bytes
+	silence := make([]int32, 130*BlockSize)
+	if err := enc.Write([][]int32{silence, silence}); err != nil {
+		t.Fatal(err)
+	}
+	for ch := range want {
+		want[ch] = append(want[ch], silence...)
+	}
+
+	info, encoded := enc.Finish()
+	if info.TotalSamples != uint64(len(want[0])) || info.MinFrameSize == 0 || info.MaxFrameSize < info.MinFrameSize {
+		t.Errorf("Finish() stream info = %+v", info)
+	}
+	_, got := decodeAll(t, streamData(info, encoded))
+	for ch := range want {
+		if len(got[ch]) != len(want[ch]) {
+			t.Fatalf("decoded %d samples of channel %d, want %d", len(got[ch]), ch, len(want[ch]))
+		}
+		for i := range want[ch] {
+			if got[ch][i] != want[ch][i] {
+				t.Fatalf("channel %d sample %d = %d, want %d", ch, i, got[ch][i], want[ch][i])
+			}
+		}
+	}
+
+	if err := enc.Write([][]int32{{1 << 15}, {0}}); err == nil {
+		t.Error("Write() of a sample exceeding 16 bits returned no error")
+	}
+	if _, err := NewEncoder(44100, 2, 32); err == nil {
+		t.Error("NewEncoder() of 32-bit audio returned no error")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	// a second of 1kHz on the left and 30kHz, above the Nyquist frequency of 44.1kHz, on the right
+	enc, err := NewEncoder(96000, 2, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Write([][]int32{sine(96000, 96000, 1000, 0.5, 24), sine(96000, 96000, 30000, 0.5, 24)}); err != nil {
+		t.Fatal(err)
+	}
+	info, frames := enc.Finish()
+
+	tests := []struct {
+		name       string
+		target     Target
+		wantRate   int
+		wantBits   int
+		unchanged  bool
+		wantExceed bool
+	}{
+		{name: "16/44.1", target: Target{SampleRate: 44100, BitsPerSample: 16}, wantRate: 44100, wantBits: 16, wantExceed: true},
+		{name: "bit depth only", target: Target{SampleRate: 96000, BitsPerSample: 16}, wantRate: 96000, wantBits: 16, wantExceed: true},
+		{name: "sample rate only", target: Target{SampleRate: 48000, BitsPerSample: 24}, wantRate: 48000, wantBits: 24, wantExceed: true},
+		{name: "within target", target: Target{SampleRate: 192000, BitsPerSample: 24}, wantRate: 96000, wantBits: 24, unchanged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.target.Exceeds(info); got != tt.wantExceed {
+				t.Errorf("Exceeds() = %v, want %v", got, tt.wantExceed)
+			}
+
+			newInfo, newFrames, err := Convert(context.Background(), info, frames, tt.target)
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if newInfo.SampleRate != tt.wantRate || newInfo.BitsPerSample != tt.wantBits || newInfo.TotalSamples != uint64(tt.wantRate) {
+				t.Errorf("Convert() stream info = %+v, want a second of %d bits at %d Hz", newInfo, tt.wantBits, tt.wantRate)
+			}
+			if tt.unchanged {
+				if newInfo.MD5 != info.MD5 {
+					t.Error("Convert() changed audio within the target")
+				}
+				return
+			}
+
+			_, samples := decodeAll(t, streamData(newInfo, newFrames))
+			// the level of the tone is kept and the tone above the new Nyquist frequency is filtered out
+			full := 0.5 * math.Ldexp(1, tt.wantBits-1) / math.Sqrt2
+			if left := rms(samples[0]); math.Abs(left/full-1) > 0.01 {
+				t.Errorf("1kHz tone RMS = %.1f, want %.1f", left, full)
+			}
+			right := rms(samples[1])
+			if tt.wantRate == 96000 && math.Abs(right/full-1) > 0.01 {
+				t.Errorf("30kHz tone RMS = %.1f, want %.1f", right, full)
+			}
+			// at least 80 dB down, the dither noise of about half a bit remains at 16 bits
+			if tt.wantRate < 60000 && right > full*1e-4 {
+				t.Errorf("30kHz tone RMS = %.1f after resampling to %d Hz, want it filtered out", right, tt.wantRate)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := Convert(ctx, info, frames, Target{SampleRate: 44100, BitsPerSample: 16}); err == nil {
+		t.Error("Convert() with cancelled context returned no error")
+	}
+}
@@ -0,0 +1,47 @@
+package flacstream
+
+// crc8Table and crc16Table are lookup tables of FLAC frame checksums,
+// CRC-8 with polynomial x^8+x^2+x+1 and CRC-16 with polynomial x^16+x^15+x^2+1
+var (
+	crc8Table  [256]uint8
+	crc16Table [256]uint16
+)
+
+func init() {
+	for i := range 256 {
+		c8 := uint8(i)
+		c16 := uint16(i) << 8
+		for range 8 {
+			if c8&0x80 != 0 {
+				c8 = c8<<1 ^ 0x07
+			} else {
+				c8 <<= 1
+			}
+			if c16&0x8000 != 0 {
+				c16 = c16<<1 ^ 0x8005
+			} else {
+				c16 <<= 1
+			}
+		}
+		crc8Table[i] = c8
+		crc16Table[i] = c16
+	}
+}
+
+// crc8 computes the checksum of a frame header
+func crc8(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		crc = crc8Table[crc^b]
+	}
+	return crc
+}
+
+// crc16 computes the checksum of a whole frame
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
@@ -0,0 +1,657 @@
+package flacstream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamInfo is the content of the STREAMINFO metadata block
+type StreamInfo struct {
+	MinBlockSize  int
+	MaxBlockSize  int
+	MinFrameSize  int
+	MaxFrameSize  int
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	// TotalSamples is the number of samples per channel, 0 means unknown
+	TotalSamples uint64
+	// MD5 is the checksum of decoded samples, all zeros means unknown
+	MD5 [16]byte
+}
+
+// Frame is a decoded audio frame
+type Frame struct {
+	BlockSize     int
+	SampleRate    int
+	BitsPerSample int
+	// Samples holds decoded samples of every channel
+	Samples [][]int32
+}
+
+// Decoder decodes FLAC audio frames from an in-memory stream or a reader
+type Decoder struct {
+	Info StreamInfo
+
+	// frames is the offset of the first audio frame
+	frames int
+	r      bitReader
+	// decoded is the number of samples per channel decoded so far
+	decoded uint64
+}
+
+// FLAC metadata block types used by the decoder
+const (
+	blockStreamInfo = 0
+)
+
+// NewDecoder parses metadata of the FLAC stream and prepares decoding of its frames
+func NewDecoder(data []byte) (*Decoder, error) {
+	if len(data) < 4 || string(data[:4]) != "fLaC" {
+		return nil, fmt.Errorf("not a FLAC stream")
+	}
+
+	d := &Decoder{}
+	offset := 4
+	first := true
+	for {
+		if offset+4 > len(data) {
+			return nil, fmt.Errorf("metadata block header: %w", io.ErrUnexpectedEOF)
+		}
+		last := data[offset]&0x80 != 0
+		blockType := data[offset] & 0x7f
+		length := int(binary.BigEndian.Uint32(data[offset:]) & 0xffffff)
+		offset += 4
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("metadata block: %w", io.ErrUnexpectedEOF)
+		}
+
+		if first {
+			if blockType != blockStreamInfo || length < 34 {
+				return nil, fmt.Errorf("STREAMINFO must be the first metadata block")
+			}
+			d.Info = parseStreamInfo(data[offset : offset+length])
+			first = false
+		}
+
+		offset += length
+		if last {
+			break
+		}
+	}
+
+	if !d.Info.valid() {
+		return nil, fmt.Errorf("invalid STREAMINFO")
+	}
+
+	d.frames = offset
+	d.r = bitReader{data: data, pos: offset}
+	return d, nil
+}
+
+// NewStreamDecoder reads metadata of the FLAC stream from r and prepares decoding of its frames,
+// frames are read from r while they are decoded, so only the current one is kept in memory
+func NewStreamDecoder(r *bufio.Reader) (*Decoder, error) {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(r, head); err != nil || string(head) != "fLaC" {
+		return nil, fmt.Errorf("not a FLAC stream")
+	}
+
+	d := &Decoder{}
+	offset := 4
+	header := make([]byte, 4)
+	first := true
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, fmt.Errorf("metadata block header: %w", io.ErrUnexpectedEOF)
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		length := int(binary.BigEndian.Uint32(header) & 0xffffff)
+		offset += 4 + length
+
+		if first {
+			if blockType != blockStreamInfo || length < 34 {
+				return nil, fmt.Errorf("STREAMINFO must be the first metadata block")
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("metadata block: %w", io.ErrUnexpectedEOF)
+			}
+			d.Info = parseStreamInfo(data)
+			first = false
+		} else if _, err := r.Discard(length); err != nil {
+			return nil, fmt.Errorf("metadata block: %w", io.ErrUnexpectedEOF)
+		}
+
+		if last {
+			break
+		}
+	}
+
+	if !d.Info.valid() {
+		return nil, fmt.Errorf("invalid STREAMINFO")
+	}
+
+	d.frames = offset
+	d.r = bitReader{src: r, base: offset}
+	return d, nil
+}
+
+// NewFrameDecoder prepares decoding of audio frames of a stream described by info,
+// frames holds the stream without its "fLaC" marker and metadata blocks
+func NewFrameDecoder(info StreamInfo, frames []byte) (*Decoder, error) {
+	if !info.valid() {
+		return nil, fmt.Errorf("invalid STREAMINFO")
+	}
+	return &Decoder{Info: info, r: bitReader{data: frames}}, nil
+}
+
+// ParseStreamInfo decodes STREAMINFO block data
+func ParseStreamInfo(data []byte) (StreamInfo, error) {
+	if len(data) < 34 {
+		return StreamInfo{}, fmt.Errorf("STREAMINFO is truncated")
+	}
+	return parseStreamInfo(data), nil
+}
+
+// valid checks if decoding of the stream is possible
+func (info StreamInfo) valid() bool {
+	return info.Channels > 0 && (info.SampleRate > 0 || info.TotalSamples == 0)
+}
+
+// Marshal encodes the STREAMINFO block data
+func (info StreamInfo) Marshal() []byte {
+	data := make([]byte, 34)
+	binary.BigEndian.PutUint16(data[0:], uint16(info.MinBlockSize))
+	binary.BigEndian.PutUint16(data[2:], uint16(info.MaxBlockSize))
+	data[4], data[5], data[6] = byte(info.MinFrameSize>>16), byte(info.MinFrameSize>>8), byte(info.MinFrameSize)
+	data[7], data[8], data[9] = byte(info.MaxFrameSize>>16), byte(info.MaxFrameSize>>8), byte(info.MaxFrameSize)
+	packed := uint64(info.SampleRate)<<44 | uint64(info.Channels-1)<<41 | uint64(info.BitsPerSample-1)<<36 | info.TotalSamples&0xfffffffff
+	binary.BigEndian.PutUint64(data[10:], packed)
+	copy(data[18:], info.MD5[:])
+	return data
+}
+
+// parseStreamInfo decodes the STREAMINFO block
+func parseStreamInfo(data []byte) StreamInfo {
+	var info StreamInfo
+	info.MinBlockSize = int(binary.BigEndian.Uint16(data[0:]))
+	info.MaxBlockSize = int(binary.BigEndian.Uint16(data[2:]))
+	info.MinFrameSize = int(data[4])<<16 | int(data[5])<<8 | int(data[6])
+	info.MaxFrameSize = int(data[7])<<16 | int(data[8])<<8 | int(data[9])
+	packed := binary.BigEndian.Uint64(data[10:])
+	info.SampleRate = int(packed >> 44)
+	info.Channels = int(packed>>41&0x7) + 1
+	info.BitsPerSample = int(packed>>36&0x1f) + 1
+	info.TotalSamples = packed & 0xfffffffff
+	copy(info.MD5[:], data[18:34])
+	return info
+}
+
+// Next decodes the next audio frame, io.EOF is returned after the last frame
+func (d *Decoder) Next() (*Frame, error) {
+	if d.Info.TotalSamples > 0 && d.decoded >= d.Info.TotalSamples {
+		// the rest may be a tag appended by some taggers
+		return nil, io.EOF
+	}
+	d.r.discard()
+	start := d.r.offset()
+	if d.r.atEnd() {
+		if d.r.err != nil && d.r.err != io.EOF {
+			return nil, d.r.err
+		}
+		if d.Info.TotalSamples > 0 {
+			return nil, fmt.Errorf("stream ends after %d of %d samples", d.decoded, d.Info.TotalSamples)
+		}
+		return nil, io.EOF
+	}
+
+	frame, err := d.decodeFrame()
+	if err != nil {
+		if d.r.err != nil && d.r.err != io.EOF {
+			return nil, d.r.err
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("frame at offset %d is truncated", start)
+		}
+		return nil, fmt.Errorf("frame at offset %d: %s", start, err)
+	}
+
+	// the frame footer is the CRC-16 of the whole frame
+	d.r.align()
+	end := d.r.offset()
+	footer, err := d.r.readBits(16)
+	if err != nil {
+		return nil, fmt.Errorf("frame at offset %d is truncated", start)
+	}
+	if uint16(footer) != crc16(d.r.bytes(start, end)) {
+		return nil, fmt.Errorf("frame at offset %d has wrong CRC-16", start)
+	}
+
+	d.decoded += uint64(frame.BlockSize)
+	return frame, nil
+}
+
+// Reset rewinds the decoder to the first audio frame, streams read from a reader can't be rewound
+func (d *Decoder) Reset() {
+	d.r.seek(d.frames)
+	d.decoded = 0
+}
+
+// channel assignments with inter-channel decorrelation
+const (
+	channelsLeftSide  = 8
+	channelsRightSide = 9
+	channelsMidSide   = 10
+)
+
+// decodeFrame decodes the frame header and all subframes
+func (d *Decoder) decodeFrame() (*Frame, error) {
+	start := d.r.offset()
+	r := &d.r
+
+	sync, err := r.readBits(14)
+	if err != nil {
+		return nil, err
+	}
+	if sync != 0x3ffe {
+		return nil, fmt.Errorf("frame sync code not found")
+	}
+	// a reserved bit and the blocking strategy, which doesn't matter for sequential decoding
+	reserved, err := r.readBits(2)
+	if err != nil {
+		return nil, err
+	}
+	if reserved&2 != 0 {
+		return nil, fmt.Errorf("reserved bit is set")
+	}
+	// block size, sample rate, channels, sample size and another reserved bit
+	fields, err := r.readBits(16)
+	if err != nil {
+		return nil, err
+	}
+	blockSizeCode := fields >> 12
+	sampleRateCode := fields >> 8 & 0xf
+	channelAssignment := int(fields >> 4 & 0xf)
+	sampleSizeCode := fields >> 1 & 0x7
+	if fields&1 != 0 {
+		return nil, fmt.Errorf("reserved bit is set")
+	}
+
+	// frame or sample number, coded like UTF-8, only its length matters here
+	if err := skipCodedNumber(r); err != nil {
+		return nil, err
+	}
+
+	frame := &Frame{}
+	switch {
+	case blockSizeCode == 0:
+		return nil, fmt.Errorf("reserved block size")
+	case blockSizeCode == 1:
+		frame.BlockSize = 192
+	case blockSizeCode <= 5:
+		frame.BlockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6:
+		v, err := r.readBits(8)
+		if err != nil {
+			return nil, err
+		}
+		frame.BlockSize = int(v) + 1
+	case blockSizeCode == 7:
+		v, err := r.readBits(16)
+		if err != nil {
+			return nil, err
+		}
+		frame.BlockSize = int(v) + 1
+	default:
+		frame.BlockSize = 256 << (blockSizeCode - 8)
+	}
+
+	switch sampleRateCode {
+	case 0:
+		frame.SampleRate = d.Info.SampleRate
+	case 12:
+		v, err := r.readBits(8)
+		if err != nil {
+			return nil, err
+		}
+		frame.SampleRate = int(v) * 1000
+	case 13, 14:
+		v, err := r.readBits(16)
+		if err != nil {
+			return nil, err
+		}
+		frame.SampleRate = int(v)
+		if sampleRateCode == 14 {
+			frame.SampleRate *= 10
+		}
+	case 15:
+		return nil, fmt.Errorf("invalid sample rate")
+	default:
+		frame.SampleRate = []int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}[sampleRateCode]
+	}
+
+	switch sampleSizeCode {
+	case 0:
+		frame.BitsPerSample = d.Info.BitsPerSample
+	case 3:
+		return nil, fmt.Errorf("reserved sample size")
+	default:
+		frame.BitsPerSample = []int{0, 8, 12, 0, 16, 20, 24, 32}[sampleSizeCode]
+	}
+
+	channels := channelAssignment + 1
+	switch {
+	case channelAssignment >= channelsLeftSide && channelAssignment <= channelsMidSide:
+		channels = 2
+	case channelAssignment > channelsMidSide:
+		return nil, fmt.Errorf("reserved channel assignment")
+	}
+	if channels != d.Info.Channels {
+		return nil, fmt.Errorf("frame has %d channels, stream has %d", channels, d.Info.Channels)
+	}
+
+	// the header ends with its CRC-8
+	end := r.offset()
+	headerCRC, err := r.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+	if uint8(headerCRC) != crc8(d.r.bytes(start, end)) {
+		return nil, fmt.Errorf("frame header has wrong CRC-8")
+	}
+
+	frame.Samples = make([][]int32, channels)
+	for ch := range channels {
+		bps := frame.BitsPerSample
+		// side channels need one more bit
+		if channelAssignment == channelsLeftSide && ch == 1 ||
+			channelAssignment == channelsRightSide && ch == 0 ||
+			channelAssignment == channelsMidSide && ch == 1 {
+			bps++
+		}
+		frame.Samples[ch] = make([]int32, frame.BlockSize)
+		if err := decodeSubframe(r, frame.Samples[ch], bps); err != nil {
+			return nil, fmt.Errorf("channel %d: %w", ch, err)
+		}
+	}
+
+	decorrelate(frame.Samples, channelAssignment)
+	return frame, nil
+}
+
+// skipCodedNumber skips the UTF-8 like coded frame or sample number
+func skipCodedNumber(r *bitReader) error {
+	first, err := r.readBits(8)
+	if err != nil {
+		return err
+	}
+	var extra int
+	for mask := uint64(0x80); first&mask != 0 && mask > 1; mask >>= 1 {
+		extra++
+	}
+	switch {
+	case extra == 1 || extra > 7:
+		return fmt.Errorf("invalid frame number")
+	case extra > 1:
+		extra--
+	}
+	for range extra {
+		b, err := r.readBits(8)
+		if err != nil {
+			return err
+		}
+		if b&0xc0 != 0x80 {
+			return fmt.Errorf("invalid frame number")
+		}
+	}
+	return nil
+}
+
+// decorrelate restores left and right channels from side channel encodings
+func decorrelate(samples [][]int32, channelAssignment int) {
+	switch channelAssignment {
+	case channelsLeftSide:
+		for i, side := range samples[1] {
+			samples[1][i] = samples[0][i] - side
+		}
+	case channelsRightSide:
+		for i, side := range samples[0] {
+			samples[0][i] = side + samples[1][i]
+		}
+	case channelsMidSide:
+		for i, side := range samples[1] {
+			mid := int64(samples[0][i])<<1 | int64(side)&1
+			samples[0][i] = int32((mid + int64(side)) >> 1)
+			samples[1][i] = int32((mid - int64(side)) >> 1)
+		}
+	}
+}
+
+// subframe types
+const (
+	subframeConstant = 0
+	subframeVerbatim = 1
+	subframeFixed    = 8
+	subframeLPC      = 32
+)
+
+// decodeSubframe decodes samples of a single channel
+func decodeSubframe(r *bitReader, samples []int32, bps int) error {
+	header, err := r.readBits(8)
+	if err != nil {
+		return err
+	}
+	if header&0x80 != 0 {
+		return fmt.Errorf("subframe padding bit is set")
+	}
+	subframeType := int(header >> 1 & 0x3f)
+
+	// wasted bits are zero low-order bits of every sample
+	wasted := 0
+	if header&1 != 0 {
+		k, err := r.readUnary()
+		if err != nil {
+			return err
+		}
+		wasted = int(k) + 1
+		bps -= wasted
+	}
+	if bps <= 0 || bps > 32 {
+		return fmt.Errorf("unsupported sample size of %d bits", bps)
+	}
+
+	switch {
+	case subframeType == subframeConstant:
+		v, err := r.readSigned(uint(bps))
+		if err != nil {
+			return err
+		}
+		for i := range samples {
+			samples[i] = int32(v)
+		}
+	case subframeType == subframeVerbatim:
+		for i := range samples {
+			v, err := r.readSigned(uint(bps))
+			if err != nil {
+				return err
+			}
+			samples[i] = int32(v)
+		}
+	case subframeType >= subframeFixed && subframeType <= subframeFixed+4:
+		if err := decodeFixed(r, samples, bps, subframeType-subframeFixed); err != nil {
+			return err
+		}
+	case subframeType >= subframeLPC:
+		if err := decodeLPC(r, samples, bps, subframeType-subframeLPC+1); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("reserved subframe type %d", subframeType)
+	}
+
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+// decodeWarmup reads unencoded samples preceding the predicted ones
+func decodeWarmup(r *bitReader, samples []int32, bps, order int) error {
+	if order > len(samples) {
+		return fmt.Errorf("predictor order %d exceeds block size %d", order, len(samples))
+	}
+	for i := range order {
+		v, err := r.readSigned(uint(bps))
+		if err != nil {
+			return err
+		}
+		samples[i] = int32(v)
+	}
+	return nil
+}
+
+// decodeFixed decodes a subframe predicted by a fixed polynomial
+func decodeFixed(r *bitReader, samples []int32, bps, order int) error {
+	if err := decodeWarmup(r, samples, bps, order); err != nil {
+		return err
+	}
+	if err := decodeResidual(r, samples, order); err != nil {
+		return err
+	}
+
+	s := samples
+	switch order {
+	case 1:
+		for i := 1; i < len(s); i++ {
+			s[i] += s[i-1]
+		}
+	case 2:
+		for i := 2; i < len(s); i++ {
+			s[i] += 2*s[i-1] - s[i-2]
+		}
+	case 3:
+		for i := 3; i < len(s); i++ {
+			s[i] += 3*s[i-1] - 3*s[i-2] + s[i-3]
+		}
+	case 4:
+		for i := 4; i < len(s); i++ {
+			s[i] += 4*s[i-1] - 6*s[i-2] + 4*s[i-3] - s[i-4]
+		}
+	}
+	return nil
+}
+
+// decodeLPC decodes a subframe predicted by linear prediction coefficients
+func decodeLPC(r *bitReader, samples []int32, bps, order int) error {
+	if err := decodeWarmup(r, samples, bps, order); err != nil {
+		return err
+	}
+
+	precision, err := r.readBits(4)
+	if err != nil {
+		return err
+	}
+	if precision == 15 {
+		return fmt.Errorf("invalid coefficient precision")
+	}
+	shift, err := r.readSigned(5)
+	if err != nil {
+		return err
+	}
+	if shift < 0 {
+		return fmt.Errorf("negative coefficient shift")
+	}
+	coeffs := make([]int64, order)
+	for i := range coeffs {
+		if coeffs[i], err = r.readSigned(uint(precision) + 1); err != nil {
+			return err
+		}
+	}
+
+	if err := decodeResidual(r, samples, order); err != nil {
+		return err
+	}
+
+	for i := order; i < len(samples); i++ {
+		var sum int64
+		for j, c := range coeffs {
+			sum += c * int64(samples[i-j-1])
+		}
+		samples[i] += int32(sum >> shift)
+	}
+	return nil
+}
+
+// decodeResidual decodes Rice coded prediction errors into samples after the warmup ones
+func decodeResidual(r *bitReader, samples []int32, order int) error {
+	method, err := r.readBits(2)
+	if err != nil {
+		return err
+	}
+	var paramBits uint
+	switch method {
+	case 0:
+		paramBits = 4
+	case 1:
+		paramBits = 5
+	default:
+		return fmt.Errorf("reserved residual coding method")
+	}
+	escape := uint64(1)<<paramBits - 1
+
+	partitionOrder, err := r.readBits(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	partitionSize := len(samples) >> partitionOrder
+	if partitionSize<<partitionOrder != len(samples) || partitionSize < order {
+		return fmt.Errorf("invalid partition order %d", partitionOrder)
+	}
+
+	i := order
+	for p := range partitions {
+		end := (p + 1) * partitionSize
+		param, err := r.readBits(paramBits)
+		if err != nil {
+			return err
+		}
+
+		if param == escape {
+			// the partition is stored unencoded with the given number of bits
+			n, err := r.readBits(5)
+			if err != nil {
+				return err
+			}
+			for ; i < end; i++ {
+				v, err := r.readSigned(uint(n))
+				if err != nil {
+					return err
+				}
+				samples[i] = int32(v)
+			}
+			continue
+		}
+
+		for ; i < end; i++ {
+			q, err := r.readUnary()
+			if err != nil {
+				return err
+			}
+			low, err := r.readBits(uint(param))
+			if err != nil {
+				return err
+			}
+			u := q<<param | low
+			samples[i] = int32(u>>1) ^ -int32(u&1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package flacstream
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSubframe describes how a test channel is encoded
+type testSubframe struct {
+	kind   int
+	order  int
+	coeffs []int64
+	shift  int
+	wasted int
+	escape bool
+}
+
+// writeSubframe encodes the samples of a channel the way the subframe describes
+func writeSubframe(w *bitWriter, samples []int32, bps int, sf testSubframe) {
+	header := uint64(sf.kind)
+	switch sf.kind {
+	case subframeFixed:
+		header += uint64(sf.order)
+	case subframeLPC:
+		header += uint64(sf.order - 1)
+	}
+	w.writeBits(header<<1|boolBit(sf.wasted > 0), 8)
+	if sf.wasted > 0 {
+		w.writeUnary(uint64(sf.wasted - 1))
+		bps -= sf.wasted
+		shifted := make([]int32, len(samples))
+		for i, s := range samples {
+			shifted[i] = s >> sf.wasted
+		}
+		samples = shifted
+	}
+
+	switch sf.kind {
+	case subframeConstant:
+		w.writeSigned(int64(samples[0]), uint(bps))
+		return
+	case subframeVerbatim:
+		for _, s := range samples {
+			w.writeSigned(int64(s), uint(bps))
+		}
+		return
+	}
+
+	for _, s := range samples[:sf.order] {
+		w.writeSigned(int64(s), uint(bps))
+	}
+	residual := make([]int64, len(samples))
+	if sf.kind == subframeFixed {
+		fixed := [][]int64{{}, {1}, {2, -1}, {3, -3, 1}, {4, -6, 4, -1}}[sf.order]
+		for i := sf.order; i < len(samples); i++ {
+			var prediction int64
+			for j, c := range fixed {
+				prediction += c * int64(samples[i-j-1])
+			}
+			residual[i] = int64(samples[i]) - prediction
+		}
+	} else {
+		w.writeBits(14, 4)
+		w.writeSigned(int64(sf.shift), 5)
+		for _, c := range sf.coeffs {
+			w.writeSigned(c, 15)
+		}
+		for i := sf.order; i < len(samples); i++ {
+			var sum int64
+			for j, c := range sf.coeffs {
+				sum += c * int64(samples[i-j-1])
+			}
+			residual[i] = int64(samples[i]) - sum>>sf.shift
+		}
+	}
+
+	// two partitions, the first with a 4-bit Rice parameter or unencoded
+	w.writeBits(0, 2)
+	w.writeBits(1, 4)
+	half := len(samples) / 2
+	for p, part := range [][]int64{residual[sf.order:half], residual[half:]} {
+		if sf.escape && p == 0 {
+			w.writeBits(15, 4)
+			w.writeBits(uint64(bps+2), 5)
+			for _, r := range part {
+				w.writeSigned(r, uint(bps+2))
+			}
+			continue
+		}
+		const param = 3
+		w.writeBits(param, 4)
+		for _, r := range part {
+			u := uint64(r<<1) ^ uint64(r>>63)
+			w.writeUnary(u >> param)
+			w.writeBits(u&(1<<param-1), param)
+		}
+	}
+}
+
+func boolBit(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// encodeTestStream builds a FLAC stream of 16-bit stereo frames, one frame per channel assignment
+func encodeTestStream(frames [][2][]int32, assignments []int, subframes [][2]testSubframe) []byte {
+	const bps = 16
+	var total int
+	hash := md5.New()
+	for _, frame := range frames {
+		total += len(frame[0])
+		for i := range frame[0] {
+			for _, channel := range frame {
+				hash.Write([]byte{byte(channel[i]), byte(channel[i] >> 8)})
+			}
+		}
+	}
+
+	data := []byte("fLaC")
+	// STREAMINFO is the last metadata block
+	data = append(data, 0x80, 0, 0, 34)
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint16(info[0:], 16)
+	binary.BigEndian.PutUint16(info[2:], 4096)
+	packed := uint64(44100)<<44 | uint64(1)<<41 | uint64(bps-1)<<36 | uint64(total)
+	binary.BigEndian.PutUint64(info[10:], packed)
+	copy(info[18:], hash.Sum(nil))
+	data = append(data, info...)
+
+	for n, frame := range frames {
+		w := &bitWriter{}
+		w.writeBits(0x3ffe, 14)
+		w.writeBits(0, 2)
+		// block size in 16 bits after the frame number, 44.1kHz, 16 bits per sample
+		w.writeBits(7, 4)
+		w.writeBits(9, 4)
+		w.writeBits(uint64(assignments[n]), 4)
+		w.writeBits(4, 3)
+		w.writeBits(0, 1)
+		w.writeBits(uint64(n), 8)
+		w.writeBits(uint64(len(frame[0])-1), 16)
+		w.writeBits(uint64(crc8(w.data)), 8)
+
+		left, right := frame[0], frame[1]
+		channels := [2][]int32{left, right}
+		bits := [2]int{bps, bps}
+		side := make([]int32, len(left))
+		for i := range left {
+			side[i] = left[i] - right[i]
+		}
+		switch assignments[n] {
+		case channelsLeftSide:
+			channels[1], bits[1] = side, bps+1
+		case channelsRightSide:
+			channels[0], bits[0] = side, bps+1
+		case channelsMidSide:
+			mid := make([]int32, len(left))
+			for i := range left {
+				mid[i] = (left[i] + right[i]) >> 1
+			}
+			channels[0], channels[1], bits[1] = mid, side, bps+1
+		}
+		for ch := range channels {
+			writeSubframe(w, channels[ch], bits[ch], subframes[n][ch])
+		}
+
+		w.align()
+		footer := crc16(w.data)
+		data = append(data, w.data...)
+		data = append(data, byte(footer>>8), byte(footer))
+	}
+	return data
+}
+
+// testFrames returns frames of a sine wave with a bit of noise and a frame of quantized samples
+func testFrames() [][2][]int32 {
+	frames := make([][2][]int32, 6)
+	t := 0
+	for n := range frames {
+		for ch := range 2 {
+			frames[n][ch] = make([]int32, 256)
+		}
+		for i := range 256 {
+			v := 12000 * math.Sin(float64(t)/20)
+			frames[n][0][i] = int32(v) + int32(t*7%13) - 6
+			frames[n][1][i] = int32(v*0.8) - int32(t*5%11) + 5
+			t++
+		}
+	}
+	// the last frame only has even samples, so it can use wasted bits
+	for ch := range 2 {
+		for i := range frames[5][ch] {
+			frames[5][ch][i] &^= 1
+		}
+	}
+	// the first frame is silent on the left
+	for i := range frames[0][0] {
+		frames[0][0][i] = -3
+	}
+	return frames
+}
+
+func testStream() ([][2][]int32, []byte) {
+	frames := testFrames()
+	assignments := []int{1, 1, channelsLeftSide, channelsRightSide, channelsMidSide, 1}
+	subframes := [][2]testSubframe{
+		{{kind: subframeConstant}, {kind: subframeVerbatim}},
+		{{kind: subframeFixed, order: 0}, {kind: subframeFixed, order: 1}},
+		{{kind: subframeFixed, order: 2}, {kind: subframeFixed, order: 3, escape: true}},
+		{{kind: subframeFixed, order: 4}, {kind: subframeLPC, order: 2, coeffs: []int64{2, -1}}},
+		{{kind: subframeLPC, order: 1, coeffs: []int64{31}, shift: 5}, {kind: subframeLPC, order: 3, coeffs: []int64{6, -6, 2}, shift: 1, escape: true}},
+		{{kind: subframeVerbatim, wasted: 1}, {kind: subframeFixed, order: 2, wasted: 1}},
+	}
+	return frames, encodeTestStream(frames, assignments, subframes)
+}
+
+func TestDecoder(t *testing.T) {
+	frames, data := testStream()
+
+	memory, err := NewDecoder(data)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	// frames of a stream decoder are read while they are decoded
+	stream, err := NewStreamDecoder(bufio.NewReaderSize(bytes.NewReader(data), 16))
+	if err != nil {
+		t.Fatalf("NewStreamDecoder() error = %v", err)
+	}
+
+	for _, d := range []*Decoder{memory, stream} {
+		if d.Info.SampleRate != 44100 || d.Info.Channels != 2 || d.Info.BitsPerSample != 16 || d.Info.TotalSamples != 6*256 {
+			t.Errorf("unexpected stream info %+v", d.Info)
+		}
+
+		for n, want := range frames {
+			frame, err := d.Next()
+			if err != nil {
+				t.Fatalf("Next() frame %d error = %v", n, err)
+			}
+			for ch := range want {
+				for i := range want[ch] {
+					if frame.Samples[ch][i] != want[ch][i] {
+						t.Fatalf("frame %d channel %d sample %d = %d, want %d", n, ch, i, frame.Samples[ch][i], want[ch][i])
+					}
+				}
+			}
+		}
+		if _, err := d.Next(); err == nil {
+			t.Errorf("Next() after the last frame returned no error")
+		}
+	}
+	if len(stream.r.data) >= len(data)/2 {
+		t.Errorf("stream decoder keeps %d of %d bytes in memory", len(stream.r.data), len(data))
+	}
+
+	if err := VerifyData(data); err != nil {
+		t.Errorf("VerifyData() error = %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if err := Verify("../../test_data/01 - test.flac"); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "albumpicker_flacstream_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	_, data := testStream()
+	audio := 4 + 4 + 34
+
+	tests := []struct {
+		name    string
+		modify  func([]byte) []byte
+		wantErr string
+	}{
+		{
+			name: "flipped sample bit",
+			modify: func(data []byte) []byte {
+				data[len(data)-100] ^= 0x10
+				return data
+			},
+			wantErr: "CRC-16",
+		},
+		{
+			name: "flipped header bit",
+			modify: func(data []byte) []byte {
+				data[audio+4] ^= 0x01
+				return data
+			},
+			wantErr: "CRC-8",
+		},
+		{
+			name: "truncated",
+			modify: func(data []byte) []byte {
+				return data[:len(data)-300]
+			},
+			wantErr: "truncated",
+		},
+		{
+			name: "wrong checksum",
+			modify: func(data []byte) []byte {
+				data[8+18] ^= 0xff
+				return data
+			},
+			wantErr: "MD5",
+		},
+		{
+			name: "missing checksum",
+			modify: func(data []byte) []byte {
+				copy(data[8+18:8+34], make([]byte, 16))
+				return data
+			},
+			wantErr: ErrNoChecksum.Error(),
+		},
+		{
+			name: "not a FLAC file",
+			modify: func(data []byte) []byte {
+				return []byte("ID3 not a FLAC file")
+			},
+			wantErr: "not a FLAC stream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified := tt.modify(append([]byte(nil), data...))
+			path := filepath.Join(tmpDir, "test.flac")
+			if err := os.WriteFile(path, modified, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Verify(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			if dataErr := VerifyData(modified); fmt.Sprint(dataErr) != fmt.Sprint(err) {
+				t.Errorf("VerifyData() error = %v, Verify() error = %v", dataErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package flacstream
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Ditherer quantizes samples to integers with triangular (TPDF) dither of one least significant bit,
+// which turns quantization distortion into a constant noise floor
+type Ditherer struct {
+	rng *rand.Rand
+	// min and max are the limits of samples of the output bit depth
+	min, max float64
+}
+
+// NewDitherer creates a ditherer to the bit depth, its noise is seeded the same way every time,
+// so converting a file again gives the same output
+func NewDitherer(bitsPerSample int) *Ditherer {
+	limit := math.Ldexp(1, bitsPerSample-1)
+	return &Ditherer{rng: rand.New(rand.NewPCG(1, 2)), min: -limit, max: limit - 1}
+}
+
+// Quantize rounds samples measured in least significant bits of the output to integers
+func (d *Ditherer) Quantize(samples []float64) []int32 {
+	out := make([]int32, len(samples))
+	for i, v := range samples {
+		v = math.Round(v + d.rng.Float64() - d.rng.Float64())
+		out[i] = int32(min(max(v, d.min), d.max))
+	}
+	return out
+}
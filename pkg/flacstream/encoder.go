@@ -0,0 +1,442 @@
+package flacstream
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash"
+)
+
+// BlockSize is the number of samples per channel of frames written by the Encoder
+const BlockSize = 4096
+
+const (
+	// maxPartitionOrder limits the search of residual partitions
+	maxPartitionOrder = 8
+	// maxRiceParam is the largest Rice parameter of the 5-bit coding method, 31 is the escape code
+	maxRiceParam = 30
+	// maxSampleRate is the largest sample rate frame headers can carry
+	maxSampleRate = 655350
+)
+
+// Encoder encodes audio to FLAC frames with fixed predictors, every frame gets the cheapest
+// of independent and decorrelated stereo channels
+type Encoder struct {
+	info StreamInfo
+	// pending holds samples of every channel not making up a whole block yet
+	pending [][]int32
+	frames  []byte
+	number  uint64
+	w       bitWriter
+	hash    hash.Hash
+	buf     []byte
+}
+
+// NewEncoder prepares encoding of audio with the sample rate, number of channels and bits per sample
+func NewEncoder(sampleRate, channels, bitsPerSample int) (*Encoder, error) {
+	if sampleRate <= 0 || sampleRate > maxSampleRate {
+		return nil, fmt.Errorf("unsupported sample rate of %d Hz", sampleRate)
+	}
+	if channels < 1 || channels > 8 {
+		return nil, fmt.Errorf("unsupported number of channels %d", channels)
+	}
+	// side channels of 24-bit audio still fit into 32-bit residuals
+	if bitsPerSample < 4 || bitsPerSample > 24 {
+		return nil, fmt.Errorf("unsupported sample size of %d bits", bitsPerSample)
+	}
+	return &Encoder{
+		info: StreamInfo{
+			MinBlockSize:  BlockSize,
+			MaxBlockSize:  BlockSize,
+			SampleRate:    sampleRate,
+			Channels:      channels,
+			BitsPerSample: bitsPerSample,
+		},
+		pending: make([][]int32, channels),
+		hash:    md5.New(),
+	}, nil
+}
+
+// Write encodes samples of every channel, samples not filling a whole block are kept for the next call
+func (e *Encoder) Write(samples [][]int32) error {
+	if len(samples) != e.info.Channels {
+		return fmt.Errorf("got %d channels, stream has %d", len(samples), e.info.Channels)
+	}
+	limit := int32(1) << (e.info.BitsPerSample - 1)
+	for ch, channel := range samples {
+		if len(channel) != len(samples[0]) {
+			return fmt.Errorf("channels have different number of samples")
+		}
+		for _, v := range channel {
+			if v < -limit || v >= limit {
+				return fmt.Errorf("sample %d doesn't fit into %d bits", v, e.info.BitsPerSample)
+			}
+		}
+		e.pending[ch] = append(e.pending[ch], channel...)
+	}
+
+	n := len(e.pending[0])
+	start := 0
+	for ; start+BlockSize <= n; start += BlockSize {
+		block := make([][]int32, e.info.Channels)
+		for ch := range block {
+			block[ch] = e.pending[ch][start : start+BlockSize]
+		}
+		e.encodeFrame(block)
+	}
+	for ch := range e.pending {
+		e.pending[ch] = append(e.pending[ch][:0], e.pending[ch][start:]...)
+	}
+	return nil
+}
+
+// Finish encodes the remaining samples as the last frame and returns the STREAMINFO
+// and audio frames of the stream
+func (e *Encoder) Finish() (StreamInfo, []byte) {
+	if len(e.pending[0]) > 0 {
+		e.encodeFrame(e.pending)
+	}
+	copy(e.info.MD5[:], e.hash.Sum(nil))
+	return e.info, e.frames
+}
+
+// encodeFrame encodes a block of samples of every channel as a single frame
+func (e *Encoder) encodeFrame(block [][]int32) {
+	blockSize := len(block[0])
+	e.updateMD5(block)
+
+	assignment, subframes := e.chooseChannels(block)
+
+	w := &e.w
+	w.reset()
+	w.writeBits(0x3ffe, 14)
+	// a reserved bit and the fixed blocking strategy
+	w.writeBits(0, 2)
+	blockSizeCode, blockSizeBits := blockSizeCode(blockSize)
+	sampleRateCode, sampleRateBits, sampleRateValue := sampleRateCode(e.info.SampleRate)
+	w.writeBits(blockSizeCode, 4)
+	w.writeBits(sampleRateCode, 4)
+	w.writeBits(uint64(assignment), 4)
+	w.writeBits(sampleSizeCode(e.info.BitsPerSample), 3)
+	w.writeBits(0, 1)
+	writeCodedNumber(w, e.number)
+	w.writeBits(uint64(blockSize-1), blockSizeBits)
+	w.writeBits(sampleRateValue, sampleRateBits)
+	w.writeBits(uint64(crc8(w.data)), 8)
+
+	for _, sf := range subframes {
+		sf.write(w)
+	}
+
+	w.align()
+	w.writeBits(uint64(crc16(w.data)), 16)
+	e.frames = append(e.frames, w.data...)
+
+	size := len(w.data)
+	if e.info.MinFrameSize == 0 || size < e.info.MinFrameSize {
+		e.info.MinFrameSize = size
+	}
+	e.info.MaxFrameSize = max(e.info.MaxFrameSize, size)
+	e.info.TotalSamples += uint64(blockSize)
+	e.number++
+}
+
+// updateMD5 adds samples to the checksum the way MD5 computes it
+func (e *Encoder) updateMD5(block [][]int32) {
+	width := (e.info.BitsPerSample + 7) / 8
+	e.buf = e.buf[:0]
+	for i := range block[0] {
+		for _, channel := range block {
+			v := channel[i]
+			for b := range width {
+				e.buf = append(e.buf, byte(v>>(8*b)))
+			}
+		}
+	}
+	e.hash.Write(e.buf)
+}
+
+// chooseChannels returns the channel assignment and subframes of the block, stereo blocks are coded
+// as left and right, left and side, side and right or mid and side channels, whichever is the smallest
+func (e *Encoder) chooseChannels(block [][]int32) (int, []*subframe) {
+	bps := e.info.BitsPerSample
+	if len(block) != 2 {
+		subframes := make([]*subframe, len(block))
+		for ch, samples := range block {
+			subframes[ch] = analyzeSubframe(samples, bps)
+		}
+		return len(block) - 1, subframes
+	}
+
+	left, right := block[0], block[1]
+	mid := make([]int32, len(left))
+	side := make([]int32, len(left))
+	for i := range left {
+		mid[i] = (left[i] + right[i]) >> 1
+		side[i] = left[i] - right[i]
+	}
+	l, r := analyzeSubframe(left, bps), analyzeSubframe(right, bps)
+	m, s := analyzeSubframe(mid, bps), analyzeSubframe(side, bps+1)
+
+	assignment, subframes := 1, []*subframe{l, r}
+	bits := l.bits + r.bits
+	for _, candidate := range []struct {
+		assignment int
+		subframes  []*subframe
+	}{
+		{channelsLeftSide, []*subframe{l, s}},
+		{channelsRightSide, []*subframe{s, r}},
+		{channelsMidSide, []*subframe{m, s}},
+	} {
+		if b := candidate.subframes[0].bits + candidate.subframes[1].bits; b < bits {
+			assignment, subframes, bits = candidate.assignment, candidate.subframes, b
+		}
+	}
+	return assignment, subframes
+}
+
+// blockSizeCode returns the frame header code of the block size and the number of bits
+// of the block size stored after the frame number
+func blockSizeCode(blockSize int) (uint64, uint) {
+	switch {
+	case blockSize == 192:
+		return 1, 0
+	case blockSize <= 256:
+		return 6, 8
+	}
+	for code := uint64(2); code <= 5; code++ {
+		if blockSize == 576<<(code-2) {
+			return code, 0
+		}
+	}
+	for code := uint64(8); code <= 15; code++ {
+		if blockSize == 256<<(code-8) {
+			return code, 0
+		}
+	}
+	return 7, 16
+}
+
+// sampleRateCode returns the frame header code of the sample rate and the value
+// stored after the block size with its number of bits
+func sampleRateCode(sampleRate int) (uint64, uint, uint64) {
+	for code, rate := range []int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000} {
+		if code > 0 && rate == sampleRate {
+			return uint64(code), 0, 0
+		}
+	}
+	switch {
+	case sampleRate%1000 == 0 && sampleRate/1000 <= 0xff:
+		return 12, 8, uint64(sampleRate / 1000)
+	case sampleRate <= 0xffff:
+		return 13, 16, uint64(sampleRate)
+	case sampleRate%10 == 0:
+		return 14, 16, uint64(sampleRate / 10)
+	}
+	// the sample rate is taken from STREAMINFO
+	return 0, 0, 0
+}
+
+// sampleSizeCode returns the frame header code of the sample size
+func sampleSizeCode(bitsPerSample int) uint64 {
+	for code, bits := range []int{0, 8, 12, 0, 16, 20, 24, 32} {
+		if code > 0 && bits == bitsPerSample {
+			return uint64(code)
+		}
+	}
+	// the sample size is taken from STREAMINFO
+	return 0
+}
+
+// writeCodedNumber writes the frame number coded like UTF-8
+func writeCodedNumber(w *bitWriter, v uint64) {
+	if v < 0x80 {
+		w.writeBits(v, 8)
+		return
+	}
+	// n bytes hold 5n+1 bits of the number
+	n := uint(2)
+	for v >= 1<<(5*n+1) {
+		n++
+	}
+	w.writeBits(0xff<<(8-n)&0xff|v>>(6*(n-1)), 8)
+	for i := int(n) - 2; i >= 0; i-- {
+		w.writeBits(0x80|v>>(6*uint(i))&0x3f, 8)
+	}
+}
+
+// subframe is the cheapest coding found for samples of a channel
+type subframe struct {
+	samples []int32
+	bps     int
+	kind    int
+	order   int
+	// residual holds prediction errors, the first order values are unused
+	residual       []int32
+	partitionOrder int
+	params         []uint
+	// bits is the estimated size of the subframe
+	bits int
+}
+
+// analyzeSubframe finds the cheapest of constant, verbatim and fixed predictor subframes
+func analyzeSubframe(samples []int32, bps int) *subframe {
+	constant := true
+	for _, v := range samples[1:] {
+		if v != samples[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		return &subframe{samples: samples, bps: bps, kind: subframeConstant, bits: 8 + bps}
+	}
+
+	best := &subframe{samples: samples, bps: bps, kind: subframeVerbatim, bits: 8 + bps*len(samples)}
+	for order := 0; order <= 4 && order < len(samples); order++ {
+		residual := fixedResidual(samples, order)
+		partitionOrder, params, residualBits := riceParams(residual, order)
+		if bits := 8 + order*bps + residualBits; bits < best.bits {
+			best = &subframe{samples: samples, bps: bps, kind: subframeFixed, order: order,
+				residual: residual, partitionOrder: partitionOrder, params: params, bits: bits}
+		}
+	}
+	return best
+}
+
+// fixedResidual computes prediction errors of the fixed polynomial predictor of the order
+func fixedResidual(samples []int32, order int) []int32 {
+	residual := make([]int32, len(samples))
+	s := samples
+	for i := order; i < len(s); i++ {
+		switch order {
+		case 0:
+			residual[i] = s[i]
+		case 1:
+			residual[i] = s[i] - s[i-1]
+		case 2:
+			residual[i] = s[i] - 2*s[i-1] + s[i-2]
+		case 3:
+			residual[i] = s[i] - 3*s[i-1] + 3*s[i-2] - s[i-3]
+		case 4:
+			residual[i] = s[i] - 4*s[i-1] + 6*s[i-2] - 4*s[i-3] + s[i-4]
+		}
+	}
+	return residual
+}
+
+// zigzag folds a signed value into the unsigned one Rice codes store
+func zigzag(v int32) uint64 {
+	return uint64(uint32(v<<1 ^ v>>31))
+}
+
+// riceParams finds the partition order and Rice parameters coding the residual in the fewest bits,
+// the bits include the coding method and partition order fields
+func riceParams(residual []int32, order int) (int, []uint, int) {
+	n := len(residual)
+	maxOrder := 0
+	for maxOrder < maxPartitionOrder && n%(2<<maxOrder) == 0 && n>>(maxOrder+1) >= order {
+		maxOrder++
+	}
+
+	size := n >> maxOrder
+	sums := make([]uint64, 1<<maxOrder)
+	for i := order; i < n; i++ {
+		sums[i/size] += zigzag(residual[i])
+	}
+
+	bestOrder, bestBits := 0, -1
+	var bestParams []uint
+	for p := maxOrder; p >= 0; p-- {
+		partitions := 1 << p
+		params := make([]uint, partitions)
+		bits, paramBits := 2+4, 4
+		for j := range partitions {
+			count := n >> p
+			if j == 0 {
+				count -= order
+			}
+			k, b := riceParam(sums[j], count)
+			params[j] = k
+			bits += b
+			if k >= 15 {
+				paramBits = 5
+			}
+		}
+		bits += partitions * paramBits
+		if bestBits < 0 || bits < bestBits {
+			bestOrder, bestParams, bestBits = p, params, bits
+		}
+
+		// sums of the next lower order cover two partitions each
+		for j := range partitions / 2 {
+			sums[j] = sums[2*j] + sums[2*j+1]
+		}
+	}
+	return bestOrder, bestParams, bestBits
+}
+
+// riceParam estimates the parameter coding count values of the sum in the fewest bits
+func riceParam(sum uint64, count int) (uint, int) {
+	if count == 0 {
+		return 0, 0
+	}
+	best, bestBits := uint(0), uint64(count)+sum
+	for k := uint(1); k <= maxRiceParam && sum>>(k-1) > 0; k++ {
+		if bits := uint64(count)*uint64(k+1) + sum>>k; bits < bestBits {
+			best, bestBits = k, bits
+		}
+	}
+	return best, int(min(bestBits, 1<<40))
+}
+
+// write writes the subframe
+func (sf *subframe) write(w *bitWriter) {
+	header := uint64(sf.kind)
+	if sf.kind == subframeFixed {
+		header += uint64(sf.order)
+	}
+	// the padding bit, the type and no wasted bits
+	w.writeBits(header<<1, 8)
+
+	bps := uint(sf.bps)
+	switch sf.kind {
+	case subframeConstant:
+		w.writeSigned(int64(sf.samples[0]), bps)
+		return
+	case subframeVerbatim:
+		for _, v := range sf.samples {
+			w.writeSigned(int64(v), bps)
+		}
+		return
+	}
+
+	for _, v := range sf.samples[:sf.order] {
+		w.writeSigned(int64(v), bps)
+	}
+
+	paramBits := uint(4)
+	for _, k := range sf.params {
+		if k >= 15 {
+			paramBits = 5
+		}
+	}
+	w.writeBits(uint64(paramBits-4), 2)
+	w.writeBits(uint64(sf.partitionOrder), 4)
+
+	size := len(sf.residual) >> sf.partitionOrder
+	i := sf.order
+	for j, k := range sf.params {
+		w.writeBits(uint64(k), paramBits)
+		for end := (j + 1) * size; i < end; i++ {
+			u := zigzag(sf.residual[i])
+			q := u >> k
+			if q < 32 {
+				// the quotient in unary, its stop bit and the low-order bits at once
+				w.writeBits(1<<k|u&(1<<k-1), uint(q)+1+k)
+				continue
+			}
+			w.writeUnary(q)
+			w.writeBits(u, k)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package flacstream
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// filterHalfWidth is the half length of the lowpass filter in samples of the output rate
+	filterHalfWidth = 32
+	// kaiserBeta shapes the filter window for about 90 dB of alias rejection
+	kaiserBeta = 9
+)
+
+// Resampler lowers the sample rate of audio with a windowed sinc lowpass filter, the cutoff is
+// the Nyquist frequency of the output rate, so aliases only land above the top of the passband
+type Resampler struct {
+	// up and down are the output and input rates divided by their greatest common divisor
+	up, down int64
+	half     int
+	// filter holds coefficients of every output phase
+	filter [][]float64
+	// buf holds input samples of every channel, start is the input index of their first sample
+	buf   [][]float64
+	start int64
+	// next is the output index of the next sample, total counts input samples
+	next  int64
+	total int64
+}
+
+// NewResampler creates a resampler of audio with the number of channels from the input to the output rate
+func NewResampler(from, to, channels int) (*Resampler, error) {
+	if to <= 0 || from <= to {
+		return nil, fmt.Errorf("can't resample from %d Hz to %d Hz", from, to)
+	}
+	d := gcd(from, to)
+	r := &Resampler{up: int64(to / d), down: int64(from / d)}
+	r.half = int(math.Ceil(filterHalfWidth * float64(r.down) / float64(r.up)))
+
+	// the cutoff relative to the input rate
+	cutoff := 0.5 * float64(r.up) / float64(r.down)
+	r.filter = make([][]float64, r.up)
+	for phase := range r.filter {
+		coeffs := make([]float64, 2*r.half)
+		var sum float64
+		for k := range coeffs {
+			x := float64(r.half-1-k) + float64(phase)/float64(r.up)
+			coeffs[k] = sinc(2*cutoff*x) * kaiser(x/float64(r.half))
+			sum += coeffs[k]
+		}
+		// every phase passes DC unchanged
+		for k := range coeffs {
+			coeffs[k] /= sum
+		}
+		r.filter[phase] = coeffs
+	}
+
+	// samples before the start are silent
+	r.buf = make([][]float64, channels)
+	for ch := range r.buf {
+		r.buf[ch] = make([]float64, r.half)
+	}
+	r.start = -int64(r.half)
+	return r, nil
+}
+
+// Process takes input samples of every channel and returns the output samples they complete
+func (r *Resampler) Process(in [][]float64) [][]float64 {
+	for ch := range r.buf {
+		r.buf[ch] = append(r.buf[ch], in[ch]...)
+	}
+	r.total += int64(len(in[0]))
+	return r.output(r.start + int64(len(r.buf[0])))
+}
+
+// Flush returns the rest of the output, the input is followed by silence
+func (r *Resampler) Flush() [][]float64 {
+	end := r.start + int64(len(r.buf[0]))
+	for ch := range r.buf {
+		r.buf[ch] = append(r.buf[ch], make([]float64, r.half)...)
+	}
+	// the output covers the same time as the input
+	last := (r.total*r.up + r.down - 1) / r.down
+	out := r.output(end + int64(r.half))
+	for ch := range out {
+		out[ch] = out[ch][:len(out[ch])-int(max(r.next-last, 0))]
+	}
+	return out
+}
+
+// output computes output samples whose filter window ends before the input index end
+// and drops input samples no longer needed
+func (r *Resampler) output(end int64) [][]float64 {
+	out := make([][]float64, len(r.buf))
+	for {
+		base := r.next * r.down / r.up
+		if base+int64(r.half) >= end {
+			break
+		}
+		phase := r.next * r.down % r.up
+		offset := int(base - int64(r.half) + 1 - r.start)
+		coeffs := r.filter[phase]
+		for ch, buf := range r.buf {
+			window := buf[offset : offset+len(coeffs)]
+			var v float64
+			for k, c := range coeffs {
+				v += window[k] * c
+			}
+			out[ch] = append(out[ch], v)
+		}
+		r.next++
+	}
+
+	// keep the window of the next output sample
+	keep := r.next*r.down/r.up - int64(r.half) + 1
+	if drop := int(keep - r.start); drop > 0 {
+		for ch := range r.buf {
+			r.buf[ch] = append(r.buf[ch][:0], r.buf[ch][drop:]...)
+		}
+		r.start = keep
+	}
+	return out
+}
+
+// sinc is the normalized sinc function
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser is the Kaiser window over -1..1
+func kaiser(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return besselI0(kaiserBeta*math.Sqrt(1-x*x)) / besselI0(kaiserBeta)
+}
+
+// besselI0 is the zeroth order modified Bessel function of the first kind
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1.0; term > sum*1e-12; k++ {
+		term *= x * x / (4 * k * k)
+		sum += term
+	}
+	return sum
+}
+
+// gcd returns the greatest common divisor of a and b
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,98 @@
+package flacstream
+
+import (
+	"bufio"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNoChecksum is returned by Verify when the encoder didn't store the MD5 of the audio
+var ErrNoChecksum = errors.New("STREAMINFO has no MD5 checksum")
+
+// Verify decodes all audio frames of the FLAC file and compares them with the STREAMINFO MD5,
+// frames are still decoded and their CRCs checked when the MD5 is missing. The file is decoded
+// while it's read, so it's never held in memory as a whole
+func Verify(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d, err := NewStreamDecoder(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	return verify(d)
+}
+
+// VerifyData verifies the in-memory FLAC stream the same way Verify does
+func VerifyData(data []byte) error {
+	d, err := NewDecoder(data)
+	if err != nil {
+		return err
+	}
+	return verify(d)
+}
+
+// verify decodes all frames of d and compares them with the STREAMINFO MD5
+func verify(d *Decoder) error {
+	sum, err := d.MD5()
+	if err != nil {
+		return err
+	}
+
+	if d.Info.MD5 == [16]byte{} {
+		return ErrNoChecksum
+	}
+	if sum != d.Info.MD5 {
+		return fmt.Errorf("decoded audio doesn't match STREAMINFO MD5")
+	}
+	return nil
+}
+
+// MD5 decodes all remaining frames and returns the checksum of their samples, computed
+// the way encoders do it: interleaved little-endian samples of whole bytes
+func (d *Decoder) MD5() ([16]byte, error) {
+	hash := md5.New()
+	width := (d.Info.BitsPerSample + 7) / 8
+	var buf []byte
+
+	for {
+		frame, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return [16]byte{}, err
+		}
+
+		if frame.BitsPerSample != d.Info.BitsPerSample {
+			return [16]byte{}, fmt.Errorf("frame has %d bits per sample, stream has %d", frame.BitsPerSample, d.Info.BitsPerSample)
+		}
+
+		// the last frame may contain more samples than the stream declares
+		blockSize := frame.BlockSize
+		if d.Info.TotalSamples > 0 && d.decoded > d.Info.TotalSamples {
+			blockSize -= int(d.decoded - d.Info.TotalSamples)
+		}
+
+		buf = buf[:0]
+		for i := range blockSize {
+			for _, channel := range frame.Samples {
+				v := channel[i]
+				for b := range width {
+					buf = append(buf, byte(v>>(8*b)))
+				}
+			}
+		}
+		hash.Write(buf)
+	}
+
+	var sum [16]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum, nil
+}
@@ -0,0 +1,123 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// MaxRuns is the number of runs kept in the history, older runs are dropped by Add
+const MaxRuns = 1000
+
+// Run is a single pick run
+type Run struct {
+	Time time.Time `json:"time"`
+	// Seed is nil for runs recorded before seeds were introduced, zero is a valid seed
+	Seed   *uint64  `json:"seed,omitempty"`
+	Albums []string `json:"albums"`
+}
+
+// History is a persistent list of past pick runs
+type History struct {
+	Runs []Run `json:"runs"`
+
+	path string
+}
+
+// DefaultPath returns the default location of the history file
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "albumpicker", "history.json"), nil
+}
+
+// Load reads the history from path, a missing file means empty history
+func Load(path string) (*History, error) {
+	h := &History{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %s", err)
+	}
+
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("error parsing history %s: %s", path, err)
+	}
+
+	return h, nil
+}
+
+// Save writes the history to its file
+func (h *History) Save() error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("error creating history directory: %s", err)
+	}
+
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding history: %s", err)
+	}
+
+	// write to a temporary file first, so an interrupted save doesn't corrupt the history
+	tmpPath := h.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("error writing history: %s", err)
+	}
+	if err := os.Rename(tmpPath, h.path); err != nil {
+		return fmt.Errorf("error writing history: %s", err)
+	}
+
+	return nil
+}
+
+// Add appends a run to the history and drops the oldest runs beyond MaxRuns
+func (h *History) Add(run Run) {
+	h.Runs = append(h.Runs, run)
+	if len(h.Runs) > MaxRuns {
+		h.Runs = slices.Delete(h.Runs, 0, len(h.Runs)-MaxRuns)
+	}
+}
+
+// Clear removes all runs from the history
+func (h *History) Clear() {
+	h.Runs = nil
+}
+
+// RecentAlbums returns albums picked after since or during the last lastRuns runs,
+// zero since and lastRuns are ignored
+func (h *History) RecentAlbums(since time.Time, lastRuns int) map[string]bool {
+	recent := make(map[string]bool)
+	for i, run := range h.Runs {
+		inLastRuns := lastRuns > 0 && i >= len(h.Runs)-lastRuns
+		inPeriod := !since.IsZero() && run.Time.After(since)
+		if !inLastRuns && !inPeriod {
+			continue
+		}
+		for _, album := range run.Albums {
+			recent[album] = true
+		}
+	}
+	return recent
+}
+
+// LastPicked returns the time every album was picked last time
+func (h *History) LastPicked() map[string]time.Time {
+	last := make(map[string]time.Time)
+	for _, run := range h.Runs {
+		for _, album := range run.Albums {
+			if run.Time.After(last[album]) {
+				last[album] = run.Time
+			}
+		}
+	}
+	return last
+}
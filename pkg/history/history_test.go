@@ -0,0 +1,141 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistorySaveLoad(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_history_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "config", "history.json")
+
+	// missing file is an empty history
+	h, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(h.Runs) != 0 {
+		t.Fatalf("Load() got %d runs, want 0", len(h.Runs))
+	}
+
+	now := time.Now().Truncate(time.Second)
+	seed := uint64(0)
+	h.Add(Run{Time: now, Seed: &seed, Albums: []string{"album1", "album2"}})
+	h.Add(Run{Time: now, Albums: []string{"album3"}})
+	if err := h.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded.Runs) != 2 {
+		t.Fatalf("Load() got %d runs, want 2", len(loaded.Runs))
+	}
+	if !loaded.Runs[0].Time.Equal(now) || len(loaded.Runs[0].Albums) != 2 {
+		t.Errorf("Load() got run %+v", loaded.Runs[0])
+	}
+	// a zero seed is kept, runs without a seed stay without one
+	if loaded.Runs[0].Seed == nil || *loaded.Runs[0].Seed != 0 || loaded.Runs[1].Seed != nil {
+		t.Errorf("Load() got seeds %v and %v, want 0 and none", loaded.Runs[0].Seed, loaded.Runs[1].Seed)
+	}
+
+	loaded.Clear()
+	if err := loaded.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	cleared, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cleared.Runs) != 0 {
+		t.Errorf("Load() after Clear() got %d runs, want 0", len(cleared.Runs))
+	}
+
+	// corrupted file
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() expected error for corrupted file")
+	}
+}
+
+func TestAddDropsOldRuns(t *testing.T) {
+	h := &History{}
+	start := time.Now()
+	for i := range MaxRuns + 10 {
+		h.Add(Run{Time: start.Add(time.Duration(i) * time.Minute), Albums: []string{"album"}})
+	}
+
+	if len(h.Runs) != MaxRuns {
+		t.Fatalf("Add() kept %d runs, want %d", len(h.Runs), MaxRuns)
+	}
+	if want := start.Add(10 * time.Minute); !h.Runs[0].Time.Equal(want) {
+		t.Errorf("Add() kept oldest run from %v, want %v", h.Runs[0].Time, want)
+	}
+}
+
+func TestRecentAlbums(t *testing.T) {
+	now := time.Now()
+	h := &History{Runs: []Run{
+		{Time: now.Add(-60 * 24 * time.Hour), Albums: []string{"old"}},
+		{Time: now.Add(-10 * 24 * time.Hour), Albums: []string{"ten-days"}},
+		{Time: now.Add(-2 * 24 * time.Hour), Albums: []string{"two-days"}},
+		{Time: now.Add(-time.Hour), Albums: []string{"hour", "two-days"}},
+	}}
+
+	tests := []struct {
+		name     string
+		since    time.Time
+		lastRuns int
+		want     []string
+	}{
+		{"nothing excluded", time.Time{}, 0, nil},
+		{"by age", now.Add(-7 * 24 * time.Hour), 0, []string{"two-days", "hour"}},
+		{"by runs", time.Time{}, 3, []string{"ten-days", "two-days", "hour"}},
+		{"more runs than history", time.Time{}, 10, []string{"old", "ten-days", "two-days", "hour"}},
+		{"by age or runs", now.Add(-24 * time.Hour), 2, []string{"two-days", "hour"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.RecentAlbums(tt.since, tt.lastRuns)
+			if len(got) != len(tt.want) {
+				t.Errorf("RecentAlbums() got %v, want %v", got, tt.want)
+			}
+			for _, album := range tt.want {
+				if !got[album] {
+					t.Errorf("RecentAlbums() missing %s", album)
+				}
+			}
+		})
+	}
+}
+
+func TestLastPicked(t *testing.T) {
+	now := time.Now()
+	h := &History{Runs: []Run{
+		{Time: now.Add(-48 * time.Hour), Albums: []string{"album1", "album2"}},
+		{Time: now.Add(-time.Hour), Albums: []string{"album2"}},
+	}}
+
+	last := h.LastPicked()
+	if len(last) != 2 {
+		t.Fatalf("LastPicked() got %d albums, want 2", len(last))
+	}
+	if !last["album1"].Equal(now.Add(-48 * time.Hour)) {
+		t.Errorf("LastPicked() album1 = %v", last["album1"])
+	}
+	if !last["album2"].Equal(now.Add(-time.Hour)) {
+		t.Errorf("LastPicked() album2 = %v", last["album2"])
+	}
+}
@@ -0,0 +1,171 @@
+package library
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tag names written by MusicBrainz Picard and other taggers for each filter
+var (
+	artistTags      = []string{"ALBUMARTIST", "ARTIST", "ARTISTS"}
+	genreTags       = []string{"GENRE"}
+	labelTags       = []string{"LABEL", "ORGANIZATION"}
+	releaseTypeTags = []string{"RELEASETYPE", "MUSICBRAINZ_ALBUMTYPE"}
+	yearTags        = []string{"ORIGINALDATE", "ORIGINALYEAR", "DATE", "YEAR"}
+)
+
+// Filter selects albums by their tags, empty fields match any album
+type Filter struct {
+	Artists      []string
+	Genres       []string
+	Labels       []string
+	ReleaseTypes []string
+	YearFrom     int
+	YearTo       int
+}
+
+// IsEmpty checks if the filter matches any album
+func (f Filter) IsEmpty() bool {
+	return len(f.Artists) == 0 && len(f.Genres) == 0 && len(f.Labels) == 0 &&
+		len(f.ReleaseTypes) == 0 && f.YearFrom == 0 && f.YearTo == 0
+}
+
+// Match checks if the album matches all conditions of the filter
+func (f Filter) Match(album Album) bool {
+	if len(f.Artists) > 0 && !matchTags(album, artistTags, f.Artists) {
+		return false
+	}
+	if len(f.Genres) > 0 && !matchTags(album, genreTags, f.Genres) {
+		return false
+	}
+	if len(f.Labels) > 0 && !matchTags(album, labelTags, f.Labels) {
+		return false
+	}
+	if len(f.ReleaseTypes) > 0 && !matchTags(album, releaseTypeTags, f.ReleaseTypes) {
+		return false
+	}
+	if f.YearFrom > 0 || f.YearTo > 0 {
+		year := album.Year()
+		if year == 0 || (f.YearFrom > 0 && year < f.YearFrom) || (f.YearTo > 0 && year > f.YearTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns albums matching the filter
+func (f Filter) Apply(albums []Album) []Album {
+	var matched []Album
+	for _, album := range albums {
+		if f.Match(album) {
+			matched = append(matched, album)
+		}
+	}
+	return matched
+}
+
+// Year returns the (original) release year of the album, 0 if unknown
+func (a Album) Year() int {
+	for _, name := range yearTags {
+		for _, value := range a.Tag(name) {
+			if len(value) >= 4 {
+				if year, err := strconv.Atoi(value[:4]); err == nil {
+					return year
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// Values returns values of a query field: artist, genre, label, releasetype and year
+// are taken from their usual tags, path, dir and parent from the album path,
+// any other field is a tag with the same name
+func (a Album) Values(field string) []string {
+	switch strings.ToLower(field) {
+	case "artist":
+		return a.tagValues(artistTags)
+	case "genre":
+		return a.tagValues(genreTags)
+	case "label":
+		return a.tagValues(labelTags)
+	case "releasetype":
+		return a.tagValues(releaseTypeTags)
+	case "year":
+		if year := a.Year(); year > 0 {
+			return []string{strconv.Itoa(year)}
+		}
+		return nil
+	case "path":
+		return []string{a.Path}
+	case "dir":
+		return []string{filepath.Base(a.Path)}
+	case "parent":
+		return []string{filepath.Base(filepath.Dir(a.Path))}
+	default:
+		return a.Tag(field)
+	}
+}
+
+// tagValues returns values of all the tags, values separated by semicolons are split
+func (a Album) tagValues(names []string) []string {
+	var values []string
+	for _, name := range names {
+		for _, value := range a.Tag(name) {
+			for _, part := range strings.Split(value, ";") {
+				values = append(values, strings.TrimSpace(part))
+			}
+		}
+	}
+	return values
+}
+
+// ParseYearRange parses a year ("1975") or a range of years ("1970-1979", "1990-", "-1969")
+func ParseYearRange(s string) (from, to int, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, nil
+	}
+
+	parseYear := func(value string) (int, error) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return 0, nil
+		}
+		year, err := strconv.Atoi(value)
+		if err != nil || year <= 0 {
+			return 0, fmt.Errorf("invalid year range: %s", s)
+		}
+		return year, nil
+	}
+
+	fromValue, toValue, isRange := strings.Cut(s, "-")
+	if from, err = parseYear(fromValue); err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return from, from, nil
+	}
+	if to, err = parseYear(toValue); err != nil {
+		return 0, 0, err
+	}
+	if (from == 0 && to == 0) || (to > 0 && from > to) {
+		return 0, 0, fmt.Errorf("invalid year range: %s", s)
+	}
+	return from, to, nil
+}
+
+// matchTags checks if any value of the tags equals any of the wanted values,
+// values separated by semicolons are compared one by one
+func matchTags(album Album, names, wanted []string) bool {
+	for _, value := range album.tagValues(names) {
+		for _, w := range wanted {
+			if strings.EqualFold(value, strings.TrimSpace(w)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package library
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	jazz := Album{Path: "jazz", Tags: map[string][]string{
+		"ALBUMARTIST":  {"Miles Davis"},
+		"ARTIST":       {"Miles Davis", "John Coltrane"},
+		"GENRE":        {"Jazz; Fusion"},
+		"ORIGINALDATE": {"1970-03-30"},
+		"DATE":         {"1999-01-01"},
+		"LABEL":        {"Columbia"},
+		"RELEASETYPE":  {"album"},
+	}}
+	rock := Album{Path: "rock", Tags: map[string][]string{
+		"ALBUMARTIST": {"Various Artists"},
+		"GENRE":       {"Rock"},
+		"DATE":        {"1995"},
+		"RELEASETYPE": {"compilation"},
+	}}
+	untagged := Album{Path: "untagged"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []bool
+	}{
+		{"empty filter", Filter{}, []bool{true, true, true}},
+		{"genre", Filter{Genres: []string{"jazz"}}, []bool{true, false, false}},
+		{"one of genres", Filter{Genres: []string{"fusion", "rock"}}, []bool{true, true, false}},
+		{"track artist", Filter{Artists: []string{"John Coltrane"}}, []bool{true, false, false}},
+		{"original year", Filter{YearFrom: 1970, YearTo: 1979}, []bool{true, false, false}},
+		{"open year range", Filter{YearFrom: 1990}, []bool{false, true, false}},
+		{"label", Filter{Labels: []string{"columbia"}}, []bool{true, false, false}},
+		{"release type", Filter{ReleaseTypes: []string{"compilation"}}, []bool{false, true, false}},
+		{"all conditions", Filter{Genres: []string{"Jazz"}, YearTo: 1969}, []bool{false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, album := range []Album{jazz, rock, untagged} {
+				if got := tt.filter.Match(album); got != tt.want[i] {
+					t.Errorf("Match(%s) = %v, want %v", album.Path, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if got := (Filter{Genres: []string{"Rock"}}).Apply([]Album{jazz, rock, untagged}); len(got) != 1 || got[0].Path != "rock" {
+		t.Errorf("Apply() = %v, want [rock]", got)
+	}
+}
+
+func TestParseYearRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantFrom int
+		wantTo   int
+		wantErr  bool
+	}{
+		{"", 0, 0, false},
+		{"1975", 1975, 1975, false},
+		{"1970-1979", 1970, 1979, false},
+		{"1990-", 1990, 0, false},
+		{"-1969", 0, 1969, false},
+		{"-", 0, 0, true},
+		{"1980-1970", 0, 0, true},
+		{"seventies", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			from, to, err := ParseYearRange(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseYearRange(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if from != tt.wantFrom || to != tt.wantTo {
+				t.Errorf("ParseYearRange(%q) = %d, %d, want %d, %d", tt.input, from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestAlbumValues(t *testing.T) {
+	album := Album{
+		Path: filepath.Join("music", "Miles Davis", "1970 - Bitches Brew"),
+		Tags: map[string][]string{
+			"ALBUMARTIST":  {"Miles Davis"},
+			"GENRE":        {"Jazz; Fusion"},
+			"ORIGINALDATE": {"1970-03-30"},
+			"COMPOSER":     {"Joe Zawinul"},
+		},
+	}
+
+	tests := []struct {
+		field string
+		want  []string
+	}{
+		{"artist", []string{"Miles Davis"}},
+		{"genre", []string{"Jazz", "Fusion"}},
+		{"year", []string{"1970"}},
+		{"dir", []string{"1970 - Bitches Brew"}},
+		{"parent", []string{"Miles Davis"}},
+		{"path", []string{album.Path}},
+		{"composer", []string{"Joe Zawinul"}},
+		{"label", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			if got := album.Values(tt.field); !slices.Equal(got, tt.want) {
+				t.Errorf("Values(%s) = %v, want %v", tt.field, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package library
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/pool"
+	"github.com/nerten/albumpicker/pkg/progress"
+)
+
+// indexVersion is bumped every time the on-disk index format changes,
+// older indexes are discarded and rebuilt from scratch
+const indexVersion = 9
+
+// File is a single audio file recorded in the index
+type File struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// Format is the name of the audio format, it's empty when the file wasn't recognised
+	Format string `json:"format,omitempty"`
+	// Stripped is the size of embedded pictures and padding removed on copying
+	Stripped int64 `json:"stripped,omitempty"`
+	// Duration is the length of audio, it's used to estimate sizes of transcoded files
+	Duration time.Duration `json:"duration,omitempty"`
+	// SampleRate and BitsPerSample are the audio format, they are used to estimate sizes of downsampled files
+	SampleRate    int `json:"sample_rate,omitempty"`
+	BitsPerSample int `json:"bits_per_sample,omitempty"`
+	// FrontCover is set when the file embeds a front cover, which is kept on copying by flac_front_cover.
+	// FrontCoverSize and FrontCoverHeight are the block size and image height of the first front cover
+	FrontCover       bool  `json:"front_cover,omitempty"`
+	FrontCoverSize   int64 `json:"front_cover_size,omitempty"`
+	FrontCoverHeight int   `json:"front_cover_height,omitempty"`
+	// Application, CueSheet and SeekTable are sizes of FLAC metadata blocks dropped on copying by config
+	Application int64 `json:"application,omitempty"`
+	CueSheet    int64 `json:"cuesheet,omitempty"`
+	SeekTable   int64 `json:"seektable,omitempty"`
+}
+
+// setMetadata records the metadata read from the file
+func (f *File) setMetadata(meta *audio.Metadata) {
+	f.Stripped = meta.Stripped
+	f.Duration = meta.Duration
+	f.SampleRate, f.BitsPerSample = meta.SampleRate, meta.BitsPerSample
+	f.FrontCover, f.FrontCoverSize, f.FrontCoverHeight = meta.FrontCover, meta.FrontCoverSize, meta.FrontCoverHeight
+	f.Application, f.CueSheet, f.SeekTable = meta.Application, meta.CueSheet, meta.SeekTable
+}
+
+// IsFLAC checks if the file is a FLAC file, files of unknown format are recognised by their extension
+func (f File) IsFLAC() bool {
+	if f.Format != "" {
+		return f.Format == audio.FLACFormat
+	}
+	_, ok := audio.ForName(f.Name).(audio.FLAC)
+	return ok
+}
+
+// Stat returns the record of a single audio file, files with broken metadata have only their size
+func Stat(path string) (File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return File{}, err
+	}
+	file := File{Name: filepath.Base(path), Size: info.Size(), ModTime: info.ModTime()}
+	handler, err := audio.Detect(path)
+	if err != nil {
+		return file, nil
+	}
+	file.Format = handler.Name()
+	if meta, err := handler.ReadMetadata(path); err == nil {
+		file.setMetadata(meta)
+	}
+	return file, nil
+}
+
+// ReadAlbum reads audio files of a single directory without an index, nil extensions are the default ones
+func ReadAlbum(path string, extensions []string) (Album, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Album{}, err
+	}
+	dir, err := readDir(path, info.ModTime(), extensions)
+	if err != nil {
+		return Album{}, err
+	}
+	return Album{Path: path, Files: dir.Files, Tags: dir.Tags}, nil
+}
+
+// Dir is a cached state of a single directory of the library
+type Dir struct {
+	ModTime time.Time           `json:"mod_time"`
+	Subdirs []string            `json:"subdirs,omitempty"`
+	Files   []File              `json:"files,omitempty"`
+	Tags    map[string][]string `json:"tags,omitempty"`
+}
+
+// Album is a directory containing audio files
+type Album struct {
+	Path  string
+	Files []File
+	// Tags are distinct values of Vorbis comments of all album files, field names are upper-cased
+	Tags map[string][]string
+}
+
+// Tag returns values of the tag, name is case-insensitive
+func (a Album) Tag(name string) []string {
+	return a.Tags[strings.ToUpper(name)]
+}
+
+// Size returns the total size of all audio files of the album
+func (a Album) Size() int64 {
+	var size int64
+	for _, f := range a.Files {
+		size += f.Size
+	}
+	return size
+}
+
+// OutputSize returns the estimated total size of the album audio files after stripping embedded pictures
+func (a Album) OutputSize() int64 {
+	var size int64
+	for _, f := range a.Files {
+		size += f.Size - f.Stripped
+	}
+	return size
+}
+
+// Index is a persistent cache of the library layout
+type Index struct {
+	Version int             `json:"version"`
+	Dirs    map[string]*Dir `json:"dirs"`
+	// Extensions are recognised audio file extensions, nil means the extensions of all supported formats
+	Extensions []string `json:"extensions,omitempty"`
+	// Jobs is the number of goroutines Scan walks directories with, each reads one directory at a time, zero means one
+	Jobs int `json:"-"`
+
+	path string
+}
+
+// DefaultPath returns the default location of the index file
+func DefaultPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "albumpicker", "index.json"), nil
+}
+
+// New creates an empty index stored at path
+func New(path string) *Index {
+	return &Index{
+		Version: indexVersion,
+		Dirs:    make(map[string]*Dir),
+		path:    path,
+	}
+}
+
+// Load reads the index from path, a missing or outdated index is returned empty
+func Load(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return New(path), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading library index: %s", err)
+	}
+
+	idx := New(path)
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("error parsing library index %s: %s", path, err)
+	}
+	if idx.Version != indexVersion || idx.Dirs == nil {
+		return New(path), nil
+	}
+
+	return idx, nil
+}
+
+// Save writes the index to its file
+func (idx *Index) Save() error {
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0o755); err != nil {
+		return fmt.Errorf("error creating index directory: %s", err)
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("error encoding library index: %s", err)
+	}
+
+	// write to a temporary file first, so an interrupted save doesn't corrupt the index
+	tmpPath := idx.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("error writing library index: %s", err)
+	}
+	if err := os.Rename(tmpPath, idx.path); err != nil {
+		return fmt.Errorf("error writing library index: %s", err)
+	}
+
+	return nil
+}
+
+// SetExtensions sets recognised audio file extensions, all cached directories are dropped
+// when they differ from the extensions of the previous scan
+func (idx *Index) SetExtensions(extensions []string) {
+	if !slices.Equal(idx.Extensions, extensions) {
+		idx.Dirs = make(map[string]*Dir)
+	}
+	idx.Extensions = extensions
+}
+
+// Forget drops cached directories under rootDir, so the next Scan reads them from disk
+func (idx *Index) Forget(rootDir string) {
+	rootDir = filepath.Clean(rootDir)
+	for path := range idx.Dirs {
+		if isWithin(rootDir, path) {
+			delete(idx.Dirs, path)
+		}
+	}
+}
+
+// Scan finds all albums under rootDir, only directories changed since the previous scan are read,
+// the index is left untouched when ctx is cancelled during the scan. Paths which can't be read
+// are skipped with a warning sent to the progress reporter of ctx
+func (idx *Index) Scan(ctx context.Context, rootDir string) ([]Album, error) {
+	rootDir = filepath.Clean(rootDir)
+
+	info, err := os.Stat(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", rootDir)
+	}
+
+	s := &scan{ctx: ctx, idx: idx, seen: make(map[string]bool), dirs: make(map[string]*Dir), workers: pool.NewSemaphore(max(idx.Jobs, 1))}
+	s.wg.Add(1)
+	s.workers.Acquire()
+	s.scanDir(rootDir, info)
+	s.workers.Release()
+	s.wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for path, dir := range s.dirs {
+		idx.Dirs[path] = dir
+	}
+
+	// forget directories that were removed from the scanned subtree
+	for path := range idx.Dirs {
+		if !s.seen[path] && isWithin(rootDir, path) {
+			delete(idx.Dirs, path)
+		}
+	}
+
+	albums := s.albums
+	sort.Slice(albums, func(i, j int) bool {
+		return albums[i].Path < albums[j].Path
+	})
+
+	return albums, nil
+}
+
+// scan is the state of a single Scan shared by directories read concurrently
+type scan struct {
+	ctx context.Context
+	idx *Index
+	// workers limits the number of goroutines walking directories, the calling one included
+	workers pool.Semaphore
+	wg      sync.WaitGroup
+
+	// mu guards the fields below
+	mu   sync.Mutex
+	seen map[string]bool
+	// dirs are directories read from disk, they are added to the index when the scan completes
+	dirs   map[string]*Dir
+	albums []Album
+}
+
+// scanDir walks a single directory, reusing the cached entry if neither the directory nor its audio files were modified,
+// subdirectories are walked in their own goroutines while workers are available and in the current one otherwise
+func (s *scan) scanDir(path string, info os.FileInfo) {
+	defer s.wg.Done()
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.seen[path] = true
+	dir, ok := s.idx.Dirs[path]
+	s.mu.Unlock()
+
+	if !ok || !dir.ModTime.Equal(info.ModTime()) || filesChanged(path, dir.Files) {
+		var err error
+		dir, err = readDir(path, info.ModTime(), s.idx.Extensions)
+		if err != nil {
+			progress.Report(s.ctx, progress.Event{Kind: progress.Warning, File: path, Err: fmt.Errorf("error accessing path %s: %s", path, err)})
+			return
+		}
+		s.mu.Lock()
+		s.dirs[path] = dir
+		s.mu.Unlock()
+	}
+
+	if len(dir.Files) > 0 {
+		// this directory contains audio files, treat it as an album
+		// and skip processing its subdirectories
+		s.mu.Lock()
+		s.albums = append(s.albums, Album{Path: path, Files: dir.Files, Tags: dir.Tags})
+		s.mu.Unlock()
+		return
+	}
+
+	for _, name := range dir.Subdirs {
+		subPath := filepath.Join(path, name)
+		subInfo, err := os.Lstat(subPath)
+		if err != nil {
+			progress.Report(s.ctx, progress.Event{Kind: progress.Warning, File: subPath, Err: fmt.Errorf("error accessing path %s: %s", subPath, err)})
+			continue
+		}
+		if !subInfo.IsDir() {
+			continue
+		}
+		s.wg.Add(1)
+		if !s.workers.TryAcquire() {
+			s.scanDir(subPath, subInfo)
+			continue
+		}
+		go func() {
+			defer s.workers.Release()
+			s.scanDir(subPath, subInfo)
+		}()
+	}
+}
+
+// filesChanged checks if size or modification time of any of the cached files differs from the disk,
+// editing tags rewrites files without changing the modification time of their directory
+func filesChanged(path string, files []File) bool {
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(path, file.Name))
+		if err != nil || info.Size() != file.Size || !info.ModTime().Equal(file.ModTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// readDir reads a directory from disk, files with an ambiguous extension are kept
+// only when their content is recognised
+func readDir(path string, modTime time.Time, extensions []string) (*Dir, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := &Dir{ModTime: modTime}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dir.Subdirs = append(dir.Subdirs, entry.Name())
+			continue
+		}
+		if !IsAudioFile(entry, extensions) {
+			continue
+		}
+		filePath := filepath.Join(path, entry.Name())
+		handler, err := audio.Detect(filePath)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		file := File{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Format:  handler.Name(),
+		}
+		// files with broken metadata are still albums, they are just copied as is
+		if meta, err := handler.ReadMetadata(filePath); err == nil {
+			file.setMetadata(meta)
+			dir.addTags(tagValues(meta.Tags))
+		}
+		dir.Files = append(dir.Files, file)
+	}
+
+	return dir, nil
+}
+
+// isWithin checks if path is rootDir itself or one of its descendants
+func isWithin(rootDir, path string) bool {
+	return path == rootDir || strings.HasPrefix(path, rootDir+string(os.PathSeparator))
+}
+
+// addTags merges tags of a file into distinct album tags
+func (d *Dir) addTags(tags map[string][]string) {
+	if len(tags) > 0 && d.Tags == nil {
+		d.Tags = make(map[string][]string)
+	}
+	for name, values := range tags {
+		for _, value := range values {
+			if !slices.Contains(d.Tags[name], value) {
+				d.Tags[name] = append(d.Tags[name], value)
+			}
+		}
+	}
+}
+
+// IsAudioFile checks if the directory entry is a file with one of the extensions, nil extensions
+// are the ones of all supported formats. AppleDouble files created by macOS on foreign file systems are skipped
+func IsAudioFile(entry os.DirEntry, extensions []string) bool {
+	return !entry.IsDir() && audio.HasExtension(entry.Name(), extensions) && !strings.HasPrefix(entry.Name(), "._")
+}
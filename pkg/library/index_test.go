@@ -0,0 +1,279 @@
+package library
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// createTestLibrary creates directories with files in root
+func createTestLibrary(t *testing.T, root string, dirs map[string][]string) {
+	t.Helper()
+	for dir, files := range dirs {
+		dirPath := filepath.Join(root, dir)
+		if err := os.MkdirAll(dirPath, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			if err := os.WriteFile(filepath.Join(dirPath, file), []byte("test data"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestIndexScan(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_index_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	createTestLibrary(t, tmpDir, map[string][]string{
+		"album1":                          {"track1.flac", "track2.flac", "cover.jpg"},
+		"album2":                          {"track1.flac", "._track1.flac"},
+		"mp3":                             {"track1.mp3", "track2.mp3"},
+		"unsupported":                     {"track1.m4a", "track1.wv"},
+		filepath.Join("nested", "album3"): {"track1.flac"},
+		filepath.Join("album1", "scans"):  {"track1.flac"},
+	})
+
+	idx := New(filepath.Join(tmpDir, "index.json"))
+	idx.Jobs = 4
+	albums, err := idx.Scan(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(tmpDir, "album1"),
+		filepath.Join(tmpDir, "album2"),
+		filepath.Join(tmpDir, "mp3"),
+		filepath.Join(tmpDir, "nested", "album3"),
+	}
+	if len(albums) != len(expected) {
+		t.Fatalf("Scan() found %d albums, want %d", len(albums), len(expected))
+	}
+	for i, album := range albums {
+		if album.Path != expected[i] {
+			t.Errorf("Scan() album[%d] = %s, want %s", i, album.Path, expected[i])
+		}
+	}
+
+	if len(albums[0].Files) != 2 {
+		t.Errorf("album1 has %d files, want 2", len(albums[0].Files))
+	}
+	if albums[0].Size() != int64(2*len("test data")) {
+		t.Errorf("album1 size = %d, want %d", albums[0].Size(), 2*len("test data"))
+	}
+	if len(albums[1].Files) != 1 {
+		t.Errorf("album2 has %d files, want 1", len(albums[1].Files))
+	}
+
+	// a single worker walks nested directories itself
+	idx = New(filepath.Join(tmpDir, "index.json"))
+	idx.Jobs = 1
+	albums, err = idx.Scan(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(albums) != len(expected) {
+		t.Errorf("Scan() with one job found %d albums, want %d", len(albums), len(expected))
+	}
+}
+
+func TestIndexIncrementalScan(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_index_incremental_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	libDir := filepath.Join(tmpDir, "library")
+	createTestLibrary(t, libDir, map[string][]string{
+		"album1": {"track1.flac"},
+		"album2": {"track1.flac"},
+	})
+
+	indexPath := filepath.Join(tmpDir, "cache", "index.json")
+	idx := New(indexPath)
+	if _, err := idx.Scan(context.Background(), libDir); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(indexPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded.Dirs) != len(idx.Dirs) {
+		t.Fatalf("Load() got %d dirs, want %d", len(loaded.Dirs), len(idx.Dirs))
+	}
+
+	// unchanged directories must be taken from the index without reading them
+	album1 := filepath.Join(libDir, "album1")
+	loaded.Dirs[album1].Tags = map[string][]string{"CACHED": {"yes"}}
+
+	// changed directories must be read again
+	if err := os.RemoveAll(filepath.Join(libDir, "album2")); err != nil {
+		t.Fatal(err)
+	}
+	createTestLibrary(t, libDir, map[string][]string{"album3": {"track1.flac"}})
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(libDir, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	albums, err := loaded.Scan(context.Background(), libDir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(albums) != 2 {
+		t.Fatalf("Scan() found %d albums, want 2", len(albums))
+	}
+	if albums[0].Path != album1 || albums[0].Tag("CACHED") == nil {
+		t.Errorf("Scan() did not reuse cached album1: %+v", albums[0])
+	}
+	if albums[1].Path != filepath.Join(libDir, "album3") {
+		t.Errorf("Scan() album[1] = %s, want album3", albums[1].Path)
+	}
+	if _, ok := loaded.Dirs[filepath.Join(libDir, "album2")]; ok {
+		t.Error("Scan() kept removed album2 in the index")
+	}
+
+	// a cancelled scan must not change the index
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := loaded.Scan(ctx, libDir); err == nil {
+		t.Error("Scan() with cancelled context returned no error")
+	}
+	if len(loaded.Dirs) == 0 || loaded.Dirs[album1].Tags["CACHED"] == nil {
+		t.Error("Scan() with cancelled context changed the index")
+	}
+
+	// forgotten directories must be read again
+	loaded.Forget(album1)
+	albums, err = loaded.Scan(context.Background(), libDir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if albums[0].Tag("CACHED") != nil {
+		t.Error("Scan() after Forget() reused cached album1")
+	}
+
+	// directories with rewritten files must be read again, even if the directory itself wasn't modified
+	loaded.Dirs[album1].Tags = map[string][]string{"CACHED": {"yes"}}
+	album1Info, err := os.Stat(album1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	track := filepath.Join(album1, "track1.flac")
+	data, err := os.ReadFile(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(track, append(data, 0), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(album1, album1Info.ModTime(), album1Info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	albums, err = loaded.Scan(context.Background(), libDir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if albums[0].Tag("CACHED") != nil || albums[0].Files[0].Size != int64(len(data))+1 {
+		t.Errorf("Scan() did not read rewritten file of album1: %+v", albums[0])
+	}
+}
+
+func TestIndexExtensions(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_index_extensions_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	createTestLibrary(t, tmpDir, map[string][]string{
+		"windows": {"TRACK1.FLAC", "Track2.Flac"},
+		"notes":   {"readme.fla"},
+	})
+	// a FLAC file with an ambiguous extension is recognised by its header
+	flacData, err := os.ReadFile(filepath.Join("..", "..", "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "short"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "short", "track1.fla"), flacData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := New("")
+	idx.SetExtensions([]string{".fla", ".flac"})
+	albums, err := idx.Scan(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(albums) != 2 {
+		t.Fatalf("Scan() found %d albums, want 2", len(albums))
+	}
+	if albums[0].Path != filepath.Join(tmpDir, "short") || !albums[0].Files[0].IsFLAC() || albums[0].Files[0].Stripped == 0 {
+		t.Errorf("Scan() album[0] = %+v, want a FLAC file with stripped blocks in short", albums[0])
+	}
+	if albums[1].Path != filepath.Join(tmpDir, "windows") || len(albums[1].Files) != 2 {
+		t.Errorf("Scan() album[1] = %+v, want 2 files in windows", albums[1])
+	}
+
+	// changed extensions drop cached directories
+	idx.SetExtensions([]string{".flac"})
+	albums, err = idx.Scan(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(albums) != 1 || albums[0].Path != filepath.Join(tmpDir, "windows") {
+		t.Errorf("Scan() with changed extensions found %v, want only windows", albums)
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_index_load_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"missing file", "", false},
+		{"outdated version", `{"version":0,"dirs":{"/music":{}}}`, false},
+		{"corrupted file", `{"version":`, true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, "index"+string(rune('0'+i))+".json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			idx, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(idx.Dirs) != 0 {
+				t.Errorf("Load() got %d dirs, want empty index", len(idx.Dirs))
+			}
+		})
+	}
+}
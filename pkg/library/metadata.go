@@ -0,0 +1,21 @@
+package library
+
+import (
+	"github.com/nerten/albumpicker/pkg/audio"
+)
+
+// maxTagLength limits the length of tag values kept in the index,
+// so lyrics and fingerprints don't bloat it
+const maxTagLength = 256
+
+// tagValues groups tag values by their names, overly long values are skipped
+func tagValues(tags []audio.Tag) map[string][]string {
+	values := make(map[string][]string)
+	for _, tag := range tags {
+		if len(tag.Value) > maxTagLength {
+			continue
+		}
+		values[tag.Name] = append(values[tag.Name], tag.Value)
+	}
+	return values
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// ConsoleHandler prints only messages of records, prefixing warnings and errors,
+// attributes are left to the structured formats
+type ConsoleHandler struct {
+	out    io.Writer
+	errOut io.Writer
+	level  slog.Leveler
+	mu     *sync.Mutex
+}
+
+// NewConsoleHandler creates a handler printing messages to out and warnings and errors to errOut
+func NewConsoleHandler(out, errOut io.Writer, level slog.Leveler) *ConsoleHandler {
+	return &ConsoleHandler{out: out, errOut: errOut, level: level, mu: &sync.Mutex{}}
+}
+
+// Enabled reports whether records of the level are printed
+func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle prints the message of the record
+func (h *ConsoleHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var err error
+	switch {
+	case r.Level >= slog.LevelError:
+		_, err = fmt.Fprintf(h.errOut, "Error: %s\n", r.Message)
+	case r.Level >= slog.LevelWarn:
+		_, err = fmt.Fprintf(h.errOut, "Warning: %s\n", r.Message)
+	default:
+		_, err = fmt.Fprintln(h.out, r.Message)
+	}
+	return err
+}
+
+// WithAttrs returns the handler itself as attributes are not printed
+func (h *ConsoleHandler) WithAttrs(_ []slog.Attr) slog.Handler {
+	return h
+}
+
+// WithGroup returns the handler itself as attributes are not printed
+func (h *ConsoleHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+// multiHandler sends records to every handler enabled for their level
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	for _, h := range m {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		// a failing handler doesn't stop the others
+		if handleErr := h.Handle(ctx, r.Clone()); handleErr != nil && err == nil {
+			err = handleErr
+		}
+	}
+	return err
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Supported log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configure the logger of the command line tool
+type Options struct {
+	// Level is the minimum level of records written to the console
+	Level slog.Level
+	// Format is text for human-readable messages or json for JSON records
+	Format string
+	// File is the path of an optional log file receiving records of all levels
+	File string
+}
+
+// Logger writes records to the console and the optional log file
+type Logger struct {
+	*slog.Logger
+	// File writes records only to the log file, it discards them without a log file
+	File *slog.Logger
+	file *os.File
+}
+
+// New creates a logger writing messages to out and warnings and errors to errOut,
+// JSON records are always written to errOut to keep out free for command results
+func New(out, errOut io.Writer, opts Options) (*Logger, error) {
+	var console slog.Handler
+	switch opts.Format {
+	case "", FormatText:
+		console = NewConsoleHandler(out, errOut, opts.Level)
+	case FormatJSON:
+		console = slog.NewJSONHandler(errOut, &slog.HandlerOptions{Level: opts.Level})
+	default:
+		return nil, fmt.Errorf("unknown log format %q, use %s or %s", opts.Format, FormatText, FormatJSON)
+	}
+
+	l := &Logger{Logger: slog.New(console), File: slog.New(slog.DiscardHandler)}
+	if opts.File == "" {
+		return l, nil
+	}
+
+	f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %s", err)
+	}
+	fileOpts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var file slog.Handler = slog.NewTextHandler(f, fileOpts)
+	if opts.Format == FormatJSON {
+		file = slog.NewJSONHandler(f, fileOpts)
+	}
+
+	l.Logger = slog.New(multiHandler{console, file})
+	l.File = slog.New(file)
+	l.file = f
+	return l, nil
+}
+
+// Close closes the log file
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Level returns the console level for the verbosity flags
+func Level(quiet, verbose bool) (slog.Level, error) {
+	switch {
+	case quiet && verbose:
+		return 0, fmt.Errorf("--quiet and --verbose can't be used together")
+	case quiet:
+		return slog.LevelWarn, nil
+	case verbose:
+		return slog.LevelDebug, nil
+	}
+	return slog.LevelInfo, nil
+}
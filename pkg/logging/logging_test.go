@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConsole(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      slog.Level
+		wantOut    string
+		wantErrOut string
+	}{
+		{
+			name:       "info",
+			level:      slog.LevelInfo,
+			wantOut:    "Processing album: artist/album\n",
+			wantErrOut: "Warning: cover is broken\nError: no space left\n",
+		},
+		{
+			name:       "quiet",
+			level:      slog.LevelWarn,
+			wantOut:    "",
+			wantErrOut: "Warning: cover is broken\nError: no space left\n",
+		},
+		{
+			name:       "verbose",
+			level:      slog.LevelDebug,
+			wantOut:    "Written FLAC file: 01.flac\nProcessing album: artist/album\n",
+			wantErrOut: "Warning: cover is broken\nError: no space left\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			l, err := New(&out, &errOut, Options{Level: tt.level})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			logger := l.With("album", "artist/album")
+			logger.Debug("Written FLAC file: 01.flac", "file", "01.flac")
+			logger.Info("Processing album: artist/album")
+			logger.Warn("cover is broken")
+			logger.Error("no space left")
+
+			if out.String() != tt.wantOut {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if errOut.String() != tt.wantErrOut {
+				t.Errorf("error output = %q, want %q", errOut.String(), tt.wantErrOut)
+			}
+		})
+	}
+}
+
+func TestJSONAndFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_logging_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	logFile := filepath.Join(tmpDir, "albumpicker.log")
+	var out, errOut bytes.Buffer
+	l, err := New(&out, &errOut, Options{Level: slog.LevelInfo, Format: FormatJSON, File: logFile})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	l.Debug("Written FLAC file: 01.flac", "file", "01.flac")
+	l.Info("Processing album: artist/album", "album", "artist/album")
+	l.File.Info("Copied album", "album", "artist/album")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// JSON records don't mix with command results on stdout
+	if out.Len() != 0 {
+		t.Errorf("output = %q, want nothing", out.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal(errOut.Bytes(), &record); err != nil {
+		t.Fatalf("error output %q is not a single JSON record: %v", errOut.String(), err)
+	}
+	if record["msg"] != "Processing album: artist/album" || record["album"] != "artist/album" {
+		t.Errorf("unexpected record %v", record)
+	}
+
+	// the log file receives all levels
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("log file has %d records, want 3: %s", len(lines), data)
+	}
+	if !strings.Contains(lines[0], `"level":"DEBUG"`) || !strings.Contains(lines[2], `"msg":"Copied album"`) {
+		t.Errorf("unexpected log file records: %s", data)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, &bytes.Buffer{}, Options{Format: "xml"}); err == nil {
+		t.Error("New() with unknown format returned no error")
+	}
+	if _, err := New(&bytes.Buffer{}, &bytes.Buffer{}, Options{File: filepath.Join("nonexistent", "dir", "log")}); err == nil {
+		t.Error("New() with log file in missing directory returned no error")
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		quiet   bool
+		verbose bool
+		want    slog.Level
+		wantErr bool
+	}{
+		{name: "default", want: slog.LevelInfo},
+		{name: "quiet", quiet: true, want: slog.LevelWarn},
+		{name: "verbose", verbose: true, want: slog.LevelDebug},
+		{name: "both", quiet: true, verbose: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Level(tt.quiet, tt.verbose)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Level() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Level() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
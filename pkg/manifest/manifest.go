@@ -0,0 +1,273 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the manifest file in the root of the destination directory
+const FileName = ".albumpicker.json"
+
+// IncompleteName is the name of the marker file in album directories being copied, albums
+// interrupted while copying keep it until they are repaired
+const IncompleteName = ".albumpicker-incomplete"
+
+// manifestVersion is bumped every time the manifest format changes
+const manifestVersion = 2
+
+// Album is an album copied to the destination directory
+type Album struct {
+	// Source is the path of the album in the source directory
+	Source   string    `json:"source"`
+	CopiedAt time.Time `json:"copied_at"`
+	Files    []File    `json:"files,omitempty"`
+	Cover    *Cover    `json:"cover,omitempty"`
+}
+
+// File is a file written to the album directory
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Cover is the cover image written to the album directory
+type Cover struct {
+	File
+	// Source is the name of the cover file in the source album directory
+	Source string `json:"source"`
+	Height int    `json:"height"`
+}
+
+// Manifest describes albums albumpicker put into the destination directory,
+// albums are keyed by their path relative to the destination directory.
+// Add, Get, Remove and Save are safe for concurrent use
+type Manifest struct {
+	Version int               `json:"version"`
+	Albums  map[string]*Album `json:"albums"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// New creates an empty manifest of the destination directory
+func New(destination string) *Manifest {
+	return &Manifest{
+		Version: manifestVersion,
+		Albums:  make(map[string]*Album),
+		path:    filepath.Join(destination, FileName),
+	}
+}
+
+// Load reads the manifest of the destination directory, a missing manifest is returned empty
+func Load(destination string) (*Manifest, error) {
+	m := New(destination)
+
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %s", err)
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %s", m.path, err)
+	}
+	if m.Version > manifestVersion {
+		return nil, fmt.Errorf("manifest %s was written by a newer version of albumpicker", m.path)
+	}
+	if m.Albums == nil {
+		m.Albums = make(map[string]*Album)
+	}
+	// albums recorded by older versions just miss some details
+	m.Version = manifestVersion
+
+	return m, nil
+}
+
+// Save writes the manifest to the destination directory
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return fmt.Errorf("error creating destination directory: %s", err)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %s", err)
+	}
+
+	// write to a temporary file first, so an interrupted save doesn't corrupt the manifest
+	tmpPath := m.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("error writing manifest: %s", err)
+	}
+	if err := os.Rename(tmpPath, m.path); err != nil {
+		return fmt.Errorf("error writing manifest: %s", err)
+	}
+
+	return nil
+}
+
+// Add records the album copied to relPath
+func (m *Manifest) Add(relPath string, album *Album) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Albums[filepath.ToSlash(relPath)] = album
+}
+
+// Get returns the album recorded at relPath
+func (m *Manifest) Get(relPath string) (*Album, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	album, ok := m.Albums[filepath.ToSlash(relPath)]
+	return album, ok
+}
+
+// Remove forgets the album at relPath
+func (m *Manifest) Remove(relPath string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Albums, filepath.ToSlash(relPath))
+}
+
+// NewFile describes the file at path
+func NewFile(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return File{}, fmt.Errorf("error reading %s: %s", path, err)
+	}
+
+	return File{
+		Name:   filepath.Base(path),
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// MarkIncomplete marks the album directory as being copied
+func MarkIncomplete(albumDir string) error {
+	return os.WriteFile(filepath.Join(albumDir, IncompleteName), nil, 0o644)
+}
+
+// MarkComplete removes the marker of the album directory once its copy is recorded
+func MarkComplete(albumDir string) error {
+	err := os.Remove(filepath.Join(albumDir, IncompleteName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// IsIncomplete checks if copying to the album directory was started and not completed
+func IsIncomplete(albumDir string) bool {
+	_, err := os.Stat(filepath.Join(albumDir, IncompleteName))
+	return err == nil
+}
+
+// FindIncomplete returns album directories under destination marked incomplete, they are found
+// by the marker, so albums of any files are found
+func FindIncomplete(destination string) ([]string, error) {
+	var albumDirs []string
+	err := filepath.WalkDir(destination, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && d.Name() == IncompleteName {
+			albumDirs = append(albumDirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	return albumDirs, err
+}
+
+// File returns the recorded file of the album with the name
+func (a *Album) File(name string) (File, bool) {
+	for _, f := range a.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return File{}, false
+}
+
+// Check compares the file in the album directory with the recorded one,
+// checksums are compared only when checksums is set, because it requires reading the whole file
+func (f File) Check(albumDir string, checksums bool) error {
+	path := filepath.Join(albumDir, f.Name)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s is missing", f.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() != f.Size {
+		return fmt.Errorf("%s has size %d, want %d", f.Name, info.Size(), f.Size)
+	}
+
+	if checksums && f.SHA256 != "" {
+		actual, err := NewFile(path)
+		if err != nil {
+			return err
+		}
+		if actual.SHA256 != f.SHA256 {
+			return fmt.Errorf("%s has wrong checksum", f.Name)
+		}
+	}
+
+	return nil
+}
+
+// Check compares all files in the album directory with the recorded ones
+func (a *Album) Check(albumDir string, checksums bool) []error {
+	var errs []error
+	if _, err := os.Stat(albumDir); err != nil {
+		return []error{fmt.Errorf("album directory is missing")}
+	}
+	if IsIncomplete(albumDir) {
+		return []error{fmt.Errorf("copying was interrupted")}
+	}
+	for _, f := range a.Files {
+		if err := f.Check(albumDir, checksums); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if a.Cover != nil {
+		if err := a.Cover.Check(albumDir, checksums); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Size returns the total size of the album files
+func (a *Album) Size() int64 {
+	var size int64
+	for _, f := range a.Files {
+		size += f.Size
+	}
+	if a.Cover != nil {
+		size += a.Cover.Size
+	}
+	return size
+}
@@ -0,0 +1,164 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifestSaveLoad(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_manifest_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// missing file is an empty manifest
+	m, err := Load(tmpDir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(m.Albums) != 0 {
+		t.Fatalf("Load() got %d albums, want 0", len(m.Albums))
+	}
+
+	now := time.Now().Truncate(time.Second)
+	m.Add(filepath.Join("artist", "album1"), &Album{Source: "/music/artist/album1", CopiedAt: now})
+	m.Add(filepath.Join("artist", "album2"), &Album{Source: "/music/artist/album2", CopiedAt: now})
+	m.Remove(filepath.Join("artist", "album2"))
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, FileName)); err != nil {
+		t.Fatalf("Save() didn't write the manifest: %v", err)
+	}
+
+	loaded, err := Load(tmpDir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded.Albums) != 1 {
+		t.Fatalf("Load() got %d albums, want 1", len(loaded.Albums))
+	}
+	album, ok := loaded.Get(filepath.Join("artist", "album1"))
+	if !ok || album.Source != "/music/artist/album1" || !album.CopiedAt.Equal(now) {
+		t.Errorf("Get() = %+v, %v", album, ok)
+	}
+	// paths are stored with forward slashes on every platform
+	if _, ok := loaded.Albums["artist/album1"]; !ok {
+		t.Errorf("Albums keys = %v", loaded.Albums)
+	}
+
+	// corrupted file
+	if err := os.WriteFile(filepath.Join(tmpDir, FileName), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(tmpDir); err == nil {
+		t.Error("Load() expected error for corrupted file")
+	}
+
+	// file written by a newer version
+	if err := os.WriteFile(filepath.Join(tmpDir, FileName), []byte(`{"version": 100}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(tmpDir); err == nil {
+		t.Error("Load() expected error for newer version")
+	}
+}
+
+func TestAlbumCheck(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_manifest_check_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for name, data := range map[string]string{"01.flac": "track one", "02.flac": "track two", "cover.jpg": "cover"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	album := &Album{}
+	for _, name := range []string{"01.flac", "02.flac"} {
+		file, err := NewFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		album.Files = append(album.Files, file)
+	}
+	cover, err := NewFile(filepath.Join(tmpDir, "cover.jpg"))
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	album.Cover = &Cover{File: cover, Source: "folder.png", Height: 240}
+
+	if album.Files[0].Name != "01.flac" || album.Files[0].Size != 9 || len(album.Files[0].SHA256) != 64 {
+		t.Errorf("NewFile() = %+v", album.Files[0])
+	}
+	if album.Size() != 9+9+5 {
+		t.Errorf("Size() = %d, want %d", album.Size(), 9+9+5)
+	}
+	if errs := album.Check(tmpDir, true); len(errs) != 0 {
+		t.Errorf("Check() = %v, want no errors", errs)
+	}
+
+	// same size, different content is found only by checksums
+	if err := os.WriteFile(filepath.Join(tmpDir, "01.flac"), []byte("track 0ne"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if errs := album.Check(tmpDir, false); len(errs) != 0 {
+		t.Errorf("Check() without checksums = %v, want no errors", errs)
+	}
+	if errs := album.Check(tmpDir, true); len(errs) != 1 {
+		t.Errorf("Check() with checksums = %v, want 1 error", errs)
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, "cover.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(filepath.Join(tmpDir, "02.flac"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if errs := album.Check(tmpDir, false); len(errs) != 2 {
+		t.Errorf("Check() = %v, want 2 errors", errs)
+	}
+
+	if errs := album.Check(filepath.Join(tmpDir, "missing"), false); len(errs) != 1 {
+		t.Errorf("Check() for missing directory = %v, want 1 error", errs)
+	}
+}
+
+func TestFindIncomplete(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_manifest_incomplete_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, name := range []string{"complete", filepath.Join("artist", "interrupted")} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	interrupted := filepath.Join(tmpDir, "artist", "interrupted")
+	if err := MarkIncomplete(interrupted); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs, err := FindIncomplete(tmpDir)
+	if err != nil {
+		t.Fatalf("FindIncomplete() error = %v", err)
+	}
+	if len(dirs) != 1 || dirs[0] != interrupted {
+		t.Errorf("FindIncomplete() = %v, want [%s]", dirs, interrupted)
+	}
+
+	if err := MarkComplete(interrupted); err != nil {
+		t.Fatal(err)
+	}
+	if dirs, err := FindIncomplete(tmpDir); err != nil || len(dirs) != 0 {
+		t.Errorf("FindIncomplete() = %v, %v after MarkComplete(), want none", dirs, err)
+	}
+}
@@ -0,0 +1,72 @@
+package pool
+
+import "sync"
+
+// Run calls fn for every index from 0 to n-1 using at most jobs goroutines
+// and waits for all calls to return, jobs below 1 mean a single goroutine
+func Run(jobs, n int, fn func(i int)) {
+	jobs = max(min(jobs, n), 1)
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := range n {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// Semaphore limits the number of concurrent operations, a nil Semaphore doesn't limit anything
+type Semaphore chan struct{}
+
+// NewSemaphore creates a semaphore allowing n concurrent operations, n below 1 means no limit
+func NewSemaphore(n int) Semaphore {
+	if n < 1 {
+		return nil
+	}
+	return make(Semaphore, n)
+}
+
+// Acquire blocks until the operation is allowed to start
+func (s Semaphore) Acquire() {
+	if s != nil {
+		s <- struct{}{}
+	}
+}
+
+// TryAcquire starts the operation if it's allowed without blocking and reports whether it did
+func (s Semaphore) TryAcquire() bool {
+	if s == nil {
+		return true
+	}
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release marks the operation finished
+func (s Semaphore) Release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// Do runs fn as a single operation limited by the semaphore
+func (s Semaphore) Do(fn func() error) error {
+	s.Acquire()
+	defer s.Release()
+	return fn()
+}
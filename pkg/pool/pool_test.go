@@ -0,0 +1,107 @@
+package pool
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		jobs int
+		n    int
+	}{
+		{name: "more items than jobs", jobs: 3, n: 20},
+		{name: "more jobs than items", jobs: 8, n: 2},
+		{name: "zero jobs", jobs: 0, n: 5},
+		{name: "no items", jobs: 4, n: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, peak atomic.Int32
+			var mu sync.Mutex
+			done := make(map[int]int)
+
+			Run(tt.jobs, tt.n, func(i int) {
+				current := running.Add(1)
+				for {
+					old := peak.Load()
+					if current <= old || peak.CompareAndSwap(old, current) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+
+				mu.Lock()
+				done[i]++
+				mu.Unlock()
+			})
+
+			if len(done) != tt.n {
+				t.Errorf("Run() called fn for %d indexes, want %d", len(done), tt.n)
+			}
+			for i, calls := range done {
+				if calls != 1 {
+					t.Errorf("Run() called fn %d times for index %d", calls, i)
+				}
+			}
+			if limit := int32(max(tt.jobs, 1)); peak.Load() > limit {
+				t.Errorf("Run() ran %d calls concurrently, want at most %d", peak.Load(), limit)
+			}
+		})
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(2)
+	var running, peak atomic.Int32
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Do(func() error {
+				current := running.Add(1)
+				for {
+					old := peak.Load()
+					if current <= old || peak.CompareAndSwap(old, current) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() > 2 {
+		t.Errorf("semaphore allowed %d concurrent operations, want at most 2", peak.Load())
+	}
+
+	// TryAcquire doesn't block when all operations are running
+	s.Acquire()
+	if !s.TryAcquire() {
+		t.Error("TryAcquire() failed with a free slot")
+	}
+	if s.TryAcquire() {
+		t.Error("TryAcquire() succeeded without a free slot")
+	}
+	s.Release()
+	s.Release()
+
+	// a nil semaphore doesn't block
+	var unlimited Semaphore
+	if err := unlimited.Do(func() error { return nil }); err != nil {
+		t.Errorf("Do() error = %v", err)
+	}
+	if !unlimited.TryAcquire() {
+		t.Error("TryAcquire() of a nil semaphore failed")
+	}
+}
@@ -1,159 +1,385 @@
 package processor
 
 import (
+	"context"
 	"fmt"
-	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/manifest"
+	"github.com/nerten/albumpicker/pkg/pool"
+	"github.com/nerten/albumpicker/pkg/progress"
+	"github.com/nerten/albumpicker/pkg/transcode"
 )
 
-// FindAllAlbums recursively finds all directories containing FLAC files
-func FindAllAlbums(rootDir string) ([]string, error) {
-	var albums []string
-	var mutex sync.Mutex
+// FindAllAlbums recursively finds all directories containing audio files with the extensions,
+// nil extensions are the ones of all supported formats
+func FindAllAlbums(ctx context.Context, rootDir string, extensions []string) ([]string, error) {
+	// scan with an empty in-memory index, so every directory is read from disk
+	idx := library.New("")
+	idx.SetExtensions(extensions)
+	idx.Jobs = runtime.NumCPU()
+	found, err := idx.Scan(ctx, rootDir)
+	if err != nil {
+		return nil, err
+	}
 
-	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Error accessing path %s: %v\n", path, err)
-			return nil // continue walking despite the error
-		}
+	albums := make([]string, 0, len(found))
+	for _, album := range found {
+		albums = append(albums, album.Path)
+	}
 
-		// check for FLAC files in current directory
-		if info.IsDir() {
-			// read all entries in the current directory
-			entries, err := os.ReadDir(path)
-			if err != nil {
-				return nil
-			}
+	return albums, nil
+}
 
-			// check for FLAC files directly in this directory
-			hasFlac := false
-			for _, entry := range entries {
-				if isFlacFile(entry) {
-					hasFlac = true
-					break
-				}
-			}
+// EstimateAlbumSize estimates the size of the processed album in the destination directory
+func EstimateAlbumSize(album library.Album, config *config.Config) int64 {
+	size := EstimateCoverSize(config)
+	for _, file := range album.Files {
+		size += estimateFileSize(file, config)
+	}
+	return size
+}
 
-			if hasFlac {
-				// this directory contains FLAC files, treat it as an album
-				mutex.Lock()
-				albums = append(albums, path)
-				mutex.Unlock()
+// ProcessAlbums processes the selected albums and records copied albums in the destination manifest,
+// files of the albums are the ones found by the library scan. When ctx is cancelled files in progress
+// are removed and completed albums are listed. Progress is reported to the reporter carried by ctx
+func ProcessAlbums(ctx context.Context, albums []library.Album, config *config.Config) error {
+	enc, err := newEncoder(config)
+	if err != nil {
+		return err
+	}
 
-				// skip processing subdirectories of this album
-				return filepath.SkipDir
-			}
+	// remove files left by interrupted runs
+	if removed, err := CleanTempFiles(config.Destination); err != nil {
+		progress.Report(ctx, progress.Event{Kind: progress.Warning, Err: fmt.Errorf("could not remove temporary files: %s", err)})
+	} else if removed > 0 {
+		progress.Report(ctx, progress.Event{Kind: progress.Info, Message: fmt.Sprintf("Removed %d temporary files left by interrupted runs", removed)})
+	}
+
+	estimates := make([]int64, len(albums))
+	var total int64
+	for i, album := range albums {
+		estimates[i] = estimateAlbumBytes(album, config)
+		total += estimates[i]
+	}
+	progress.Report(ctx, progress.Event{Kind: progress.Start, Albums: len(albums), Bytes: total})
+	defer progress.Report(ctx, progress.Event{Kind: progress.Finish})
+
+	m := loadManifest(ctx, config.Destination)
+	limits := NewLimits(config)
+	albumErrs := make([]error, len(albums))
+	pool.Run(config.Jobs, len(albums), func(i int) {
+		// albums left when ctx is cancelled are never started
+		if err := ctx.Err(); err != nil {
+			albumErrs[i] = err
+			progress.Report(ctx, progress.Event{Kind: progress.AlbumNotProcessed, Album: albumRelPath(albums[i].Path, config),
+				Bytes: estimates[i]})
+			return
+		}
+		albumErrs[i] = processAlbum(ctx, albums[i], config, m, enc, limits)
+		if albumErrs[i] != nil && ctx.Err() == nil {
+			progress.Report(ctx, progress.Event{Kind: progress.Error, Album: albumRelPath(albums[i].Path, config),
+				Err: albumErrs[i], Bytes: estimates[i]})
 		}
-		return nil
 	})
 
-	return albums, err
-}
-
-// SelectRandomAlbums randomly selects n albums from the list
-func SelectRandomAlbums(albums []string, n int) []string {
-
-	// if n is greater than the number of albums, return all albums
-	if n >= len(albums) {
-		return albums
+	if ctx.Err() != nil {
+		reportInterrupted(ctx, albums, albumErrs)
+		return fmt.Errorf("interrupted: %w", ctx.Err())
 	}
 
-	// shuffle the albums
-	shuffled := make([]string, len(albums))
-	copy(shuffled, albums)
-	rand.Shuffle(len(shuffled), func(i, j int) {
-		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-	})
+	var failed int
+	for _, err := range albumErrs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d albums failed to process", failed)
+	}
 
-	// return the first n albums
-	return shuffled[:n]
+	progress.Report(ctx, progress.Event{Kind: progress.Info, Message: fmt.Sprintf("Successfully processed %d albums", len(albums))})
+	return nil
 }
 
-// ProcessAlbums processes the selected albums
-func ProcessAlbums(albums []string, config *config.Config) error {
-	var errs []error
-
-	for _, albumPath := range albums {
-		if err := ProcessAlbum(albumPath, config); err != nil {
-			errs = append(errs, fmt.Errorf("error processing album %s: %v", albumPath, err))
+// reportInterrupted lists albums completed before the interruption
+func reportInterrupted(ctx context.Context, albums []library.Album, albumErrs []error) {
+	var completed []string
+	for i, err := range albumErrs {
+		if err == nil {
+			completed = append(completed, albums[i].Path)
 		}
 	}
 
-	if len(errs) > 0 {
-		// print all errors
-		for _, err := range errs {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		}
-		return fmt.Errorf("%d albums failed to process", len(errs))
+	lines := []string{fmt.Sprintf("Interrupted, %d of %d albums were processed", len(completed), len(albums))}
+	for _, albumPath := range completed {
+		lines = append(lines, "  "+albumPath)
+	}
+	if len(completed) < len(albums) {
+		lines = append(lines, "Albums interrupted while copying are repaired by the next run")
 	}
+	progress.Report(ctx, progress.Event{Kind: progress.Info, Message: strings.Join(lines, "\n")})
+}
 
-	fmt.Printf("Successfully processed %d albums\n", len(albums))
-	return nil
+// estimateAlbumBytes estimates the number of bytes written for the album from its index entries,
+// the cover is only counted when the album has one
+func estimateAlbumBytes(album library.Album, config *config.Config) int64 {
+	var size int64
+	for _, file := range album.Files {
+		if file.Format == "" {
+			continue
+		}
+		size += estimateFileSize(file, config)
+	}
+	if findCoverFile(album.Path, config) != "" {
+		size += EstimateCoverSize(config)
+	}
+	return size
 }
 
-// ProcessAlbum processes a single album
-func ProcessAlbum(albumPath string, config *config.Config) error {
-	// check if album path is within source directory
-	if !isSubPath(config.Source, albumPath) {
-		return fmt.Errorf("album path %s is not within source directory %s", albumPath, config.Source)
+// ProcessAlbum reads the album directory and processes it, the album is recorded in the destination manifest
+func ProcessAlbum(ctx context.Context, albumPath string, config *config.Config) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	enc, err := newEncoder(config)
+	if err != nil {
+		return err
+	}
+	album, err := library.ReadAlbum(albumPath, config.AudioExtensions)
+	if err != nil {
+		return fmt.Errorf("error reading directory: %s", err)
+	}
+	return processAlbum(ctx, album, config, loadManifest(ctx, config.Destination), enc, NewLimits(config))
+}
 
-	// get the relative path from source directory
-	relPath, err := filepath.Rel(config.Source, albumPath)
+// processAlbum processes a single album and records it in the manifest,
+// the manifest is saved once the album is copied, audio files are processed concurrently
+// and FLAC files are transcoded with enc unless it's nil. An album interrupted by cancelling ctx stays marked incomplete,
+// so does an album with files which failed to process, it's recorded with the written files and an error is returned
+func processAlbum(ctx context.Context, album library.Album, config *config.Config, m *manifest.Manifest, enc transcode.Encoder, limits *Limits) error {
+	albumPath := album.Path
+
+	destAlbumPath, err := DestinationPath(albumPath, config)
 	if err != nil {
-		return fmt.Errorf("error getting relative path: %s", err)
+		return err
 	}
+	relPath, _ := filepath.Rel(config.Destination, destAlbumPath)
 
-	// check if destination album already exists
-	destAlbumPath := filepath.Join(config.Destination, relPath)
+	// check if destination album already exists, directories neither recorded in the manifest
+	// nor marked incomplete are never touched, they may be created by the user
+	copiedAt := time.Now()
+	repair, interrupted := false, false
+	var recorded *manifest.Album
 	if _, err := os.Stat(destAlbumPath); err == nil {
-		fmt.Printf("Skipping existing album: %s\n", relPath)
-		return nil
+		skipped := progress.Event{Kind: progress.AlbumSkipped, Album: relPath, Bytes: estimateAlbumBytes(album, config)}
+		var ok bool
+		recorded, ok = m.Get(relPath)
+		interrupted = manifest.IsIncomplete(destAlbumPath)
+		if !ok && !interrupted {
+			skipped.Message = "album not copied by albumpicker"
+			progress.Report(ctx, skipped)
+			return nil
+		}
+		if ok && len(recorded.Check(destAlbumPath, false)) == 0 {
+			skipped.Message = "existing album"
+			progress.Report(ctx, skipped)
+			return nil
+		}
+		if ok {
+			copiedAt = recorded.CopiedAt
+		}
+		repair = true
+	}
+
+	// audio files of the album were recognised by the scan, files of unknown format are skipped
+	var audioFiles []library.File
+	var flacFiles []string
+	for _, file := range album.Files {
+		if file.Format == "" {
+			continue
+		}
+		audioFiles = append(audioFiles, file)
+		if file.IsFLAC() {
+			flacFiles = append(flacFiles, filepath.Join(albumPath, file.Name))
+		}
+	}
+
+	if len(audioFiles) == 0 {
+		return fmt.Errorf("no audio files found in album: %s", relPath)
 	}
 
-	// create the destination album directory
+	// create the destination album directory and mark it incomplete before writing anything,
+	// so an interrupted copy is repaired by the next run
 	if err := os.MkdirAll(destAlbumPath, 0o755); err != nil {
 		return fmt.Errorf("error creating destination directory: %s", err)
 	}
+	if err := manifest.MarkIncomplete(destAlbumPath); err != nil {
+		return fmt.Errorf("error marking album incomplete: %s", err)
+	}
 
-	fmt.Printf("Processing album: %s\n", relPath)
+	progress.Report(ctx, progress.Event{Kind: progress.AlbumStarted, Album: relPath, Files: len(audioFiles), Repair: repair})
+
+	// process audio files, lossy sources are never transcoded again,
+	// records of written files are kept in the order of the album files
+	written := make([]*manifest.File, len(audioFiles))
+	var wg sync.WaitGroup
+	for i, file := range audioFiles {
+		name := outputFileName(file.Name, file.IsFLAC(), config)
+		if repair {
+			if copied, ok := copiedFile(name, destAlbumPath, recorded, interrupted); ok {
+				// the file was completely written before the interruption
+				written[i] = &copied
+				continue
+			}
+		}
+		audioFile := filepath.Join(albumPath, file.Name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var f manifest.File
+			err := limits.file(func() error {
+				var err error
+				if enc != nil && file.IsFLAC() {
+					f, err = TranscodeFLACFile(ctx, audioFile, albumPath, destAlbumPath, config, enc, limits)
+				} else {
+					f, err = ProcessAudioFile(ctx, audioFile, albumPath, destAlbumPath, config, limits)
+				}
+				return err
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				// continue processing other files despite the error
+				progress.Report(ctx, progress.Event{Kind: progress.Warning, Album: relPath, File: filepath.Base(audioFile),
+					Err: fmt.Errorf("error processing audio file %s: %v", audioFile, err)})
+				return
+			}
+			written[i] = &f
+			progress.Report(ctx, progress.Event{Kind: progress.FileWritten, Album: relPath, File: f.Name, Bytes: f.Size})
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	// find all FLAC files in the album
-	entries, err := os.ReadDir(albumPath)
+	// process cover files
+	cover, err := ProcessCoverFile(ctx, albumPath, destAlbumPath, config, limits)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	if err != nil {
-		return fmt.Errorf("error reading directory: %s", err)
+		// continue processing other albums despite the error
+		progress.Report(ctx, progress.Event{Kind: progress.Warning, Album: relPath,
+			Err: fmt.Errorf("error processing cover for album %s: %v", albumPath, err)})
+	} else if cover != nil {
+		progress.Report(ctx, progress.Event{Kind: progress.CoverWritten, Album: relPath, File: cover.Name, Bytes: cover.Size})
 	}
 
-	var flacFiles []string
-	for _, entry := range entries {
-		if isFlacFile(entry) {
-			flacFiles = append(flacFiles, filepath.Join(albumPath, entry.Name()))
+	// corrupted copies leave the album marked incomplete, transcoded files have no checksum to compare with
+	// and only FLAC files carry one
+	if config.Verify && !transcoding(config) && len(flacFiles) > 0 {
+		if err := verifyAlbum(ctx, flacFiles, albumPath, destAlbumPath); err != nil {
+			return err
 		}
 	}
 
-	fmt.Printf("Found %d FLAC files in album\n", len(flacFiles))
-
-	if len(flacFiles) == 0 {
-		return fmt.Errorf("no FLAC files found in album: %s", relPath)
+	recorded = &manifest.Album{Source: albumPath, CopiedAt: copiedAt, Cover: cover}
+	var failed int
+	for _, f := range written {
+		// files which failed to process are already reported
+		if f == nil {
+			failed++
+			continue
+		}
+		recorded.Files = append(recorded.Files, *f)
+	}
+	// an album with failed files stays marked incomplete, so the next run copies them again
+	recordAlbum(ctx, m, relPath, destAlbumPath, recorded, failed == 0)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d audio files failed to process", failed, len(audioFiles))
 	}
 
-	// process FLAC files
-	for _, flacFile := range flacFiles {
-		if err := ProcessFLACFile(flacFile, albumPath, destAlbumPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Error processing FLAC file %s: %v\n", flacFile, err)
-			// continue processing other files despite the error
+	progress.Report(ctx, progress.Event{Kind: progress.AlbumDone, Album: relPath})
+	return nil
+}
+
+// copiedFile returns the record of the file written to the destination album by an earlier run,
+// name is the name of the file in the destination. Files recorded in the manifest must still have
+// their recorded size. Other files are only accepted in albums interrupted while copying: files are
+// written atomically, so any file with the final name is complete, it's hashed for the manifest
+func copiedFile(name, destAlbumPath string, recorded *manifest.Album, interrupted bool) (manifest.File, bool) {
+	if recorded != nil {
+		if f, ok := recorded.File(name); ok {
+			return f, f.Check(destAlbumPath, false) == nil
 		}
 	}
+	if !interrupted {
+		return manifest.File{}, false
+	}
+	f, err := manifest.NewFile(filepath.Join(destAlbumPath, name))
+	if err != nil {
+		return manifest.File{}, false
+	}
+	return f, true
+}
+
+// DestinationPath returns the path of the album in the destination directory
+func DestinationPath(albumPath string, config *config.Config) (string, error) {
+	// check if album path is within source directory
+	if !isSubPath(config.Source, albumPath) {
+		return "", fmt.Errorf("album path %s is not within source directory %s", albumPath, config.Source)
+	}
 
-	// process cover files
-	if err := ProcessCoverFile(albumPath, destAlbumPath, config); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Error processing cover for album %s: %v\n", albumPath, err)
-		// continue processing other albums despite the error
+	// get the relative path from source directory
+	relPath, err := filepath.Rel(config.Source, albumPath)
+	if err != nil {
+		return "", fmt.Errorf("error getting relative path: %s", err)
+	}
+
+	return filepath.Join(config.Destination, relPath), nil
+}
+
+// albumRelPath returns the path of the album relative to the source directory, which is
+// its path in the destination directory, or the album path itself when it's outside of the source
+func albumRelPath(albumPath string, config *config.Config) string {
+	if !isSubPath(config.Source, albumPath) {
+		return albumPath
+	}
+	relPath, err := filepath.Rel(config.Source, albumPath)
+	if err != nil {
+		return albumPath
+	}
+	return relPath
+}
+
+// RemoveAlbum removes the album from the destination directory together with
+// parent directories left empty
+func RemoveAlbum(destAlbumPath, destination string) error {
+	if !isSubPath(destination, destAlbumPath) {
+		return fmt.Errorf("album path %s is not within destination directory %s", destAlbumPath, destination)
+	}
+	if err := os.RemoveAll(destAlbumPath); err != nil {
+		return err
+	}
+
+	for dir := filepath.Dir(filepath.Clean(destAlbumPath)); isSubPath(destination, dir); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			break
+		}
+		if err := os.Remove(dir); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -178,7 +404,3 @@ func isSubPath(basePath, targetPath string) bool {
 	// check if target path starts with base path
 	return absTarget != absBase && strings.HasPrefix(absTarget, absBase+string(os.PathSeparator))
 }
-
-func isFlacFile(entry os.DirEntry) bool {
-	return !entry.IsDir() && filepath.Ext(entry.Name()) == ".flac" && !strings.HasPrefix(entry.Name(), "._")
-}
@@ -1,67 +1,42 @@
 package processor
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/manifest"
+	"github.com/nerten/albumpicker/pkg/progress"
 )
 
-func TestSelectRandomAlbums(t *testing.T) {
-	tests := []struct {
-		name     string
-		albums   []string
-		n        int
-		wantLen  int
-		wantSame bool
-	}{
-		{
-			name:     "select less than available",
-			albums:   []string{"album1", "album2", "album3", "album4"},
-			n:        2,
-			wantLen:  2,
-			wantSame: false,
-		},
-		{
-			name:     "select more than available",
-			albums:   []string{"album1", "album2"},
-			n:        3,
-			wantLen:  2,
-			wantSame: true,
-		},
-		{
-			name:     "select exact amount",
-			albums:   []string{"album1", "album2", "album3"},
-			n:        3,
-			wantLen:  3,
-			wantSame: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := SelectRandomAlbums(tt.albums, tt.n)
-			if len(got) != tt.wantLen {
-				t.Errorf("selectRandomAlbums() got len = %v, want %v", len(got), tt.wantLen)
-			}
-			if tt.wantSame {
-				// check if all elements are present
-				for _, album := range tt.albums {
-					found := false
-					for _, selected := range got {
-						if album == selected {
-							found = true
-							break
-						}
-					}
-					if !found {
-						t.Errorf("selectRandomAlbums() missing album %v", album)
-					}
-				}
-			}
-		})
+// readAlbums reads the album directories the way the library scan finds them
+func readAlbums(t *testing.T, paths ...string) []library.Album {
+	t.Helper()
+	albums := make([]library.Album, len(paths))
+	for i, path := range paths {
+		album, err := library.ReadAlbum(path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		albums[i] = album
+	}
+	return albums
+}
+
+// testCoverData returns a valid JPEG cover, covers which can't be decoded are never written
+func testCoverData(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "test_data", "cover.jpg"))
+	if err != nil {
+		t.Fatal(err)
 	}
+	return data
 }
 
 func TestProcessAlbum(t *testing.T) {
@@ -91,7 +66,7 @@ func TestProcessAlbum(t *testing.T) {
 
 	// create test cover file
 	testCover := filepath.Join(albumDir, "cover.jpg")
-	if err := os.WriteFile(testCover, []byte("test jpg data"), 0o644); err != nil {
+	if err := os.WriteFile(testCover, testCoverData(t), 0o644); err != nil {
 		t.Fatal(err)
 	}
 
@@ -103,7 +78,7 @@ func TestProcessAlbum(t *testing.T) {
 		CoverHeight:     240,
 	}
 
-	err = ProcessAlbum(albumDir, cfg)
+	err = ProcessAlbum(context.Background(), albumDir, cfg)
 	if err != nil {
 		t.Errorf("processAlbum() error = %v", err)
 	}
@@ -118,6 +93,40 @@ func TestProcessAlbum(t *testing.T) {
 			t.Errorf("Expected file %s was not created", file)
 		}
 	}
+
+	// check if the album was recorded in the manifest
+	m, err := manifest.Load(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	album, ok := m.Get("testalbum")
+	if !ok {
+		t.Fatal("Album was not recorded in the manifest")
+	}
+	if album.Source != albumDir || len(album.Files) != 1 || album.Cover == nil || album.Cover.Source != "cover.jpg" {
+		t.Errorf("Manifest album = %+v", album)
+	}
+	if errs := album.Check(destAlbum, true); len(errs) > 0 {
+		t.Errorf("Manifest album doesn't match written files: %v", errs)
+	}
+
+	// albums not copied by albumpicker are not touched
+	userAlbum := filepath.Join(srcDir, "useralbum")
+	if err := os.MkdirAll(userAlbum, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(userAlbum, "test.flac"), []byte("test flac data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(destDir, "useralbum"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProcessAlbum(context.Background(), userAlbum, cfg); err != nil {
+		t.Errorf("processAlbum() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "useralbum", "test.flac")); !os.IsNotExist(err) {
+		t.Error("Album not copied by albumpicker was overwritten")
+	}
 }
 
 func TestFindAllAlbums(t *testing.T) {
@@ -133,7 +142,9 @@ func TestFindAllAlbums(t *testing.T) {
 		"album1":                          {"track1.flac", "track2.flac"},
 		"album2":                          {"track1.flac", "cover.jpg"},
 		"empty":                           {},
-		"noflac":                          {"track1.mp3", "track2.mp3"},
+		"mp3":                             {"track1.mp3", "track2.mp3"},
+		"unsupported":                     {"track1.m4a", "track2.wv"},
+		"windows":                         {"TRACK1.FLAC"},
 		filepath.Join("nested", "album3"): {"track1.flac"},
 	}
 
@@ -151,16 +162,18 @@ func TestFindAllAlbums(t *testing.T) {
 	}
 
 	// test finding albums
-	albums, err := FindAllAlbums(tmpDir)
+	albums, err := FindAllAlbums(context.Background(), tmpDir, nil)
 	if err != nil {
 		t.Errorf("findAllAlbums() error = %v", err)
 	}
 
-	// expected albums (directories containing .flac files)
+	// expected albums (directories containing files of supported formats)
 	expected := []string{
 		filepath.Join(tmpDir, "album1"),
 		filepath.Join(tmpDir, "album2"),
+		filepath.Join(tmpDir, "mp3"),
 		filepath.Join(tmpDir, "nested", "album3"),
+		filepath.Join(tmpDir, "windows"),
 	}
 
 	if len(albums) != len(expected) {
@@ -203,7 +216,7 @@ func TestProcessAlbums(t *testing.T) {
 		testFiles := map[string][]byte{
 			"track1.flac": []byte("test flac data 1"),
 			"track2.flac": []byte("test flac data 2"),
-			"cover.jpg":   []byte("test jpg data"),
+			"cover.jpg":   testCoverData(t),
 		}
 
 		for name, data := range testFiles {
@@ -223,12 +236,7 @@ func TestProcessAlbums(t *testing.T) {
 	}
 
 	// test processing albums
-	albumPaths := []string{
-		filepath.Join(srcDir, "album1"),
-		filepath.Join(srcDir, "album2"),
-	}
-
-	err = ProcessAlbums(albumPaths, cfg)
+	err = ProcessAlbums(context.Background(), readAlbums(t, filepath.Join(srcDir, "album1"), filepath.Join(srcDir, "album2")), cfg)
 	if err != nil {
 		t.Errorf("processAlbums() error = %v", err)
 	}
@@ -249,3 +257,413 @@ func TestProcessAlbums(t *testing.T) {
 		}
 	}
 }
+
+// recorder records the reported progress events
+type recorder struct {
+	mu     sync.Mutex
+	events []progress.Event
+}
+
+func (r *recorder) Report(e progress.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func TestProcessAlbumsEvents(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_events_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	for album, size := range map[string]int{"album1": 10, filepath.Join("artist", "album2"): 20} {
+		if err := os.MkdirAll(filepath.Join(srcDir, album), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(srcDir, album, "track1.mp3"), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	albums := readAlbums(t, filepath.Join(srcDir, "album1"), filepath.Join(srcDir, "artist", "album2"))
+
+	// the second album can't be created in the destination, so it fails
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "artist"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Source:          srcDir,
+		Destination:     destDir,
+		OutputCoverName: "cover.jpg",
+		CoverHeight:     240,
+		Jobs:            1,
+	}
+
+	r := &recorder{}
+	if err := ProcessAlbums(progress.WithReporter(context.Background(), r), albums, cfg); err == nil {
+		t.Error("ProcessAlbums() expected error for the failed album")
+	}
+
+	want := []progress.Event{
+		{Kind: progress.Start, Albums: 2, Bytes: 30},
+		{Kind: progress.AlbumStarted, Album: "album1", Files: 1},
+		{Kind: progress.Warning, Album: "album1", File: "track1.mp3"},
+		{Kind: progress.FileWritten, Album: "album1", File: "track1.mp3", Bytes: 10},
+		{Kind: progress.Info, Message: "No cover file found"},
+		{Kind: progress.AlbumDone, Album: "album1"},
+		{Kind: progress.Error, Album: filepath.Join("artist", "album2"), Bytes: 20},
+		{Kind: progress.Finish},
+	}
+	if len(r.events) != len(want) {
+		t.Fatalf("ProcessAlbums() reported %d events, want %d: %+v", len(r.events), len(want), r.events)
+	}
+	for i, e := range r.events {
+		e.Err = nil
+		if e != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestProcessAlbumsCancelled(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_cancel_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	albumDir := filepath.Join(srcDir, "album1")
+	if err := os.MkdirAll(albumDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(albumDir, "track1.flac"), []byte("test flac data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Source:          srcDir,
+		Destination:     destDir,
+		OutputCoverName: "cover.jpg",
+		CoverHeight:     240,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := &recorder{}
+	err = ProcessAlbums(progress.WithReporter(ctx, r), readAlbums(t, albumDir), cfg)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ProcessAlbums() error = %v, want %v", err, context.Canceled)
+	}
+	// the album is never started, so it's reported as not processed
+	notProcessed := false
+	for _, e := range r.events {
+		if e.Kind == progress.AlbumStarted {
+			t.Error("ProcessAlbums() with cancelled context started the album")
+		}
+		if e.Kind == progress.AlbumNotProcessed && e.Album == "album1" {
+			notProcessed = true
+		}
+	}
+	if !notProcessed {
+		t.Errorf("ProcessAlbums() events = %+v, want album1 not processed", r.events)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "album1")); !os.IsNotExist(err) {
+		t.Error("ProcessAlbums() with cancelled context created the album")
+	}
+
+	// a cancelled file isn't copied by the fallback either
+	if err := os.MkdirAll(filepath.Join(destDir, "album1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ProcessAudioFile(ctx, filepath.Join(albumDir, "track1.flac"), albumDir, filepath.Join(destDir, "album1"), &config.Config{}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ProcessAudioFile() error = %v, want %v", err, context.Canceled)
+	}
+	entries, err := os.ReadDir(filepath.Join(destDir, "album1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("ProcessAudioFile() with cancelled context left files: %v", entries)
+	}
+}
+
+func TestRemoveAlbum(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_remove_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// two albums of the same artist and a file next to them
+	for _, dir := range []string{
+		filepath.Join(tmpDir, "artist1", "album1"),
+		filepath.Join(tmpDir, "artist1", "album2"),
+		filepath.Join(tmpDir, "artist2", "album3"),
+	} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "track1.flac"), []byte("test data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "playlist.m3u"), []byte("test data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveAlbum(filepath.Join(tmpDir, "artist1", "album1"), tmpDir); err != nil {
+		t.Fatalf("RemoveAlbum() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "artist1", "album2")); err != nil {
+		t.Errorf("RemoveAlbum() removed another album: %v", err)
+	}
+
+	// empty artist directory is removed, the destination directory is kept
+	if err := RemoveAlbum(filepath.Join(tmpDir, "artist2", "album3"), tmpDir); err != nil {
+		t.Fatalf("RemoveAlbum() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "artist2")); !os.IsNotExist(err) {
+		t.Errorf("RemoveAlbum() left empty artist directory")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "playlist.m3u")); err != nil {
+		t.Errorf("RemoveAlbum() removed a file in destination directory: %v", err)
+	}
+
+	// paths outside of the destination directory are not removed
+	if err := RemoveAlbum(tmpDir, filepath.Join(tmpDir, "artist1")); err == nil {
+		t.Error("RemoveAlbum() expected error for path outside of destination directory")
+	}
+}
+
+func TestProcessAlbumRepair(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_repair_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	albumDir := filepath.Join(srcDir, "album")
+	if err := os.MkdirAll(albumDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join("../../test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"01.flac", "02.flac"} {
+		if err := os.WriteFile(filepath.Join(albumDir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Source:          srcDir,
+		Destination:     destDir,
+		OutputCoverName: "cover.jpg",
+		CoverHeight:     240,
+	}
+	if err := ProcessAlbum(context.Background(), albumDir, cfg); err != nil {
+		t.Fatalf("ProcessAlbum() error = %v", err)
+	}
+
+	// simulate an interrupted copy: the second file is truncated
+	destAlbum := filepath.Join(destDir, "album")
+	complete := filepath.Join(destAlbum, "01.flac")
+	truncated := filepath.Join(destAlbum, "02.flac")
+	info, err := os.Stat(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(truncated, 100); err != nil {
+		t.Fatal(err)
+	}
+	m, err := manifest.Load(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, _ := m.Get("album")
+	if err := manifest.MarkIncomplete(destAlbum); err != nil {
+		t.Fatal(err)
+	}
+	oldTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(complete, oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ProcessAlbum(context.Background(), albumDir, cfg); err != nil {
+		t.Fatalf("ProcessAlbum() error = %v", err)
+	}
+
+	repaired, err := os.Stat(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repaired.Size() != info.Size() {
+		t.Errorf("Truncated file has size %d after repair, want %d", repaired.Size(), info.Size())
+	}
+	kept, err := os.Stat(complete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !kept.ModTime().Equal(oldTime) {
+		t.Error("Completely copied file was written again")
+	}
+
+	m, err = manifest.Load(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	album, _ := m.Get("album")
+	if manifest.IsIncomplete(destAlbum) || !album.CopiedAt.Equal(recorded.CopiedAt) {
+		t.Errorf("Manifest album after repair = %+v", album)
+	}
+	if errs := album.Check(destAlbum, true); len(errs) > 0 {
+		t.Errorf("Repaired album doesn't match the manifest: %v", errs)
+	}
+
+	// the first copy interrupted before the album was recorded: files written
+	// atomically are kept, missing ones are copied
+	m.Remove("album")
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := manifest.MarkIncomplete(destAlbum); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(truncated); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProcessAlbum(context.Background(), albumDir, cfg); err != nil {
+		t.Fatalf("ProcessAlbum() error = %v", err)
+	}
+	if kept, err := os.Stat(complete); err != nil || !kept.ModTime().Equal(oldTime) {
+		t.Error("Completely copied file was written again")
+	}
+	m, err = manifest.Load(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	album, ok := m.Get("album")
+	if !ok || len(album.Files) != 2 || manifest.IsIncomplete(destAlbum) {
+		t.Fatalf("Manifest album after repair = %+v", album)
+	}
+	if errs := album.Check(destAlbum, true); len(errs) > 0 {
+		t.Errorf("Repaired album doesn't match the manifest: %v", errs)
+	}
+
+	// directories neither recorded nor marked incomplete are left alone
+	m.Remove("album")
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(truncated, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProcessAlbum(context.Background(), albumDir, cfg); err != nil {
+		t.Fatalf("ProcessAlbum() error = %v", err)
+	}
+	if info, err := os.Stat(truncated); err != nil || info.Size() != 100 {
+		t.Error("Album not copied by albumpicker was repaired")
+	}
+}
+
+func TestProcessAlbumFailedFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_failed_file_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	albumDir := filepath.Join(srcDir, "album")
+	if err := os.MkdirAll(albumDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join("../../test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"01.flac", "02.flac"} {
+		if err := os.WriteFile(filepath.Join(albumDir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	albums := readAlbums(t, albumDir)
+
+	// the second file becomes unreadable after the scan
+	unreadable := filepath.Join(albumDir, "02.flac")
+	if err := os.Remove(unreadable); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(unreadable, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Source:          srcDir,
+		Destination:     destDir,
+		OutputCoverName: "cover.jpg",
+		CoverHeight:     240,
+	}
+	if err := ProcessAlbums(context.Background(), albums, cfg); err == nil {
+		t.Fatal("ProcessAlbums() expected error for the unreadable file")
+	}
+
+	// the written file is recorded, the album stays incomplete
+	destAlbum := filepath.Join(destDir, "album")
+	m, err := manifest.Load(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	album, ok := m.Get("album")
+	if !ok || len(album.Files) != 1 || album.Files[0].Name != "01.flac" {
+		t.Fatalf("Manifest album with a failed file = %+v", album)
+	}
+	if !manifest.IsIncomplete(destAlbum) {
+		t.Error("Album with a failed file is not marked incomplete")
+	}
+
+	// the next run copies the missing file
+	if err := os.Remove(unreadable); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(unreadable, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := &recorder{}
+	if err := ProcessAlbums(progress.WithReporter(context.Background(), r), readAlbums(t, albumDir), cfg); err != nil {
+		t.Fatalf("ProcessAlbums() error = %v", err)
+	}
+	var written []string
+	for _, e := range r.events {
+		if e.Kind == progress.FileWritten {
+			written = append(written, e.File)
+		}
+	}
+	m, err = manifest.Load(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	album, _ = m.Get("album")
+	if len(album.Files) != 2 || manifest.IsIncomplete(destAlbum) {
+		t.Errorf("Manifest album after repair = %+v", album)
+	}
+	if errs := album.Check(destAlbum, true); len(errs) > 0 {
+		t.Errorf("Repaired album doesn't match the manifest: %v", errs)
+	}
+	if len(written) != 1 || written[0] != "02.flac" {
+		t.Errorf("Repair wrote %v, want only 02.flac", written)
+	}
+}
@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nerten/albumpicker/pkg/manifest"
+)
+
+// tempSuffix marks files which are being written, they are renamed to their final names when complete
+const tempSuffix = ".albumpicker-tmp"
+
+// atomicFile is a file written under a temporary name in the destination directory,
+// so an interrupted write never leaves a truncated file with the final name.
+// Written data is hashed on the way, so the manifest doesn't read files back
+type atomicFile struct {
+	f    *os.File
+	path string
+	hash hash.Hash
+	size int64
+}
+
+// createAtomic creates a temporary file which becomes path on Commit
+func createAtomic(path string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*"+tempSuffix)
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{f: f, path: path, hash: sha256.New()}, nil
+}
+
+// Write writes to the temporary file
+func (f *atomicFile) Write(p []byte) (int, error) {
+	n, err := f.f.Write(p)
+	f.hash.Write(p[:n])
+	f.size += int64(n)
+	return n, err
+}
+
+// Commit flushes the file to the disk and renames it to its final name,
+// it returns the manifest record of the written file
+func (f *atomicFile) Commit() (manifest.File, error) {
+	if err := f.f.Chmod(0o644); err != nil {
+		return manifest.File{}, err
+	}
+	if err := f.f.Sync(); err != nil {
+		return manifest.File{}, err
+	}
+	if err := f.f.Close(); err != nil {
+		return manifest.File{}, err
+	}
+	if err := os.Rename(f.f.Name(), f.path); err != nil {
+		return manifest.File{}, err
+	}
+	return manifest.File{Name: filepath.Base(f.path), Size: f.size, SHA256: hex.EncodeToString(f.hash.Sum(nil))}, nil
+}
+
+// Abort closes and removes the temporary file, it does nothing after a successful Commit
+func (f *atomicFile) Abort() {
+	f.f.Close()
+	os.Remove(f.f.Name())
+}
+
+// writeFileAtomic writes data to path through a temporary file,
+// the temporary file is removed when ctx is cancelled before the write completes
+func writeFileAtomic(ctx context.Context, path string, data []byte) (manifest.File, error) {
+	f, err := createAtomic(path)
+	if err != nil {
+		return manifest.File{}, err
+	}
+	defer f.Abort()
+
+	if _, err := io.Copy(f, contextReader{ctx: ctx, r: bytes.NewReader(data)}); err != nil {
+		return manifest.File{}, err
+	}
+	return f.Commit()
+}
+
+// contextReader stops reading once the context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// CleanTempFiles removes temporary files left in the destination directory by interrupted runs
+func CleanTempFiles(destination string) (int, error) {
+	var removed int
+	err := filepath.WalkDir(destination, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(d.Name(), tempSuffix) {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("error removing temporary file: %s", err)
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
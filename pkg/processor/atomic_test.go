@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nerten/albumpicker/pkg/manifest"
+)
+
+func TestAtomicFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_atomic_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "01.flac")
+
+	// aborted file leaves nothing behind
+	f, err := createAtomic(path)
+	if err != nil {
+		t.Fatalf("createAtomic() error = %v", err)
+	}
+	if _, err := f.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	f.Abort()
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Abort() left files: %v", entries)
+	}
+
+	// the final name appears only after commit
+	written, err := writeFileAtomic(context.Background(), path, []byte("complete"))
+	if err != nil {
+		t.Fatalf("writeFileAtomic() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "complete" {
+		t.Errorf("writeFileAtomic() wrote %q", data)
+	}
+	if want, err := manifest.NewFile(path); err != nil || written != want {
+		t.Errorf("writeFileAtomic() = %+v, want %+v", written, want)
+	}
+	entries, err = os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("writeFileAtomic() left temporary files: %v", entries)
+	}
+
+	// a cancelled write leaves nothing behind
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := writeFileAtomic(ctx, filepath.Join(tmpDir, "02.flac"), []byte("cancelled")); err == nil {
+		t.Error("writeFileAtomic() with cancelled context returned no error")
+	}
+	entries, err = os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("cancelled writeFileAtomic() left files: %v", entries)
+	}
+}
+
+func TestCleanTempFiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_clean_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	albumDir := filepath.Join(tmpDir, "artist", "album")
+	if err := os.MkdirAll(albumDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]bool{
+		"01.flac":                     true,
+		".02.flac.123" + tempSuffix:   false,
+		".cover.jpg.456" + tempSuffix: false,
+		"notes" + tempSuffix + ".txt": true,
+	}
+	for name := range files {
+		if err := os.WriteFile(filepath.Join(albumDir, name), []byte("test data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := CleanTempFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("CleanTempFiles() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("CleanTempFiles() removed %d files, want 2", removed)
+	}
+	for name, kept := range files {
+		_, err := os.Stat(filepath.Join(albumDir, name))
+		if kept && err != nil {
+			t.Errorf("CleanTempFiles() removed %s", name)
+		}
+		if !kept && !os.IsNotExist(err) {
+			t.Errorf("CleanTempFiles() kept %s", name)
+		}
+	}
+}
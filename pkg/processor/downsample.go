@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-flac/go-flac"
+
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/flacstream"
+	"github.com/nerten/albumpicker/pkg/library"
+)
+
+// downsampling checks if FLAC files exceeding the target sample rate or bit depth are converted,
+// transcoded files are left to the encoder
+func downsampling(config *config.Config) bool {
+	return config.Downsample && !transcoding(config)
+}
+
+// downsampleTarget returns the highest sample rate and bit depth of written FLAC files
+func downsampleTarget(config *config.Config) flacstream.Target {
+	return flacstream.Target{SampleRate: config.SampleRate, BitsPerSample: config.BitDepth}
+}
+
+// estimateDownsampledSize scales the size of the file audio by the ratio of the target and source
+// sample rates and bit depths, files of unknown format are estimated unchanged
+func estimateDownsampledSize(size int64, file library.File, config *config.Config) int64 {
+	if file.SampleRate <= 0 || file.BitsPerSample <= 0 {
+		return size
+	}
+	sampleRate := min(file.SampleRate, config.SampleRate)
+	bitsPerSample := min(file.BitsPerSample, config.BitDepth)
+	return size * int64(sampleRate*bitsPerSample) / int64(file.SampleRate*file.BitsPerSample)
+}
+
+// downsampleFLAC converts audio of the FLAC file exceeding the target sample rate or bit depth.
+// STREAMINFO is replaced and SEEKTABLE dropped as its offsets point into the old frames, CUESHEET is dropped
+// too when the sample rate changes. Files within the target and files failing to convert are left unchanged
+func downsampleFLAC(ctx context.Context, file *flac.File, config *config.Config) error {
+	if len(file.Meta) == 0 || file.Meta[0].Type != flac.StreamInfo {
+		return fmt.Errorf("STREAMINFO must be the first metadata block")
+	}
+	info, err := flacstream.ParseStreamInfo(file.Meta[0].Data)
+	if err != nil {
+		return err
+	}
+	target := downsampleTarget(config)
+	if !target.Exceeds(info) {
+		return nil
+	}
+
+	newInfo, frames, err := flacstream.Convert(ctx, info, file.Frames, target)
+	if err != nil {
+		return err
+	}
+
+	var newMetadata []*flac.MetaDataBlock
+	for _, block := range file.Meta {
+		switch {
+		case block.Type == flac.StreamInfo:
+			block = &flac.MetaDataBlock{Type: flac.StreamInfo, Data: newInfo.Marshal()}
+		case block.Type == flac.SeekTable:
+			continue
+		case block.Type == flac.CueSheet && newInfo.SampleRate != info.SampleRate:
+			continue
+		}
+		newMetadata = append(newMetadata, block)
+	}
+	file.Meta = newMetadata
+	file.Frames = frames
+	return nil
+}
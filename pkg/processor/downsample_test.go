@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-flac/flacvorbis"
+	"github.com/go-flac/go-flac"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/flacstream"
+	"github.com/nerten/albumpicker/pkg/library"
+)
+
+// writeHiResFLAC writes a second of a 24-bit 96kHz stereo tone with a SEEKTABLE and a Vorbis comment
+func writeHiResFLAC(t *testing.T, path string) {
+	t.Helper()
+	enc, err := flacstream.NewEncoder(96000, 2, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tone := make([]int32, 96000)
+	for i := range tone {
+		tone[i] = int32(4_000_000 * math.Sin(2*math.Pi*440*float64(i)/96000))
+	}
+	if err := enc.Write([][]int32{tone, tone}); err != nil {
+		t.Fatal(err)
+	}
+	info, frames := enc.Finish()
+
+	comment := flacvorbis.New()
+	if err := comment.Add(flacvorbis.FIELD_ALBUM, "Hi-res album"); err != nil {
+		t.Fatal(err)
+	}
+	vorbis := comment.Marshal()
+	file := &flac.File{
+		Meta: []*flac.MetaDataBlock{
+			{Type: flac.StreamInfo, Data: info.Marshal()},
+			{Type: flac.SeekTable, Data: make([]byte, 18)},
+			&vorbis,
+		},
+		Frames: frames,
+	}
+	if err := os.WriteFile(path, file.Marshal(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownsampleAlbum(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_downsample_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	albumDir := filepath.Join(srcDir, "hires")
+	if err := os.MkdirAll(albumDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeHiResFLAC(t, filepath.Join(albumDir, "01 - hires.flac"))
+	// the CD quality file is within the target and copied as usual
+	data, err := os.ReadFile(filepath.Join("..", "..", "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(albumDir, "02 - cd.flac"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Source:          srcDir,
+		Destination:     destDir,
+		OutputCoverName: "cover.jpg",
+		Downsample:      true,
+		SampleRate:      44100,
+		BitDepth:        16,
+		Verify:          true,
+	}
+
+	// the hi-res file is estimated at a bitrate 16/24 * 44.1/96 of the source
+	hires, err := library.Stat(filepath.Join(albumDir, "01 - hires.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := estimateFileSize(hires, cfg), hires.Size*44100*16/(96000*24); got != want {
+		t.Errorf("estimateFileSize() = %d, want %d", got, want)
+	}
+
+	if err := ProcessAlbums(context.Background(), readAlbums(t, albumDir), cfg); err != nil {
+		t.Fatalf("ProcessAlbums() error = %v", err)
+	}
+
+	file, err := flac.ParseFile(filepath.Join(destDir, "hires", "01 - hires.flac"))
+	if err != nil {
+		t.Fatalf("downsampled file is missing: %v", err)
+	}
+	info, err := flacstream.ParseStreamInfo(file.Meta[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SampleRate != 44100 || info.BitsPerSample != 16 || info.TotalSamples != 44100 {
+		t.Errorf("downsampled STREAMINFO = %+v, want a second of 16 bits at 44.1kHz", info)
+	}
+	var types []flac.BlockType
+	for _, block := range file.Meta {
+		types = append(types, block.Type)
+	}
+	if len(types) != 2 || types[1] != flac.VorbisComment {
+		t.Errorf("downsampled metadata blocks = %v, want STREAMINFO and VORBIS_COMMENT", types)
+	}
+
+	copied, err := os.ReadFile(filepath.Join(destDir, "hires", "02 - cd.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := audio.ReadMetadata(filepath.Join(albumDir, "02 - cd.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(copied)) != int64(len(data))-meta.Stripped {
+		t.Errorf("file within the target was converted, got %d bytes, want %d", len(copied), int64(len(data))-meta.Stripped)
+	}
+}
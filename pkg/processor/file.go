@@ -0,0 +1,139 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/manifest"
+	"github.com/nerten/albumpicker/pkg/progress"
+)
+
+// ProcessAudioFile processes a single audio file, reads and writes are bounded by limits,
+// ctx.Err() is returned when ctx is cancelled and nothing is left in the destination.
+// It returns the manifest record of the written file
+func ProcessAudioFile(ctx context.Context, audioFile, srcAlbumPath, destAlbumPath string, config *config.Config, limits *Limits) (manifest.File, error) {
+	// try to strip embedded pictures with the handler of the file format
+	written, err := processAudioWithHandler(ctx, audioFile, srcAlbumPath, destAlbumPath, config, limits)
+	if err != nil && ctx.Err() == nil {
+		// if processing with the handler fails, fall back to simple copy
+		progress.Report(ctx, progress.Event{Kind: progress.Warning, Album: albumRelPath(srcAlbumPath, config), File: filepath.Base(audioFile),
+			Err: fmt.Errorf("failed to process %s, copying it without removing embedded pictures: %v", filepath.Base(audioFile), err)})
+		written, err = simpleCopyFile(ctx, audioFile, srcAlbumPath, destAlbumPath, limits)
+	}
+	if ctx.Err() != nil {
+		return manifest.File{}, ctx.Err()
+	}
+	return written, err
+}
+
+// processAudioWithHandler processes a single audio file by removing embedded pictures and padding,
+// applying the metadata block policy and downsampling to FLAC files and copying it to the destination
+func processAudioWithHandler(ctx context.Context, audioFile, srcAlbumPath, destAlbumPath string, config *config.Config, limits *Limits) (manifest.File, error) {
+	handler, err := audio.Detect(audioFile)
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	// get the relative path from album directory
+	relFilePath, err := filepath.Rel(srcAlbumPath, audioFile)
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error getting relative file path: %s", err)
+	}
+
+	// create the destination file path
+	destFilePath := filepath.Join(destAlbumPath, relFilePath)
+
+	// the file is buffered only while holding a write slot, so no more than write_jobs files are in memory
+	var written manifest.File
+	err = limits.write(func() error {
+		// read the whole file, handlers rewrite it in memory
+		var data []byte
+		err := limits.read(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var err error
+			data, err = os.ReadFile(audioFile)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error reading file: %s", err)
+		}
+		if !handler.Detect(data) {
+			return fmt.Errorf("not a %s file", handler.Name())
+		}
+
+		// remove embedded pictures and padding, FLAC files follow the metadata block policy
+		data, err = handler.StripArt(data, stripOptions(ctx, albumRelPath(srcAlbumPath, config), filepath.Base(audioFile), config))
+		if err != nil {
+			return err
+		}
+
+		// write the processed file to destination
+		written, err = writeFileAtomic(ctx, destFilePath, data)
+		if err != nil {
+			return fmt.Errorf("error saving file: %s", err)
+		}
+		return nil
+	})
+	return written, err
+}
+
+// simpleCopyFile is a fallback method that copies the audio file without processing it
+// This can be used if the file can't be parsed by its format handler
+func simpleCopyFile(ctx context.Context, audioFile, srcAlbumPath, destAlbumPath string, limits *Limits) (manifest.File, error) {
+	// get the relative path from album directory
+	relFilePath, err := filepath.Rel(srcAlbumPath, audioFile)
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error getting relative file path: %s", err)
+	}
+
+	// create the destination file path
+	destFilePath := filepath.Join(destAlbumPath, relFilePath)
+
+	// the file is read and written at once
+	var written manifest.File
+	err = limits.write(func() error {
+		return limits.read(func() error {
+			var err error
+			written, err = copyFile(ctx, audioFile, destFilePath)
+			return err
+		})
+	})
+	return written, err
+}
+
+// copyFile copies the file to destFilePath atomically, it stops when ctx is cancelled
+func copyFile(ctx context.Context, srcFilePath, destFilePath string) (manifest.File, error) {
+	// open source file
+	srcFile, err := os.Open(srcFilePath)
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error opening source file: %s", err)
+	}
+	defer srcFile.Close()
+
+	// create destination file
+	destFile, err := createAtomic(destFilePath)
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error creating destination file: %s", err)
+	}
+	defer destFile.Abort()
+
+	// copy file contents
+	_, err = io.Copy(destFile, contextReader{ctx: ctx, r: srcFile})
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error copying file: %s", err)
+	}
+
+	written, err := destFile.Commit()
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error saving file: %s", err)
+	}
+
+	return written, nil
+}
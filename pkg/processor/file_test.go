@@ -0,0 +1,227 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/manifest"
+)
+
+func TestProcessAudioWithHandler(t *testing.T) {
+	// create temporary directory for test output
+	tmpDir, err := os.MkdirTemp("", "flac_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// setup test paths
+	testDataDir := "../../test_data"
+	srcFile := filepath.Join(testDataDir, "01 - test.flac")
+	destDir := filepath.Join(tmpDir, "dest")
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = processAudioWithHandler(context.Background(), srcFile, testDataDir, destDir, &config.Config{}, nil)
+	if err != nil {
+		t.Errorf("ProcessAudioWithHandler() error = %v", err)
+	}
+
+	// verify output file exists
+	destFile := filepath.Join(destDir, "01 - test.flac")
+	if _, err := os.Stat(destFile); os.IsNotExist(err) {
+		t.Error("Destination file was not created")
+	}
+
+	// verify file size (should be smaller or equal due to removed PICTURE blocks)
+	srcInfo, err := os.Stat(srcFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destInfo, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if destInfo.Size() > srcInfo.Size() {
+		t.Error("Processed file is larger than source file")
+	}
+}
+
+func TestSimpleCopyFile(t *testing.T) {
+	// create temporary directory for test output
+	tmpDir, err := os.MkdirTemp("", "flac_copy_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// setup test paths
+	testDataDir := "../../test_data"
+	srcFile := filepath.Join(testDataDir, "01 - test.flac")
+	destDir := filepath.Join(tmpDir, "dest")
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = simpleCopyFile(context.Background(), srcFile, testDataDir, destDir, nil)
+	if err != nil {
+		t.Errorf("SimpleCopyFile() error = %v", err)
+	}
+
+	// verify output file exists
+	destFile := filepath.Join(destDir, "01 - test.flac")
+	if _, err := os.Stat(destFile); os.IsNotExist(err) {
+		t.Error("Destination file was not created")
+	}
+
+	// verify file contents are identical
+	srcData, err := os.ReadFile(srcFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destData, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(srcData, destData) {
+		t.Error("Destination file content does not match source")
+	}
+}
+
+func TestProcessAudioFile(t *testing.T) {
+	// create temporary test directories
+	tmpDir, err := os.MkdirTemp("", "albumpicker_flac_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// setup test paths
+	testDataDir := "../../test_data"
+	srcDir := filepath.Clean(testDataDir)
+	destDir := filepath.Join(tmpDir, "dest")
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// use real FLAC file from test_data
+	testFlac := filepath.Join(srcDir, "01 - test.flac")
+	if _, err := os.Stat(testFlac); os.IsNotExist(err) {
+		t.Fatalf("Test FLAC file not found: %s", testFlac)
+	}
+
+	written, err := ProcessAudioFile(context.Background(), testFlac, srcDir, destDir, &config.Config{}, nil)
+	if err != nil {
+		t.Errorf("processFLACFile() error = %v", err)
+	}
+
+	// check if destination file exists
+	destFile := filepath.Join(destDir, "01 - test.flac")
+	if _, err := os.Stat(destFile); os.IsNotExist(err) {
+		t.Error("Destination FLAC file was not created")
+	}
+
+	// the returned record is hashed while writing
+	if want, err := manifest.NewFile(destFile); err != nil || written != want {
+		t.Errorf("ProcessAudioFile() = %+v, want %+v", written, want)
+	}
+
+	// verify file size (should be smaller or equal due to removed PICTURE blocks)
+	srcInfo, err := os.Stat(testFlac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destInfo, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if destInfo.Size() > srcInfo.Size() {
+		t.Error("Processed file is larger than source file")
+	}
+}
+
+// writeMP3 writes an MP3 file with an ID3v2.3 tag holding a title and a picture,
+// followed by a few frames of silence
+func writeMP3(t *testing.T, path string, picture []byte) {
+	t.Helper()
+	var body []byte
+	for _, frame := range []struct {
+		id   string
+		data []byte
+	}{
+		{"TIT2", []byte("\x00Title")},
+		{"APIC", append([]byte("\x00image/jpeg\x00\x03\x00"), picture...)},
+	} {
+		body = append(body, frame.id...)
+		body = binary.BigEndian.AppendUint32(body, uint32(len(frame.data)))
+		body = append(body, 0, 0)
+		body = append(body, frame.data...)
+	}
+	size := len(body)
+	data := []byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	data = append(data, body...)
+	for range 10 {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+		data = append(data, frame...)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessMP3File(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_mp3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	for _, dir := range []string{srcDir, destDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	picture := bytes.Repeat([]byte{0xff, 0xd8}, 5000)
+	srcFile := filepath.Join(srcDir, "01 - track.mp3")
+	writeMP3(t, srcFile, picture)
+
+	_, err = ProcessAudioFile(context.Background(), srcFile, srcDir, destDir, &config.Config{}, nil)
+	if err != nil {
+		t.Fatalf("ProcessAudioFile() error = %v", err)
+	}
+
+	srcData, err := os.ReadFile(srcFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destData, err := os.ReadFile(filepath.Join(destDir, "01 - track.mp3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(destData, picture[:100]) {
+		t.Error("APIC frame was not removed")
+	}
+	if !bytes.Contains(destData, []byte("Title")) || !bytes.HasSuffix(srcData, destData[len(destData)-4170:]) {
+		t.Error("tags or audio frames were not kept")
+	}
+	meta, err := audio.ReadMetadata(srcFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(destData)) != int64(len(srcData))-meta.Stripped {
+		t.Errorf("processed file has %d bytes, want %d", len(destData), int64(len(srcData))-meta.Stripped)
+	}
+}
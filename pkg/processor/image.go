@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/jpeg"
@@ -11,109 +12,165 @@ import (
 
 	"github.com/disintegration/imaging"
 	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/manifest"
+	"github.com/nerten/albumpicker/pkg/progress"
 )
 
-// ProcessCoverFile processes the album cover
-func ProcessCoverFile(srcAlbumPath, destAlbumPath string, config *config.Config) error {
+// ProcessCoverFile processes the album cover, reads and writes are bounded by limits,
+// ctx.Err() is returned when ctx is cancelled and nothing is left in the destination.
+// It returns the manifest record of the written cover, which is nil when the album has no cover
+func ProcessCoverFile(ctx context.Context, srcAlbumPath, destAlbumPath string, config *config.Config, limits *Limits) (*manifest.Cover, error) {
 
 	// check for cover files
-	var coverFile string
+	coverFile := findCoverFile(srcAlbumPath, config)
+	if coverFile == "" {
+		progress.Report(ctx, progress.Event{Kind: progress.Info, Message: "No cover file found"})
+		return nil, nil
+	}
+
+	// try to use the imaging library to process the cover
+	written, err := processImageWithLibrary(ctx, coverFile, destAlbumPath, config, limits)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		// if processing with the library fails, fall back to simple copy
+		progress.Report(ctx, progress.Event{Kind: progress.Warning, File: filepath.Base(coverFile),
+			Err: fmt.Errorf("failed to process cover %s with imaging library, copying it without resizing: %v", filepath.Base(coverFile), err)})
+		written, err = fallbackCopyCover(ctx, coverFile, destAlbumPath, config.OutputCoverName, limits)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &manifest.Cover{File: written, Source: filepath.Base(coverFile), Height: config.CoverHeight}, nil
+}
+
+// findCoverFile returns the first existing cover file of the album, or an empty string
+func findCoverFile(srcAlbumPath string, config *config.Config) string {
 	for _, coverName := range config.CoverFilenames {
 		candidate := filepath.Join(srcAlbumPath, coverName)
 		if _, err := os.Stat(candidate); err == nil {
-			coverFile = candidate
-			break
+			return candidate
 		}
 	}
+	return ""
+}
 
-	if coverFile != "" {
-		fmt.Printf("  Found cover file: %s\n", filepath.Base(coverFile))
-
-		// try to use the imaging library to process the cover
-		err := processImageWithLibrary(coverFile, destAlbumPath, config)
-		if err != nil {
-			// if processing with the library fails, fall back to simple copy
-			fmt.Fprintf(os.Stderr, "Warning: Failed to process cover with imaging library: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Falling back to simple copy (cover will not be resized)\n")
-			return fallbackCopyCover(coverFile, destAlbumPath, config.OutputCoverName)
-		}
-	} else {
-		fmt.Printf("no cover file found")
-	}
-	return nil
+// EstimateCoverSize estimates the size of the processed cover,
+// a square JPEG with quality 85 takes about half a byte per pixel
+func EstimateCoverSize(config *config.Config) int64 {
+	return int64(config.CoverHeight) * int64(config.CoverHeight) / 2
 }
 
 // processCoverFile processes the album cover by finding, resizing, and converting it to JPG
-func processImageWithLibrary(coverFile, destAlbumPath string, config *config.Config) error {
+func processImageWithLibrary(ctx context.Context, coverFile, destAlbumPath string, config *config.Config, limits *Limits) (manifest.File, error) {
 	// create destination cover file path
 	destCoverPath := filepath.Join(destAlbumPath, config.OutputCoverName)
 
-	fmt.Printf("  Processing cover to %dpx height\n", config.CoverHeight)
-
 	// open the source image
-	srcImage, err := imaging.Open(coverFile)
+	var srcImage image.Image
+	err := limits.read(func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		srcImage, err = imaging.Open(coverFile)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("error opening image: %s", err)
+		return manifest.File{}, fmt.Errorf("error opening image: %s", err)
 	}
 
-	// resize image to the desired height while preserving aspect ratio
-	newWidth := int(float64(config.CoverHeight) * float64(srcImage.Bounds().Dx()) / float64(srcImage.Bounds().Dy()))
-	resized := imaging.Resize(srcImage, newWidth, config.CoverHeight, imaging.Lanczos)
+	resized := resizeToHeight(srcImage, config.CoverHeight)
 
-	// create the destination file
-	destFile, err := os.Create(destCoverPath)
-	if err != nil {
-		return fmt.Errorf("error creating destination file: %s", err)
-	}
-	defer destFile.Close()
+	var written manifest.File
+	err = limits.write(func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-	// save as JPEG with quality 85
-	opts := jpeg.Options{Quality: 85}
-	if err := jpeg.Encode(destFile, resized, &opts); err != nil {
-		return fmt.Errorf("error encoding JPEG: %s", err)
-	}
+		// create the destination file
+		destFile, err := createAtomic(destCoverPath)
+		if err != nil {
+			return fmt.Errorf("error creating destination file: %s", err)
+		}
+		defer destFile.Abort()
 
-	return nil
+		// save as JPEG with quality 85
+		opts := jpeg.Options{Quality: 85}
+		if err := jpeg.Encode(destFile, resized, &opts); err != nil {
+			return fmt.Errorf("error encoding JPEG: %s", err)
+		}
+
+		written, err = destFile.Commit()
+		if err != nil {
+			return fmt.Errorf("error saving cover: %s", err)
+		}
+
+		return nil
+	})
+	return written, err
+}
+
+// resizeToHeight resizes the image to the height while preserving aspect ratio
+func resizeToHeight(img image.Image, height int) image.Image {
+	width := int(float64(height) * float64(img.Bounds().Dx()) / float64(img.Bounds().Dy()))
+	return imaging.Resize(img, width, height, imaging.Lanczos)
 }
 
 // fallbackCopyCover is a fallback method that copies the cover file without processing it
 // This can be used if the imaging library fails or is not available
-func fallbackCopyCover(coverFile, destAlbumPath, outputCoverName string) error {
+func fallbackCopyCover(ctx context.Context, coverFile, destAlbumPath, outputCoverName string, limits *Limits) (manifest.File, error) {
 	// create destination cover file path
 	destCoverPath := filepath.Join(destAlbumPath, outputCoverName)
 
-	fmt.Printf("  Copying cover (without processing): %s\n", filepath.Base(coverFile))
+	// the cover is read and written at once
+	var written manifest.File
+	err := limits.write(func() error {
+		return limits.read(func() error {
+			var err error
+			written, err = copyCover(ctx, coverFile, destCoverPath)
+			return err
+		})
+	})
+	return written, err
+}
 
+// copyCover copies the cover to destCoverPath, converting it to JPEG if needed, it stops when ctx is cancelled
+func copyCover(ctx context.Context, coverFile, destCoverPath string) (manifest.File, error) {
 	// open source file
 	srcFile, err := os.Open(coverFile)
 	if err != nil {
-		return fmt.Errorf("error opening source file: %s", err)
+		return manifest.File{}, fmt.Errorf("error opening source file: %s", err)
 	}
 	defer srcFile.Close()
 
 	// create destination file
-	destFile, err := os.Create(destCoverPath)
+	destFile, err := createAtomic(destCoverPath)
 	if err != nil {
-		return fmt.Errorf("error creating destination file: %s", err)
+		return manifest.File{}, fmt.Errorf("error creating destination file: %s", err)
 	}
-	defer destFile.Close()
+	defer destFile.Abort()
 
 	// copy file contents
 	_, err = os.ReadFile(coverFile)
 	if err != nil {
-		return fmt.Errorf("error reading source file: %s", err)
+		return manifest.File{}, fmt.Errorf("error reading source file: %s", err)
 	}
 
 	// open the source image
-	img, _, err := image.Decode(srcFile)
+	img, _, err := image.Decode(contextReader{ctx: ctx, r: srcFile})
 	if err != nil {
-		return fmt.Errorf("error decoding image: %s", err)
+		return manifest.File{}, fmt.Errorf("error decoding image: %s", err)
 	}
 
 	// reset file pointer
 	_, err = srcFile.Seek(0, io.SeekStart)
 	if err != nil {
-		return fmt.Errorf("error seeking file: %s", err)
+		return manifest.File{}, fmt.Errorf("error seeking file: %s", err)
 	}
 
 	// if the source is already a JPEG, just copy it
@@ -121,20 +178,25 @@ func fallbackCopyCover(coverFile, destAlbumPath, outputCoverName string) error {
 		strings.ToLower(filepath.Ext(coverFile)) == ".jpeg" {
 		_, err = srcFile.Seek(0, io.SeekStart)
 		if err != nil {
-			return fmt.Errorf("error seeking file: %s", err)
+			return manifest.File{}, fmt.Errorf("error seeking file: %s", err)
 		}
 
-		_, err = destFile.ReadFrom(srcFile)
+		_, err = io.Copy(destFile, contextReader{ctx: ctx, r: srcFile})
 		if err != nil {
-			return fmt.Errorf("error copying file: %s", err)
+			return manifest.File{}, fmt.Errorf("error copying file: %s", err)
 		}
 	} else {
 		// encode as JPEG with quality 85
 		opts := jpeg.Options{Quality: 85}
 		if err := jpeg.Encode(destFile, img, &opts); err != nil {
-			return fmt.Errorf("error encoding JPEG: %s", err)
+			return manifest.File{}, fmt.Errorf("error encoding JPEG: %s", err)
 		}
 	}
 
-	return nil
+	written, err := destFile.Commit()
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error saving cover: %s", err)
+	}
+
+	return written, nil
 }
@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"context"
 	"image"
 	"os"
 	"path/filepath"
@@ -40,7 +41,7 @@ func TestProcessCoverFile(t *testing.T) {
 		CoverHeight:     240,
 	}
 
-	err = ProcessCoverFile(srcDir, destDir, cfg)
+	_, err = ProcessCoverFile(context.Background(), srcDir, destDir, cfg, nil)
 	if err != nil {
 		t.Errorf("processCoverFile() error = %v", err)
 	}
@@ -89,7 +90,7 @@ func TestProcessImageWithLibrary(t *testing.T) {
 		CoverHeight:     240,
 	}
 
-	err = processImageWithLibrary(srcFile, destDir, cfg)
+	_, err = processImageWithLibrary(context.Background(), srcFile, destDir, cfg, nil)
 	if err != nil {
 		t.Errorf("ProcessImageWithLibrary() error = %v", err)
 	}
@@ -133,7 +134,7 @@ func TestFallbackCopyCover(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = fallbackCopyCover(srcFile, destDir, "cover.jpg")
+	_, err = fallbackCopyCover(context.Background(), srcFile, destDir, "cover.jpg", nil)
 	if err != nil {
 		t.Errorf("FallbackCopyCover() error = %v", err)
 	}
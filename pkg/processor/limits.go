@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/pool"
+)
+
+// Limits bounds the number of files processed concurrently and, separately, concurrent
+// reads of source files and writes to the destination, a nil Limits doesn't limit anything.
+// Operations holding both slots acquire the write slot first
+type Limits struct {
+	files  pool.Semaphore
+	reads  pool.Semaphore
+	writes pool.Semaphore
+}
+
+// NewLimits creates limits configured by jobs, read_jobs and write_jobs
+func NewLimits(config *config.Config) *Limits {
+	return &Limits{
+		files:  pool.NewSemaphore(max(config.Jobs, 1)),
+		reads:  pool.NewSemaphore(config.ReadJobs),
+		writes: pool.NewSemaphore(config.WriteJobs),
+	}
+}
+
+// file runs processing of a single file
+func (l *Limits) file(fn func() error) error {
+	if l == nil {
+		return fn()
+	}
+	return l.files.Do(fn)
+}
+
+// read runs fn reading a source file
+func (l *Limits) read(fn func() error) error {
+	if l == nil {
+		return fn()
+	}
+	return l.reads.Do(fn)
+}
+
+// write runs fn writing a destination file
+func (l *Limits) write(fn func() error) error {
+	if l == nil {
+		return fn()
+	}
+	return l.writes.Do(fn)
+}
@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nerten/albumpicker/pkg/manifest"
+	"github.com/nerten/albumpicker/pkg/progress"
+)
+
+// loadManifest loads the manifest of the destination directory, a broken manifest is replaced with an empty one
+func loadManifest(ctx context.Context, destination string) *manifest.Manifest {
+	m, err := manifest.Load(destination)
+	if err != nil {
+		progress.Report(ctx, progress.Event{Kind: progress.Warning, Err: fmt.Errorf("%s, creating a new manifest", err)})
+		return manifest.New(destination)
+	}
+	return m
+}
+
+// recordAlbum records the album copied to destAlbumPath and saves the manifest, the album is marked
+// complete once the manifest is saved when complete is set. Files are already copied at this point,
+// so errors are only reported and the album stays marked incomplete
+func recordAlbum(ctx context.Context, m *manifest.Manifest, relPath, destAlbumPath string, album *manifest.Album, complete bool) {
+	m.Add(relPath, album)
+	if err := m.Save(); err != nil {
+		progress.Report(ctx, progress.Event{Kind: progress.Warning, Album: relPath, Err: fmt.Errorf("could not save manifest: %s", err)})
+		return
+	}
+	if !complete {
+		return
+	}
+	if err := manifest.MarkComplete(destAlbumPath); err != nil {
+		progress.Report(ctx, progress.Event{Kind: progress.Warning, Album: relPath, Err: fmt.Errorf("could not mark album %s complete: %s", relPath, err)})
+	}
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"github.com/go-flac/go-flac"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/progress"
+)
+
+// metadataPolicy checks if FLAC metadata blocks are kept, dropped or added beyond removing
+// all PICTURE and PADDING blocks, which makes sizes of written files unpredictable
+func metadataPolicy(config *config.Config) bool {
+	return config.FLACFrontCover || config.FLACDropApplication || config.FLACDropCueSheet ||
+		config.FLACDropSeekTable || config.FLACPadding > 0
+}
+
+// stripOptions returns what StripArt keeps in the file of the album, the first front cover of FLAC files
+// is resized to the cover height and FLAC files are downsampled when it's enabled. Warnings are reported
+// for covers removed because they can't be resized and for hi-res audio which is copied unchanged
+func stripOptions(ctx context.Context, album, name string, config *config.Config) audio.StripOptions {
+	opts := audio.StripOptions{
+		FrontCover: config.FLACFrontCover,
+		ResizeCover: func(picture *audio.Picture) error {
+			err := resizePicture(picture, config.CoverHeight)
+			if err != nil {
+				progress.Report(ctx, progress.Event{Kind: progress.Warning, Album: album, File: name,
+					Err: fmt.Errorf("could not resize the front cover of %s, removing it: %v", name, err)})
+			}
+			return err
+		},
+		DropApplication: config.FLACDropApplication,
+		DropCueSheet:    config.FLACDropCueSheet,
+		DropSeekTable:   config.FLACDropSeekTable,
+		Padding:         config.FLACPadding,
+	}
+	if downsampling(config) {
+		opts.Transform = func(file *flac.File) error {
+			err := downsampleFLAC(ctx, file, config)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				progress.Report(ctx, progress.Event{Kind: progress.Warning, Album: album, File: name,
+					Err: fmt.Errorf("could not downsample %s, copying it unchanged: %v", name, err)})
+			}
+			return nil
+		}
+	}
+	return opts
+}
+
+// resizePicture shrinks the picture to the height and encodes it as JPEG with quality 85,
+// pictures no higher than that are kept as they are
+func resizePicture(picture *audio.Picture, height int) error {
+	img, _, err := image.Decode(bytes.NewReader(picture.Data))
+	if err != nil {
+		return fmt.Errorf("error decoding image: %s", err)
+	}
+	if height <= 0 || img.Bounds().Dy() <= height {
+		return nil
+	}
+
+	resized := resizeToHeight(img, height)
+	var buf bytes.Buffer
+	opts := jpeg.Options{Quality: 85}
+	if err := jpeg.Encode(&buf, resized, &opts); err != nil {
+		return fmt.Errorf("error encoding JPEG: %s", err)
+	}
+	picture.MIME = "image/jpeg"
+	picture.Width = uint32(resized.Bounds().Dx())
+	picture.Height = uint32(resized.Bounds().Dy())
+	picture.Depth = 24
+	picture.Colors = 0
+	picture.Data = buf.Bytes()
+	return nil
+}
+
+// estimateMetadataSize estimates the change of FLAC metadata blocks kept, dropped or added by config
+// in the stripped file. A front cover no higher than the cover height is kept as it is, and a cover
+// which can't be decoded is removed
+func estimateMetadataSize(file library.File, config *config.Config) int64 {
+	var size int64
+	if config.FLACFrontCover && file.FrontCover && file.FrontCoverHeight > 0 {
+		if config.CoverHeight <= 0 || file.FrontCoverHeight <= config.CoverHeight {
+			size += file.FrontCoverSize
+		} else {
+			size += EstimateCoverSize(config)
+		}
+	}
+	if config.FLACDropApplication {
+		size -= file.Application
+	}
+	if config.FLACDropCueSheet {
+		size -= file.CueSheet
+	}
+	if config.FLACDropSeekTable {
+		size -= file.SeekTable
+	}
+	if config.FLACPadding > 0 {
+		size += 4 + config.FLACPadding
+	}
+	return size
+}
@@ -0,0 +1,193 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/go-flac/go-flac"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/progress"
+)
+
+// pictureBlock encodes a PICTURE block of the type with a PNG image of the size
+func pictureBlock(t *testing.T, pictureType uint32, width, height int) *flac.MetaDataBlock {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	picture := &audio.Picture{Type: pictureType, MIME: "image/png", Width: uint32(width), Height: uint32(height), Depth: 32, Data: buf.Bytes()}
+	return &flac.MetaDataBlock{Type: flac.Picture, Data: picture.Marshal()}
+}
+
+// blockTypes returns types of metadata blocks of the FLAC file
+func blockTypes(t *testing.T, data []byte) []flac.BlockType {
+	t.Helper()
+	file, err := flac.ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("error parsing written file: %v", err)
+	}
+	var types []flac.BlockType
+	for _, block := range file.Meta {
+		types = append(types, block.Type)
+	}
+	return types
+}
+
+// strip strips the FLAC file data with the options of cfg
+func strip(t *testing.T, ctx context.Context, data []byte, cfg *config.Config) []byte {
+	t.Helper()
+	stripped, err := audio.FLAC{}.StripArt(data, stripOptions(ctx, "album", "01 - test.flac", cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stripped
+}
+
+func TestStripOptions(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_metadata_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := flac.ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vorbis *flac.MetaDataBlock
+	for _, block := range file.Meta {
+		if block.Type == flac.VorbisComment {
+			vorbis = block
+		}
+	}
+	file.Meta = []*flac.MetaDataBlock{
+		file.Meta[0],
+		{Type: flac.SeekTable, Data: make([]byte, 18)},
+		{Type: flac.Application, Data: []byte("test application data")},
+		{Type: flac.CueSheet, Data: make([]byte, 432)},
+		vorbis,
+		pictureBlock(t, 4, 300, 300),
+		pictureBlock(t, audio.PictureFrontCover, 600, 480),
+		pictureBlock(t, audio.PictureFrontCover, 100, 100),
+		{Type: flac.Padding, Data: make([]byte, 4096)},
+	}
+	data = file.Marshal()
+	path := filepath.Join(tmpDir, "01 - test.flac")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the default policy removes pictures and padding like other formats
+	stripped, err := audio.FLAC{}.StripArt(data, audio.StripOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strip(t, context.Background(), data, &config.Config{CoverHeight: 240})
+	if !bytes.Equal(got, stripped) {
+		t.Error("stripOptions() with the default policy differs from StripOptions{}")
+	}
+
+	cfg := &config.Config{
+		CoverHeight:         240,
+		FLACFrontCover:      true,
+		FLACDropApplication: true,
+		FLACDropCueSheet:    true,
+		FLACDropSeekTable:   true,
+		FLACPadding:         1024,
+	}
+	got = strip(t, context.Background(), data, cfg)
+	want := []flac.BlockType{flac.StreamInfo, flac.VorbisComment, flac.Picture, flac.Padding}
+	if types := blockTypes(t, got); !slices.Equal(types, want) {
+		t.Fatalf("StripArt() blocks = %v, want %v", types, want)
+	}
+	file, err = flac.ParseBytes(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Meta[3].Data) != 1024 {
+		t.Errorf("padding = %d bytes, want 1024", len(file.Meta[3].Data))
+	}
+
+	// only the first front cover is kept, resized to the cover height
+	picture, err := audio.ParsePicture(file.Meta[2].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if picture.Type != audio.PictureFrontCover || picture.MIME != "image/jpeg" || picture.Width != 300 || picture.Height != 240 {
+		t.Errorf("front cover = type %d %s %dx%d, want type 3 image/jpeg 300x240",
+			picture.Type, picture.MIME, picture.Width, picture.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(picture.Data))
+	if err != nil {
+		t.Fatalf("error decoding front cover: %v", err)
+	}
+	if img.Bounds().Dx() != 300 || img.Bounds().Dy() != 240 {
+		t.Errorf("front cover image = %v, want 300x240", img.Bounds())
+	}
+
+	// the audio is untouched
+	if !bytes.HasSuffix(got, file.Frames) || !bytes.HasSuffix(data, file.Frames) {
+		t.Error("StripArt() changed audio frames")
+	}
+
+	// size estimates include the resized cover and the added padding without the dropped blocks
+	indexed, err := library.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !indexed.FrontCover || indexed.FrontCoverHeight != 480 {
+		t.Errorf("Stat() front cover = %v with height %d, want height 480", indexed.FrontCover, indexed.FrontCoverHeight)
+	}
+	dropped := int64(4+18) + int64(4+len("test application data")) + int64(4+432)
+	if size, want := estimateMetadataSize(indexed, cfg), EstimateCoverSize(cfg)+4+1024-dropped; size != want {
+		t.Errorf("estimateMetadataSize() = %d, want %d", size, want)
+	}
+	// a cover no higher than the cover height is kept as it is
+	highCfg := *cfg
+	highCfg.CoverHeight = 480
+	if size, want := estimateMetadataSize(indexed, &highCfg), indexed.FrontCoverSize+4+1024-dropped; size != want {
+		t.Errorf("estimateMetadataSize() of a kept cover = %d, want %d", size, want)
+	}
+	kept := strip(t, context.Background(), data, &highCfg)
+	if size, want := int64(len(kept)), indexed.Size-indexed.Stripped+estimateMetadataSize(indexed, &highCfg); size != want {
+		t.Errorf("StripArt() with a kept cover wrote %d bytes, estimated %d", size, want)
+	}
+
+	// a front cover which can't be decoded is removed with a warning of the album
+	broken := &audio.Picture{Type: audio.PictureFrontCover, MIME: "image/jpeg", Data: []byte("not an image")}
+	file.Meta[2] = &flac.MetaDataBlock{Type: flac.Picture, Data: broken.Marshal()}
+	data = file.Marshal()
+	r := &recorder{}
+	got = strip(t, progress.WithReporter(context.Background(), r), data, cfg)
+	want = []flac.BlockType{flac.StreamInfo, flac.VorbisComment, flac.Padding}
+	if types := blockTypes(t, got); !slices.Equal(types, want) {
+		t.Errorf("StripArt() blocks = %v, want %v", types, want)
+	}
+	if len(r.events) != 1 || r.events[0].Kind != progress.Warning || r.events[0].Album != "album" {
+		t.Errorf("StripArt() events = %+v, want a warning of the album", r.events)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	indexed, err = library.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, want := estimateMetadataSize(indexed, cfg), int64(4+1024); size != want {
+		t.Errorf("estimateMetadataSize() of a broken cover = %d, want %d", size, want)
+	}
+}
@@ -0,0 +1,162 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/manifest"
+)
+
+// Plan describes what processing albums would do to the destination directory
+type Plan struct {
+	// Wipe lists paths in the destination directory that would be removed
+	Wipe   []string    `json:"wipe,omitempty"`
+	Albums []AlbumPlan `json:"albums"`
+	// Bytes is the estimated number of bytes to write
+	Bytes int64 `json:"bytes"`
+}
+
+// AlbumPlan describes what processing a single album would do
+type AlbumPlan struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Skip is set when the album already exists in the destination directory
+	Skip bool `json:"skip,omitempty"`
+	// Repair is set when an interrupted copy of the album is completed, only missing files are written
+	Repair bool       `json:"repair,omitempty"`
+	Files  []FilePlan `json:"files,omitempty"`
+	Cover  *FilePlan  `json:"cover,omitempty"`
+	Bytes  int64      `json:"bytes"`
+}
+
+// FilePlan describes a single file to write
+type FilePlan struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// PlanAlbums plans processing of the albums after removing wipe paths without touching the destination directory
+func PlanAlbums(albums []library.Album, wipe []string, config *config.Config) (*Plan, error) {
+	plan := &Plan{Wipe: wipe, Albums: make([]AlbumPlan, 0, len(albums))}
+	m := loadManifest(context.Background(), config.Destination)
+	for _, album := range albums {
+		albumPlan, err := planAlbum(album, wipe, config, m)
+		if err != nil {
+			return nil, err
+		}
+		plan.Albums = append(plan.Albums, albumPlan)
+		plan.Bytes += albumPlan.Bytes
+	}
+	return plan, nil
+}
+
+// planAlbum plans processing of a single album the same way ProcessAlbum does it
+func planAlbum(album library.Album, wipe []string, config *config.Config, m *manifest.Manifest) (AlbumPlan, error) {
+	destAlbumPath, err := DestinationPath(album.Path, config)
+	if err != nil {
+		return AlbumPlan{}, err
+	}
+
+	plan := AlbumPlan{
+		Source:      album.Path,
+		Destination: destAlbumPath,
+	}
+	var recorded *manifest.Album
+	interrupted := false
+	if _, err := os.Stat(plan.Destination); err == nil && !isWiped(plan.Destination, wipe) {
+		relPath, _ := filepath.Rel(config.Destination, destAlbumPath)
+		var ok bool
+		recorded, ok = m.Get(relPath)
+		interrupted = manifest.IsIncomplete(destAlbumPath)
+		if (!ok && !interrupted) || (ok && len(recorded.Check(destAlbumPath, false)) == 0) {
+			plan.Skip = true
+			return plan, nil
+		}
+		plan.Repair = true
+	}
+
+	for _, file := range album.Files {
+		if plan.Repair {
+			if _, ok := copiedFile(outputFileName(file.Name, file.IsFLAC(), config), destAlbumPath, recorded, interrupted); ok {
+				continue
+			}
+		}
+		filePlan := FilePlan{
+			Source:      filepath.Join(album.Path, file.Name),
+			Destination: filepath.Join(plan.Destination, outputFileName(file.Name, file.IsFLAC(), config)),
+			Bytes:       estimateFileSize(file, config),
+		}
+		plan.Files = append(plan.Files, filePlan)
+		plan.Bytes += filePlan.Bytes
+	}
+
+	if coverFile := findCoverFile(album.Path, config); coverFile != "" {
+		plan.Cover = &FilePlan{
+			Source:      coverFile,
+			Destination: filepath.Join(plan.Destination, config.OutputCoverName),
+			Bytes:       EstimateCoverSize(config),
+		}
+		plan.Bytes += plan.Cover.Bytes
+	}
+
+	return plan, nil
+}
+
+// isWiped checks if the path is removed together with one of the wipe paths
+func isWiped(path string, wipe []string) bool {
+	for _, wiped := range wipe {
+		if filepath.Clean(path) == filepath.Clean(wiped) || isSubPath(wiped, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the plan in a human-readable form
+func (p *Plan) Print(w io.Writer) {
+	for _, path := range p.Wipe {
+		fmt.Fprintf(w, "Would remove: %s\n", path)
+	}
+
+	var albums int
+	for _, album := range p.Albums {
+		if album.Skip {
+			fmt.Fprintf(w, "Would skip existing album: %s\n", album.Destination)
+			continue
+		}
+		albums++
+		action := "copy"
+		if album.Repair {
+			action = "repair"
+		}
+		fmt.Fprintf(w, "Would %s album: %s -> %s (%s)\n", action, album.Source, album.Destination, config.FormatSize(album.Bytes))
+		for _, file := range album.Files {
+			fmt.Fprintf(w, "  %s (%s)\n", filepath.Base(file.Destination), config.FormatSize(file.Bytes))
+		}
+		if album.Cover != nil {
+			fmt.Fprintf(w, "  %s from %s (%s)\n", filepath.Base(album.Cover.Destination),
+				filepath.Base(album.Cover.Source), config.FormatSize(album.Cover.Bytes))
+		}
+	}
+
+	fmt.Fprintf(w, "Would copy %d albums, %s in total\n", albums, config.FormatSize(p.Bytes))
+}
+
+// WriteFile writes the plan as JSON to the file
+func (p *Plan) WriteFile(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding plan: %s", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing plan: %s", err)
+	}
+	return nil
+}
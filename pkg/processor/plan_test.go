@@ -0,0 +1,120 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+)
+
+func TestPlanAlbums(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_plan_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	for _, dir := range []string{
+		filepath.Join(srcDir, "new"),
+		filepath.Join(srcDir, "existing"),
+		filepath.Join(destDir, "existing"),
+	} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "new", "cover.jpg"), []byte("test jpg data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Source:          srcDir,
+		Destination:     destDir,
+		CoverFilenames:  []string{"folder.jpg", "cover.jpg"},
+		OutputCoverName: "cover.jpg",
+		CoverHeight:     100,
+	}
+	albums := []library.Album{
+		{
+			Path: filepath.Join(srcDir, "new"),
+			Files: []library.File{
+				{Name: "01.flac", Size: 1000, Stripped: 200},
+				{Name: "02.flac", Size: 500},
+			},
+		},
+		{
+			Path:  filepath.Join(srcDir, "existing"),
+			Files: []library.File{{Name: "01.flac", Size: 1000}},
+		},
+	}
+
+	plan, err := PlanAlbums(albums, nil, cfg)
+	if err != nil {
+		t.Fatalf("PlanAlbums() error = %v", err)
+	}
+	if len(plan.Albums) != 2 {
+		t.Fatalf("PlanAlbums() got %d albums, want 2", len(plan.Albums))
+	}
+
+	newAlbum := plan.Albums[0]
+	if newAlbum.Skip || len(newAlbum.Files) != 2 {
+		t.Errorf("PlanAlbums() new album = %+v", newAlbum)
+	}
+	if newAlbum.Files[0].Destination != filepath.Join(destDir, "new", "01.flac") || newAlbum.Files[0].Bytes != 800 {
+		t.Errorf("PlanAlbums() file = %+v", newAlbum.Files[0])
+	}
+	if newAlbum.Cover == nil || newAlbum.Cover.Source != filepath.Join(srcDir, "new", "cover.jpg") || newAlbum.Cover.Bytes != 5000 {
+		t.Errorf("PlanAlbums() cover = %+v", newAlbum.Cover)
+	}
+	if !plan.Albums[1].Skip || plan.Albums[1].Bytes != 0 {
+		t.Errorf("PlanAlbums() existing album = %+v", plan.Albums[1])
+	}
+	if plan.Bytes != 800+500+5000 {
+		t.Errorf("PlanAlbums() bytes = %d, want %d", plan.Bytes, 800+500+5000)
+	}
+
+	// existing albums are copied again after wiping
+	plan, err = PlanAlbums(albums, []string{filepath.Join(destDir, "existing")}, cfg)
+	if err != nil {
+		t.Fatalf("PlanAlbums() error = %v", err)
+	}
+	if plan.Albums[1].Skip || plan.Albums[1].Bytes != 1000 {
+		t.Errorf("PlanAlbums() wiped album = %+v", plan.Albums[1])
+	}
+
+	var out bytes.Buffer
+	plan.Print(&out)
+	for _, want := range []string{"Would remove: " + filepath.Join(destDir, "existing"), "01.flac", "Would copy 2 albums"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Print() output doesn't contain %q:\n%s", want, out.String())
+		}
+	}
+
+	planFile := filepath.Join(tmpDir, "plan.json")
+	if err := plan.WriteFile(planFile); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	data, err := os.ReadFile(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Plan
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("plan file is not valid JSON: %v", err)
+	}
+	if loaded.Bytes != plan.Bytes || len(loaded.Albums) != 2 || len(loaded.Wipe) != 1 {
+		t.Errorf("plan file = %+v", loaded)
+	}
+
+	// albums outside of the source directory
+	if _, err := PlanAlbums([]library.Album{{Path: tmpDir}}, nil, cfg); err == nil {
+		t.Error("PlanAlbums() expected error for album outside of source directory")
+	}
+}
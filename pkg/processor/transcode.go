@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/manifest"
+	"github.com/nerten/albumpicker/pkg/transcode"
+)
+
+// transcoding checks if FLAC files are transcoded to a lossy format instead of being copied
+func transcoding(config *config.Config) bool {
+	return config.OutputFormat != "" && config.OutputFormat != transcode.FLAC
+}
+
+// newEncoder creates the configured encoder backend, it's nil when FLAC files are copied
+func newEncoder(config *config.Config) (transcode.Encoder, error) {
+	if !transcoding(config) {
+		return nil, nil
+	}
+	profile, err := transcode.NewProfile(config.OutputFormat, config.OutputBitrate)
+	if err != nil {
+		return nil, err
+	}
+	return transcode.New(config.Encoder, profile)
+}
+
+// outputFileName returns the name of an audio file processed in the destination directory, name is its path
+// relative to the album, only FLAC files are transcoded
+func outputFileName(name string, flacFile bool, config *config.Config) string {
+	if !transcoding(config) || !flacFile {
+		return name
+	}
+	profile, err := transcode.NewProfile(config.OutputFormat, config.OutputBitrate)
+	if err != nil {
+		return name
+	}
+	return profile.OutputName(name)
+}
+
+// estimateFileSize estimates the size of the processed audio file, transcoded files
+// of unknown duration are estimated as copies
+func estimateFileSize(file library.File, config *config.Config) int64 {
+	flacFile := file.IsFLAC()
+	if flacFile && transcoding(config) && file.Duration > 0 {
+		if profile, err := transcode.NewProfile(config.OutputFormat, config.OutputBitrate); err == nil {
+			return profile.EstimateSize(file.Duration)
+		}
+	}
+	size := file.Size - file.Stripped
+	if flacFile && downsampling(config) {
+		size = estimateDownsampledSize(size, file, config)
+	}
+	if flacFile {
+		size += estimateMetadataSize(file, config)
+	}
+	return size
+}
+
+// TranscodeFLACFile transcodes a single FLAC file with the encoder, Vorbis comments are carried over
+// and the file gets the extension of the output format. The file is encoded to a local temporary file
+// while reading, so the destination gets the same sequential writes as copied files
+func TranscodeFLACFile(ctx context.Context, flacFile, srcAlbumPath, destAlbumPath string, config *config.Config, enc transcode.Encoder, limits *Limits) (manifest.File, error) {
+	// get the relative path from album directory
+	relFilePath, err := filepath.Rel(srcAlbumPath, flacFile)
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error getting relative file path: %s", err)
+	}
+	destFilePath := filepath.Join(destAlbumPath, outputFileName(relFilePath, true, config))
+
+	meta, err := audio.FLAC{}.ReadMetadata(flacFile)
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error reading tags: %s", err)
+	}
+
+	encoded, err := os.CreateTemp("", "albumpicker-*"+filepath.Ext(destFilePath))
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error creating temporary file: %s", err)
+	}
+	encoded.Close()
+	defer os.Remove(encoded.Name())
+
+	err = limits.read(func() error {
+		return enc.Encode(ctx, flacFile, encoded.Name(), meta.Tags)
+	})
+	if ctx.Err() != nil {
+		return manifest.File{}, ctx.Err()
+	}
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("error transcoding FLAC file: %s", err)
+	}
+
+	var written manifest.File
+	err = limits.write(func() error {
+		var err error
+		written, err = copyFile(ctx, encoded.Name(), destFilePath)
+		return err
+	})
+	if ctx.Err() != nil {
+		return manifest.File{}, ctx.Err()
+	}
+	return written, err
+}
@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerten/albumpicker/internal/transcodetest"
+	"github.com/nerten/albumpicker/pkg/config"
+	"github.com/nerten/albumpicker/pkg/library"
+	"github.com/nerten/albumpicker/pkg/manifest"
+	"github.com/nerten/albumpicker/pkg/transcode"
+)
+
+func TestTranscodeAlbum(t *testing.T) {
+	var fake *transcodetest.Fake
+	transcode.Register("fake", func(profile transcode.Profile) (transcode.Encoder, error) {
+		fake = transcodetest.NewFake(profile)
+		return fake, nil
+	})
+
+	tmpDir, err := os.MkdirTemp("", "albumpicker_transcode_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	albumDir := filepath.Join(srcDir, "testalbum")
+	for _, dir := range []string{albumDir, destDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join("..", "..", "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(albumDir, "01 - test.flac"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Source:          srcDir,
+		Destination:     destDir,
+		OutputCoverName: "cover.jpg",
+		OutputFormat:    "opus",
+		OutputBitrate:   96,
+		Encoder:         "fake",
+		Verify:          true,
+	}
+
+	// the test file is a second long
+	album := library.Album{Path: albumDir, Files: []library.File{{Name: "01 - test.flac", Size: int64(len(data)), Duration: time.Second}}}
+	plan, err := PlanAlbums([]library.Album{album}, nil, cfg)
+	if err != nil {
+		t.Fatalf("PlanAlbums() error = %v", err)
+	}
+	if files := plan.Albums[0].Files; len(files) != 1 || filepath.Base(files[0].Destination) != "01 - test.opus" || files[0].Bytes != 12000 {
+		t.Errorf("PlanAlbums() files = %+v, want 01 - test.opus of 12000 bytes", files)
+	}
+
+	if err := ProcessAlbums(context.Background(), readAlbums(t, albumDir), cfg); err != nil {
+		t.Fatalf("ProcessAlbums() error = %v", err)
+	}
+
+	// the file gets the extension of the format and the tags of the source
+	destFile := filepath.Join(destDir, "testalbum", "01 - test.opus")
+	encoded, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("transcoded file is missing: %v", err)
+	}
+	for _, want := range []string{"fake opus 96k\n", "ALBUMARTIST=Test album artist\n"} {
+		if !strings.Contains(string(encoded), want) {
+			t.Errorf("transcoded file %q doesn't contain %q", encoded, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "testalbum", "01 - test.flac")); !os.IsNotExist(err) {
+		t.Error("FLAC file was copied along with the transcoded one")
+	}
+
+	// the manifest records the transcoded file, so the album is complete
+	m, err := manifest.Load(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, ok := m.Get("testalbum")
+	if !ok || manifest.IsIncomplete(filepath.Join(destDir, "testalbum")) || len(recorded.Files) != 1 || recorded.Files[0].Name != "01 - test.opus" {
+		t.Fatalf("manifest album = %+v, want a complete album with 01 - test.opus", recorded)
+	}
+
+	// transcoded files of an interrupted copy are kept on repair
+	if err := manifest.MarkIncomplete(filepath.Join(destDir, "testalbum")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProcessAlbums(context.Background(), readAlbums(t, albumDir), cfg); err != nil {
+		t.Fatalf("ProcessAlbums() repair error = %v", err)
+	}
+	if len(fake.Sources) != 0 {
+		t.Errorf("repair transcoded %v again", fake.Sources)
+	}
+}
+
+func TestTranscodeUnknownEncoder(t *testing.T) {
+	cfg := &config.Config{OutputFormat: "opus", Encoder: "nonexistent"}
+	if err := ProcessAlbums(context.Background(), nil, cfg); err == nil {
+		t.Error("ProcessAlbums() with unknown encoder returned no error")
+	}
+}
@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nerten/albumpicker/pkg/flacstream"
+	"github.com/nerten/albumpicker/pkg/progress"
+)
+
+// verifyAlbum verifies the written copies of the FLAC files and removes corrupted ones,
+// so they are copied again when the album is repaired
+func verifyAlbum(ctx context.Context, flacFiles []string, srcAlbumPath, destAlbumPath string) error {
+	var corrupted []string
+	for _, flacFile := range flacFiles {
+		relFilePath, err := filepath.Rel(srcAlbumPath, flacFile)
+		if err != nil {
+			return fmt.Errorf("error getting relative file path: %s", err)
+		}
+		destFilePath := filepath.Join(destAlbumPath, relFilePath)
+
+		err = flacstream.Verify(destFilePath)
+		if errors.Is(err, flacstream.ErrNoChecksum) {
+			progress.Report(ctx, progress.Event{Kind: progress.Warning, File: relFilePath, Err: fmt.Errorf("%s can't be verified: %v", relFilePath, err)})
+			continue
+		}
+		if err != nil {
+			progress.Report(ctx, progress.Event{Kind: progress.Warning, File: relFilePath, Err: fmt.Errorf("%s is corrupted: %v", relFilePath, err)})
+			corrupted = append(corrupted, relFilePath)
+			if err := os.Remove(destFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				progress.Report(ctx, progress.Event{Kind: progress.Warning, File: relFilePath, Err: fmt.Errorf("could not remove corrupted file %s: %v", destFilePath, err)})
+			}
+		}
+	}
+
+	if len(corrupted) > 0 {
+		return fmt.Errorf("corrupted copies of %s, the album is repaired by the next run", strings.Join(corrupted, ", "))
+	}
+	progress.Report(ctx, progress.Event{Kind: progress.Info, Message: fmt.Sprintf("Verified %d FLAC files", len(flacFiles))})
+	return nil
+}
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyAlbum(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_verify_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	for _, dir := range []string{srcDir, destDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "test_data", "01 - test.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)-5] ^= 0x40
+
+	var flacFiles []string
+	for i, content := range [][]byte{data, corrupted} {
+		name := fmt.Sprintf("%02d.flac", i+1)
+		flacFiles = append(flacFiles, filepath.Join(srcDir, name))
+		if err := os.WriteFile(filepath.Join(srcDir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(destDir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := verifyAlbum(context.Background(), flacFiles, srcDir, destDir); err == nil {
+		t.Fatal("verifyAlbum() expected error for corrupted copy")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "01.flac")); err != nil {
+		t.Errorf("valid copy was removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "02.flac")); !os.IsNotExist(err) {
+		t.Errorf("corrupted copy was not removed")
+	}
+
+	if err := verifyAlbum(context.Background(), flacFiles[:1], srcDir, destDir); err != nil {
+		t.Errorf("verifyAlbum() error = %v", err)
+	}
+}
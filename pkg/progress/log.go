@@ -0,0 +1,66 @@
+package progress
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/nerten/albumpicker/pkg/config"
+)
+
+// Log writes events as records of a logger, it suits pipes, log files and embedding
+type Log struct {
+	logger *slog.Logger
+}
+
+// NewLog creates a reporter writing events to the logger, albums are logged at info level
+// and single files at debug level
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+// Report logs the event
+func (l *Log) Report(e Event) {
+	switch e.Kind {
+	case Start:
+		l.logger.Debug(fmt.Sprintf("Processing %d albums, %s estimated", e.Albums, config.FormatSize(e.Bytes)),
+			"albums", e.Albums, "bytes", e.Bytes)
+	case AlbumStarted:
+		msg := "Processing album: " + e.Album
+		if e.Repair {
+			msg = "Repairing incomplete album: " + e.Album
+		}
+		l.logger.Info(msg, "album", e.Album, "files", e.Files, "repair", e.Repair)
+		l.logger.Debug(fmt.Sprintf("Found %d audio files in album", e.Files), "album", e.Album)
+	case AlbumSkipped:
+		l.logger.Info(fmt.Sprintf("Skipping %s: %s", e.Message, e.Album),
+			"album", e.Album, "reason", e.Message, "bytes", e.Bytes)
+	case FileWritten:
+		l.logger.Debug(fmt.Sprintf("Written audio file: %s (%s)", e.File, config.FormatSize(e.Bytes)),
+			"album", e.Album, "file", e.File, "bytes", e.Bytes)
+	case CoverWritten:
+		l.logger.Debug(fmt.Sprintf("Written cover: %s (%s)", e.File, config.FormatSize(e.Bytes)),
+			"album", e.Album, "file", e.File, "bytes", e.Bytes)
+	case AlbumDone:
+		l.logger.Debug("Album done: "+e.Album, "album", e.Album)
+	case Info:
+		l.logger.Info(e.Message, eventAttrs(e)...)
+	case Warning:
+		l.logger.Warn(e.Err.Error(), eventAttrs(e)...)
+	case Error:
+		l.logger.Error(fmt.Sprintf("error processing album %s: %v", e.Album, e.Err), eventAttrs(e)...)
+	case Finish:
+		l.logger.Debug("Processing finished")
+	}
+}
+
+// eventAttrs returns the album and file of the event as log attributes
+func eventAttrs(e Event) []any {
+	var attrs []any
+	if e.Album != "" {
+		attrs = append(attrs, "album", e.Album)
+	}
+	if e.File != "" {
+		attrs = append(attrs, "file", e.File)
+	}
+	return attrs
+}
@@ -0,0 +1,93 @@
+package progress
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// Kind is the type of a progress event
+type Kind int
+
+const (
+	// Start is reported once before processing, Albums and Bytes are the planned totals
+	Start Kind = iota
+	// AlbumStarted is reported when copying of an album begins, Files is the number of files
+	// to write and Repair is set when an interrupted copy is completed
+	AlbumStarted
+	// AlbumSkipped is reported for an album which is not copied, Message is the reason
+	// and Bytes is its estimated size
+	AlbumSkipped
+	// FileWritten is reported for every written audio file, Bytes is its size
+	FileWritten
+	// CoverWritten is reported for the written cover, Bytes is its size
+	CoverWritten
+	// AlbumDone is reported when all files of an album are written
+	AlbumDone
+	// Info is a message which is neither progress nor a problem
+	Info
+	// Warning is a problem which doesn't stop the album from being copied
+	Warning
+	// Error is reported for an album which failed to copy, Bytes is its estimated size
+	Error
+	// AlbumNotProcessed is reported for an album never started because processing was interrupted,
+	// Bytes is its estimated size
+	AlbumNotProcessed
+	// Finish is reported once after processing
+	Finish
+)
+
+// Event is a single progress event, only fields documented for its kind are set
+type Event struct {
+	Kind Kind
+	// Album is the path of the album relative to the destination directory
+	Album   string
+	File    string
+	Albums  int
+	Files   int
+	Bytes   int64
+	Repair  bool
+	Message string
+	Err     error
+}
+
+// Reporter receives progress events, implementations must be safe for concurrent use
+type Reporter interface {
+	Report(e Event)
+}
+
+type contextKey struct{}
+
+// WithReporter returns a copy of ctx which carries the reporter
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the reporter carried by ctx, or the one writing events to the default logger
+func FromContext(ctx context.Context) Reporter {
+	if r, ok := ctx.Value(contextKey{}).(Reporter); ok {
+		return r
+	}
+	return NewLog(slog.Default())
+}
+
+// Report sends the event to the reporter carried by ctx
+func Report(ctx context.Context, e Event) {
+	FromContext(ctx).Report(e)
+}
+
+// Multi sends every event to all reporters
+type Multi []Reporter
+
+// Report sends the event to all reporters
+func (m Multi) Report(e Event) {
+	for _, r := range m {
+		r.Report(e)
+	}
+}
+
+// IsTerminal checks if the file is a character device
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,149 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	var out bytes.Buffer
+	r := NewLog(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	events := []Event{
+		{Kind: Start, Albums: 2, Bytes: 3000},
+		{Kind: AlbumStarted, Album: "artist/album1", Files: 2},
+		{Kind: FileWritten, Album: "artist/album1", File: "01.flac", Bytes: 1000},
+		{Kind: CoverWritten, Album: "artist/album1", File: "cover.jpg", Bytes: 100},
+		{Kind: AlbumDone, Album: "artist/album1"},
+		{Kind: AlbumStarted, Album: "artist/album2", Files: 1, Repair: true},
+		{Kind: AlbumSkipped, Album: "artist/album3", Message: "existing album"},
+		{Kind: Warning, Album: "artist/album2", Err: errors.New("cover is broken")},
+		{Kind: Error, Album: "artist/album2", Err: errors.New("no space left")},
+		{Kind: Finish},
+	}
+	for _, e := range events {
+		r.Report(e)
+	}
+
+	for _, want := range []string{
+		`level=INFO msg="Processing album: artist/album1" album=artist/album1 files=2 repair=false`,
+		`level=INFO msg="Repairing incomplete album: artist/album2" album=artist/album2 files=1 repair=true`,
+		`level=INFO msg="Skipping existing album: artist/album3" album=artist/album3 reason="existing album"`,
+		`level=WARN msg="cover is broken" album=artist/album2`,
+		`level=ERROR msg="error processing album artist/album2: no space left" album=artist/album2`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output %q doesn't contain %q", out.String(), want)
+		}
+	}
+	// single files are logged only at debug level
+	for _, unwanted := range []string{"Written audio file", "Written cover", "Found 2 audio files"} {
+		if strings.Contains(out.String(), unwanted) {
+			t.Errorf("output %q contains debug message %q", out.String(), unwanted)
+		}
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	var out bytes.Buffer
+	r := NewTerminal(&out)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	r.Report(Event{Kind: Start, Albums: 5, Bytes: 50_000_000})
+	r.Report(Event{Kind: AlbumSkipped, Album: "artist/album0", Message: "existing album", Bytes: 10_000_000})
+	r.Report(Event{Kind: AlbumStarted, Album: "artist/album1", Files: 1})
+	now = now.Add(10 * time.Second)
+	r.Report(Event{Kind: FileWritten, Album: "artist/album1", File: "01.flac", Bytes: 10_000_000})
+	r.Report(Event{Kind: AlbumDone, Album: "artist/album1"})
+	// a failed album only counts with what was written before the error
+	r.Report(Event{Kind: AlbumStarted, Album: "artist/album2", Files: 2})
+	r.Report(Event{Kind: FileWritten, Album: "artist/album2", File: "01.flac", Bytes: 2_000_000})
+	r.Report(Event{Kind: Error, Album: "artist/album2", Err: errors.New("disk full"), Bytes: 10_000_000})
+	if _, ok := r.albumBytes["artist/album2"]; ok {
+		t.Error("bytes of the failed album are kept")
+	}
+	// an album not processed after an interruption is dropped from the totals
+	r.Report(Event{Kind: AlbumNotProcessed, Album: "artist/album4", Bytes: 10_000_000})
+
+	// the last status line shows albums, bytes, throughput and ETA of the rest
+	lines := strings.Split(out.String(), clearLine)
+	status := lines[len(lines)-1]
+	for _, want := range []string{"[3/4]", "12.0 MB of 22.0 MB", "1.2 MB/s", "ETA 8s"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status line %q doesn't contain %q", status, want)
+		}
+	}
+	for _, want := range []string{"Skipped artist/album0: existing album\n", "Copied artist/album1 (10.0 MB)\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output %q doesn't contain %q", out.String(), want)
+		}
+	}
+
+	r.Report(Event{Kind: Finish})
+	if !strings.HasSuffix(out.String(), clearLine+"Written 12.0 MB in 10s, 1.2 MB/s\n") {
+		t.Errorf("output %q doesn't end with the summary", out.String())
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()).(*Log); !ok {
+		t.Error("FromContext() without reporter doesn't return the default log reporter")
+	}
+
+	r := NewTerminal(&bytes.Buffer{})
+	if FromContext(WithReporter(context.Background(), r)) != r {
+		t.Error("FromContext() doesn't return the reporter of the context")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	s := NewSummary()
+	events := []Event{
+		{Kind: Start, Albums: 5, Bytes: 5000},
+		{Kind: Warning, Err: errors.New("could not remove temporary files")},
+		{Kind: AlbumStarted, Album: "artist/album1", Files: 2},
+		{Kind: FileWritten, Album: "artist/album1", File: "01.flac", Bytes: 1000},
+		{Kind: FileWritten, Album: "artist/album1", File: "02.flac", Bytes: 1000},
+		{Kind: CoverWritten, Album: "artist/album1", File: "cover.jpg", Bytes: 100},
+		{Kind: Warning, Album: "artist/album1", Err: errors.New("cover is broken")},
+		{Kind: AlbumDone, Album: "artist/album1"},
+		{Kind: AlbumSkipped, Album: "artist/album2", Message: "existing album", Bytes: 1000},
+		{Kind: Error, Album: "artist/album3", Err: errors.New("no audio files found")},
+		{Kind: AlbumStarted, Album: "artist/album4", Files: 1, Repair: true},
+		{Kind: AlbumNotProcessed, Album: "artist/album5", Bytes: 1000},
+		{Kind: Finish},
+	}
+	for _, e := range events {
+		s.Report(e)
+	}
+
+	want := []AlbumResult{
+		{Album: "artist/album1", Status: StatusCopied, Files: 2, Bytes: 2100, Warnings: []string{"cover is broken"}},
+		{Album: "artist/album2", Status: StatusSkipped, Reason: "existing album"},
+		{Album: "artist/album3", Status: StatusFailed, Error: "no audio files found"},
+		{Album: "artist/album4", Status: StatusInterrupted, Repair: true},
+		{Album: "artist/album5", Status: StatusNotProcessed},
+	}
+	albums := s.Albums()
+	if len(albums) != len(want) {
+		t.Fatalf("Albums() = %+v, want %+v", albums, want)
+	}
+	for i := range want {
+		if fmt.Sprint(albums[i]) != fmt.Sprint(want[i]) {
+			t.Errorf("Albums()[%d] = %+v, want %+v", i, albums[i], want[i])
+		}
+	}
+	if estimated, written := s.Bytes(); estimated != 5000 || written != 2100 {
+		t.Errorf("Bytes() = %d, %d, want 5000, 2100", estimated, written)
+	}
+	if warnings := s.Warnings(); len(warnings) != 1 || warnings[0] != "could not remove temporary files" {
+		t.Errorf("Warnings() = %v", warnings)
+	}
+}
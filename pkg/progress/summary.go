@@ -0,0 +1,123 @@
+package progress
+
+import "sync"
+
+// Album statuses of results
+const (
+	StatusCopied       = "copied"
+	StatusSkipped      = "skipped"
+	StatusFailed       = "failed"
+	StatusInterrupted  = "interrupted"
+	StatusNotProcessed = "not_processed"
+)
+
+// AlbumResult is the outcome of processing a single album
+type AlbumResult struct {
+	// Album is the path of the album relative to the destination directory
+	Album string `json:"album"`
+	// Status is copied, skipped, failed, interrupted or not_processed
+	Status string `json:"status"`
+	// Reason is set for skipped albums
+	Reason string `json:"reason,omitempty"`
+	// Repair is set when an interrupted copy was completed
+	Repair bool `json:"repair,omitempty"`
+	// Files is the number of written audio files and Bytes the size of all written files
+	Files    int      `json:"files"`
+	Bytes    int64    `json:"bytes"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Summary collects results of albums and byte totals from events
+type Summary struct {
+	mu        sync.Mutex
+	albums    []*AlbumResult
+	byAlbum   map[string]*AlbumResult
+	warnings  []string
+	estimated int64
+	written   int64
+}
+
+// NewSummary creates an empty summary
+func NewSummary() *Summary {
+	return &Summary{byAlbum: make(map[string]*AlbumResult)}
+}
+
+// Report records the event
+func (s *Summary) Report(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch e.Kind {
+	case Start:
+		s.estimated = e.Bytes
+	case AlbumStarted:
+		// albums which never finish were interrupted
+		r := s.album(e.Album)
+		r.Status = StatusInterrupted
+		r.Repair = e.Repair
+	case AlbumSkipped:
+		r := s.album(e.Album)
+		r.Status = StatusSkipped
+		r.Reason = e.Message
+	case FileWritten, CoverWritten:
+		r := s.album(e.Album)
+		if e.Kind == FileWritten {
+			r.Files++
+		}
+		r.Bytes += e.Bytes
+		s.written += e.Bytes
+	case AlbumDone:
+		s.album(e.Album).Status = StatusCopied
+	case Warning:
+		if e.Album == "" {
+			s.warnings = append(s.warnings, e.Err.Error())
+			break
+		}
+		r := s.album(e.Album)
+		r.Warnings = append(r.Warnings, e.Err.Error())
+	case Error:
+		r := s.album(e.Album)
+		r.Status = StatusFailed
+		r.Error = e.Err.Error()
+	case AlbumNotProcessed:
+		s.album(e.Album).Status = StatusNotProcessed
+	}
+}
+
+// album returns the result of the album, creating it on the first event
+func (s *Summary) album(album string) *AlbumResult {
+	r, ok := s.byAlbum[album]
+	if !ok {
+		r = &AlbumResult{Album: album}
+		s.byAlbum[album] = r
+		s.albums = append(s.albums, r)
+	}
+	return r
+}
+
+// Albums returns results of albums in the order of their first events
+func (s *Summary) Albums() []AlbumResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	albums := make([]AlbumResult, len(s.albums))
+	for i, r := range s.albums {
+		albums[i] = *r
+	}
+	return albums
+}
+
+// Warnings returns warnings not related to a single album
+func (s *Summary) Warnings() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.warnings...)
+}
+
+// Bytes returns the estimated number of bytes to write and the number of bytes written
+func (s *Summary) Bytes() (estimated, written int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.estimated, s.written
+}
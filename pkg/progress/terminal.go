@@ -0,0 +1,133 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nerten/albumpicker/pkg/config"
+)
+
+// clearLine moves the cursor to the beginning of the line and erases it
+const clearLine = "\r\033[K"
+
+// Terminal renders a status line with overall bytes written, throughput and ETA,
+// per-album results are printed above it
+type Terminal struct {
+	w   io.Writer
+	now func() time.Time
+	mu  sync.Mutex
+
+	started     time.Time
+	albums      int
+	albumsDone  int
+	total       int64
+	written     int64
+	current     string
+	albumBytes  map[string]int64
+	statusShown bool
+}
+
+// NewTerminal creates a terminal renderer writing to w
+func NewTerminal(w io.Writer) *Terminal {
+	return &Terminal{w: w, now: time.Now, albumBytes: make(map[string]int64)}
+}
+
+// Report updates the status line and prints per-album results
+func (t *Terminal) Report(e Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch e.Kind {
+	case Start:
+		t.started = t.now()
+		t.albums = e.Albums
+		t.total = e.Bytes
+	case AlbumStarted:
+		t.current = e.Album
+	case AlbumSkipped:
+		t.albumsDone++
+		t.total -= e.Bytes
+		t.println("Skipped %s: %s", e.Album, e.Message)
+	case FileWritten, CoverWritten:
+		t.written += e.Bytes
+		t.albumBytes[e.Album] += e.Bytes
+	case AlbumDone:
+		t.albumsDone++
+		t.println("Copied %s (%s)", e.Album, config.FormatSize(t.albumBytes[e.Album]))
+		delete(t.albumBytes, e.Album)
+		if t.current == e.Album {
+			t.current = ""
+		}
+	case Info:
+		t.println("%s", e.Message)
+	case Warning:
+		t.println("Warning: %v", e.Err)
+	case Error:
+		// the rest of the album is never written
+		t.albumsDone++
+		t.total -= e.Bytes - t.albumBytes[e.Album]
+		delete(t.albumBytes, e.Album)
+		t.println("Error: error processing album %s: %v", e.Album, e.Err)
+		if t.current == e.Album {
+			t.current = ""
+		}
+	case AlbumNotProcessed:
+		// the album is never started, so it's dropped from the totals
+		t.albums--
+		t.total -= e.Bytes
+	case Finish:
+		t.clear()
+		elapsed := t.now().Sub(t.started)
+		fmt.Fprintf(t.w, "Written %s in %s, %s/s\n", config.FormatSize(t.written),
+			elapsed.Round(time.Second), config.FormatSize(t.rate(elapsed)))
+		return
+	}
+
+	t.status()
+}
+
+// println prints a line above the status line
+func (t *Terminal) println(format string, args ...any) {
+	t.clear()
+	fmt.Fprintf(t.w, format+"\n", args...)
+}
+
+// clear erases the status line
+func (t *Terminal) clear() {
+	if t.statusShown {
+		fmt.Fprint(t.w, clearLine)
+		t.statusShown = false
+	}
+}
+
+// status redraws the status line
+func (t *Terminal) status() {
+	elapsed := t.now().Sub(t.started)
+	rate := t.rate(elapsed)
+
+	line := fmt.Sprintf("[%d/%d] %s", t.albumsDone, t.albums, config.FormatSize(t.written))
+	if t.total > 0 {
+		line += " of " + config.FormatSize(t.total)
+	}
+	line += fmt.Sprintf(", %s/s", config.FormatSize(rate))
+	if rate > 0 && t.total > t.written {
+		eta := time.Duration(float64(t.total-t.written) / float64(rate) * float64(time.Second))
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	if t.current != "" {
+		line += ", " + t.current
+	}
+
+	fmt.Fprint(t.w, clearLine+line)
+	t.statusShown = true
+}
+
+// rate returns the average number of bytes written per second
+func (t *Terminal) rate(elapsed time.Duration) int64 {
+	if elapsed < time.Second {
+		return 0
+	}
+	return int64(float64(t.written) / elapsed.Seconds())
+}
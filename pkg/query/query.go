@@ -0,0 +1,257 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Record provides values of fields a query is evaluated against
+type Record interface {
+	// Values returns all values of the field, nil if the field is unknown
+	Values(field string) []string
+}
+
+// Query is a parsed filter expression like
+//
+//	genre:rock AND year>=1990 AND NOT artist:"Various Artists"
+//
+// Comparisons have the form field operator value, where the operator is one of:
+//
+//	:   any value of the field contains the value
+//	=   any value of the field equals the value
+//	!=  no value of the field equals the value
+//	>, >=, <, <=  any value of the field is greater or less than the value,
+//	              numbers are compared numerically, other values alphabetically
+//
+// All comparisons are case-insensitive. Comparisons are combined with AND, OR, NOT
+// and parentheses, two comparisons without an operator between them mean AND.
+type Query struct {
+	source string
+	root   node
+}
+
+// Parse parses the filter expression
+func Parse(s string) (*Query, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos+1)
+	}
+
+	return &Query{source: s, root: root}, nil
+}
+
+// Match checks if the record matches the query
+func (q *Query) Match(r Record) bool {
+	return q.root.eval(r)
+}
+
+// String returns the source of the query
+func (q *Query) String() string {
+	return q.source
+}
+
+// node is a node of the expression tree
+type node interface {
+	eval(r Record) bool
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n andNode) eval(r Record) bool {
+	return n.left.eval(r) && n.right.eval(r)
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n orNode) eval(r Record) bool {
+	return n.left.eval(r) || n.right.eval(r)
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(r Record) bool {
+	return !n.operand.eval(r)
+}
+
+type compareNode struct {
+	field string
+	op    string
+	value string
+}
+
+func (n compareNode) eval(r Record) bool {
+	values := r.Values(n.field)
+	if n.op == "!=" {
+		for _, v := range values {
+			if strings.EqualFold(v, n.value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, v := range values {
+		if compareValue(v, n.op, n.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValue compares a single value of a field with the value of a comparison
+func compareValue(value, op, want string) bool {
+	switch op {
+	case ":":
+		return strings.Contains(strings.ToLower(value), strings.ToLower(want))
+	case "=":
+		return strings.EqualFold(value, want)
+	}
+
+	// compare numbers numerically, everything else alphabetically
+	var cmp int
+	a, errA := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	b, errB := strconv.ParseFloat(strings.TrimSpace(want), 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(strings.ToLower(value), strings.ToLower(want))
+	}
+
+	switch op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// parser is a recursive descent parser of queries
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// parseOr parses: and (OR and)*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses: not ([AND] not)*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenWord, tokenNot, tokenLeftParen:
+			// implicit AND
+		default:
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+}
+
+// parseNot parses: NOT not | primary
+func (p *parser) parseNot() (node, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses: ( or ) | field operator value
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenLeftParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRightParen {
+			return nil, fmt.Errorf("expected ')' at position %d, got %s", closing.pos+1, closing)
+		}
+		return expr, nil
+	case tokenWord:
+		op := p.next()
+		if op.kind != tokenOperator {
+			return nil, fmt.Errorf("expected operator after field '%s' at position %d, got %s", t.value, op.pos+1, op)
+		}
+		value := p.next()
+		switch value.kind {
+		case tokenWord, tokenString, tokenAnd, tokenOr, tokenNot:
+		default:
+			return nil, fmt.Errorf("expected value at position %d, got %s", value.pos+1, value)
+		}
+		return compareNode{field: strings.ToLower(t.value), op: op.value, value: value.value}, nil
+	default:
+		return nil, fmt.Errorf("expected field or '(' at position %d, got %s", t.pos+1, t)
+	}
+}
@@ -0,0 +1,131 @@
+package query
+
+import (
+	"testing"
+)
+
+// mapRecord is a record backed by a map
+type mapRecord map[string][]string
+
+func (m mapRecord) Values(field string) []string {
+	return m[field]
+}
+
+func TestMatch(t *testing.T) {
+	record := mapRecord{
+		"genre":  {"Progressive Rock", "Jazz"},
+		"artist": {"King Crimson"},
+		"year":   {"1974"},
+		"album":  {"Red"},
+		"path":   {"/music/King Crimson/1974 - Red"},
+	}
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"genre:rock", true},
+		{"genre:ROCK", true},
+		{"genre:blues", false},
+		{"genre=jazz", true},
+		{"genre=rock", false},
+		{"genre!=jazz", false},
+		{"label!=columbia", true},
+		{"year>=1970", true},
+		{"year>1974", false},
+		{"year<=1974", true},
+		{"year<1970", false},
+		{"year>=1970 AND year<1980", true},
+		{"year>=1970 year<1980", true},
+		{"genre:blues OR artist:crimson", true},
+		{"genre:blues OR artist:yes", false},
+		{"NOT genre:blues", true},
+		{"not genre:jazz", false},
+		{"NOT NOT genre:jazz", true},
+		{`artist:"King Crimson"`, true},
+		{`artist="king crimson"`, true},
+		{`NOT artist:"Various Artists"`, true},
+		{"genre:rock AND year>=1990 AND NOT artist:\"Various Artists\"", false},
+		{"(genre:blues OR genre:jazz) AND year<1980", true},
+		{"genre:blues OR genre:jazz AND year>1980", false},
+		{"album>q", true},
+		{"album<q", false},
+		{"path:crimson", true},
+		{"unknown:anything", false},
+		{`album:"R\"ed"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.query, err)
+			}
+			if got := q.Match(record); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			if q.String() != tt.query {
+				t.Errorf("String() = %q, want %q", q.String(), tt.query)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		"genre",
+		"genre:",
+		"genre rock",
+		":rock",
+		"genre:rock AND",
+		"genre:rock OR OR year>1",
+		"(genre:rock",
+		"genre:rock)",
+		`artist:"unterminated`,
+		"genre!rock",
+		"genre:(rock)",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			if _, err := Parse(input); err == nil {
+				t.Errorf("Parse(%q) expected error", input)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, err := tokenize(`year>=1990 and NOT (artist:"Various \"Artists\"")`)
+	if err != nil {
+		t.Fatalf("tokenize() error = %v", err)
+	}
+
+	want := []struct {
+		kind  tokenKind
+		value string
+	}{
+		{tokenWord, "year"},
+		{tokenOperator, ">="},
+		{tokenWord, "1990"},
+		{tokenAnd, "and"},
+		{tokenNot, "NOT"},
+		{tokenLeftParen, "("},
+		{tokenWord, "artist"},
+		{tokenOperator, ":"},
+		{tokenString, `Various "Artists"`},
+		{tokenRightParen, ")"},
+		{tokenEOF, ""},
+	}
+
+	if len(tokens) != len(want) {
+		t.Fatalf("tokenize() got %d tokens, want %d: %v", len(tokens), len(want), tokens)
+	}
+	for i, w := range want {
+		if tokens[i].kind != w.kind || tokens[i].value != w.value {
+			t.Errorf("token[%d] = %v, want %v", i, tokens[i], w.value)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package selector
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nerten/albumpicker/pkg/library"
+)
+
+// Options limit the selection of albums
+type Options struct {
+	// Count is the maximum number of albums, 0 means no limit
+	Count int
+	// Budget is the maximum total size of albums, 0 means no limit
+	Budget int64
+	// Size estimates the size of an album, required when Budget is set
+	Size func(library.Album) int64
+	// Weight is the relative probability of an album to be selected,
+	// albums with zero weight are never selected, nil means equal weights
+	Weight func(library.Album) float64
+	// MaxPerArtist is the maximum number of albums of a single artist, 0 means no limit
+	MaxPerArtist int
+	// MaxPerGenre is the maximum number of albums of a single genre, 0 means no limit
+	MaxPerGenre int
+	// Rand is the source of randomness, the same source and albums give the same selection,
+	// nil means the global source
+	Rand *rand.Rand
+}
+
+// Result is the outcome of a selection
+type Result struct {
+	Albums []library.Album
+	Size   int64
+	// SkippedByArtist is the number of albums skipped because of MaxPerArtist
+	SkippedByArtist int
+	// SkippedByGenre is the number of albums skipped because of MaxPerGenre
+	SkippedByGenre int
+}
+
+// Select randomly selects albums within the limits of the options
+func Select(albums []library.Album, opts Options) Result {
+	r := opts.Rand
+	if r == nil {
+		r = rand.New(globalSource{})
+	}
+	shuffled := shuffle(r, albums, opts.Weight)
+
+	var result Result
+	artists := make(map[string]int)
+	genres := make(map[string]int)
+	for _, album := range shuffled {
+		if opts.Count > 0 && len(result.Albums) >= opts.Count {
+			break
+		}
+
+		artist := AlbumArtist(album)
+		if opts.MaxPerArtist > 0 && artists[artist] >= opts.MaxPerArtist {
+			result.SkippedByArtist++
+			continue
+		}
+		albumGenres := albumGenres(album)
+		if opts.MaxPerGenre > 0 && slices.ContainsFunc(albumGenres, func(genre string) bool {
+			return genres[genre] >= opts.MaxPerGenre
+		}) {
+			result.SkippedByGenre++
+			continue
+		}
+
+		var size int64
+		if opts.Size != nil {
+			size = opts.Size(album)
+		}
+		if opts.Budget > 0 && result.Size+size > opts.Budget {
+			// the album doesn't fit, but a smaller one still might
+			continue
+		}
+
+		result.Albums = append(result.Albums, album)
+		result.Size += size
+		artists[artist]++
+		for _, genre := range albumGenres {
+			genres[genre]++
+		}
+	}
+
+	return result
+}
+
+// NewRand creates a deterministic source of randomness from the seed
+func NewRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed))
+}
+
+// globalSource is a rand.Source backed by the global random generator
+type globalSource struct{}
+
+func (globalSource) Uint64() uint64 {
+	return rand.Uint64()
+}
+
+// albumGenres returns distinct lower-cased genres of the album
+func albumGenres(album library.Album) []string {
+	var genres []string
+	for _, genre := range album.Values("genre") {
+		genre = strings.ToLower(genre)
+		if genre != "" && !slices.Contains(genres, genre) {
+			genres = append(genres, genre)
+		}
+	}
+	return genres
+}
+
+// shuffle returns albums in random order, albums with higher weights tend to go first
+func shuffle(r *rand.Rand, albums []library.Album, weight func(library.Album) float64) []library.Album {
+	if weight == nil {
+		shuffled := make([]library.Album, len(albums))
+		copy(shuffled, albums)
+		r.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		return shuffled
+	}
+
+	// weighted random sampling without replacement (Efraimidis and Spirakis):
+	// every album gets a key u^(1/w) for a uniform random u, albums are sorted by the key,
+	// logarithms of keys are used to avoid precision loss
+	type keyedAlbum struct {
+		album library.Album
+		key   float64
+	}
+	keyed := make([]keyedAlbum, 0, len(albums))
+	for _, album := range albums {
+		w := weight(album)
+		if w <= 0 || math.IsNaN(w) {
+			continue
+		}
+		u := 1 - r.Float64() // (0, 1]
+		keyed = append(keyed, keyedAlbum{album: album, key: math.Log(u) / w})
+	}
+	sort.SliceStable(keyed, func(i, j int) bool {
+		return keyed[i].key > keyed[j].key
+	})
+
+	shuffled := make([]library.Album, 0, len(keyed))
+	for _, k := range keyed {
+		shuffled = append(shuffled, k.album)
+	}
+	return shuffled
+}
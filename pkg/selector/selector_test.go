@@ -0,0 +1,227 @@
+package selector
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nerten/albumpicker/pkg/library"
+)
+
+// testAlbums creates albums with a single file of the given sizes
+func testAlbums(sizes ...int64) []library.Album {
+	albums := make([]library.Album, 0, len(sizes))
+	for i, size := range sizes {
+		albums = append(albums, library.Album{
+			Path:  string(rune('a' + i)),
+			Files: []library.File{{Name: "track.flac", Size: size}},
+		})
+	}
+	return albums
+}
+
+func TestSelect(t *testing.T) {
+	albumSize := func(album library.Album) int64 {
+		return album.Size()
+	}
+
+	tests := []struct {
+		name     string
+		albums   []library.Album
+		opts     Options
+		wantLen  int
+		wantSize int64
+	}{
+		{
+			name:    "select less than available",
+			albums:  testAlbums(1, 1, 1, 1),
+			opts:    Options{Count: 2},
+			wantLen: 2,
+		},
+		{
+			name:    "select more than available",
+			albums:  testAlbums(1, 1),
+			opts:    Options{Count: 3},
+			wantLen: 2,
+		},
+		{
+			name:     "fill budget exactly",
+			albums:   testAlbums(10, 10, 10, 10),
+			opts:     Options{Budget: 30, Size: albumSize},
+			wantLen:  3,
+			wantSize: 30,
+		},
+		{
+			name:     "skip albums larger than budget",
+			albums:   testAlbums(100, 100, 5),
+			opts:     Options{Budget: 10, Size: albumSize},
+			wantLen:  1,
+			wantSize: 5,
+		},
+		{
+			name:     "nothing fits",
+			albums:   testAlbums(100, 100),
+			opts:     Options{Budget: 10, Size: albumSize},
+			wantLen:  0,
+			wantSize: 0,
+		},
+		{
+			name:     "count and budget",
+			albums:   testAlbums(1, 1, 1, 1),
+			opts:     Options{Count: 2, Budget: 10, Size: albumSize},
+			wantLen:  2,
+			wantSize: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Select(tt.albums, tt.opts)
+			if len(got.Albums) != tt.wantLen {
+				t.Errorf("Select() got %d albums, want %d", len(got.Albums), tt.wantLen)
+			}
+			if tt.opts.Size != nil && got.Size != tt.wantSize {
+				t.Errorf("Select() got size %d, want %d", got.Size, tt.wantSize)
+			}
+
+			// every album must be selected only once
+			seen := make(map[string]bool)
+			for _, album := range got.Albums {
+				if seen[album.Path] {
+					t.Errorf("Select() selected %s twice", album.Path)
+				}
+				seen[album.Path] = true
+			}
+		})
+	}
+}
+
+func TestSelectWeighted(t *testing.T) {
+	albums := testAlbums(1, 1, 1)
+	weights := map[string]float64{"a": 100, "b": 1, "c": 0}
+	opts := Options{
+		Count: 1,
+		Weight: func(album library.Album) float64 {
+			return weights[album.Path]
+		},
+	}
+
+	counts := make(map[string]int)
+	for range 1000 {
+		got := Select(albums, opts)
+		if len(got.Albums) != 1 {
+			t.Fatalf("Select() got %d albums, want 1", len(got.Albums))
+		}
+		counts[got.Albums[0].Path]++
+	}
+
+	if counts["c"] > 0 {
+		t.Errorf("Select() selected album with zero weight %d times", counts["c"])
+	}
+	// "a" is expected to be selected about 990 times out of 1000
+	if counts["a"] < 900 {
+		t.Errorf("Select() selected album with high weight only %d times out of 1000", counts["a"])
+	}
+
+	// zero weights are never selected even if the count allows
+	opts.Count = 3
+	if got := Select(albums, opts); len(got.Albums) != 2 {
+		t.Errorf("Select() got %d albums, want 2", len(got.Albums))
+	}
+}
+
+func TestSelectDiversity(t *testing.T) {
+	album := func(path, artist string, genres ...string) library.Album {
+		return library.Album{Path: path, Tags: map[string][]string{"ALBUMARTIST": {artist}, "GENRE": genres}}
+	}
+	albums := []library.Album{
+		album("a1", "Artist A", "Rock"),
+		album("a2", "Artist A", "Rock"),
+		album("a3", "Artist A", "Jazz"),
+		album("b1", "Artist B", "Rock; Jazz"),
+		album("c1", "Artist C", "Jazz"),
+		album("d1", "Artist D", "Blues"),
+	}
+
+	tests := []struct {
+		name        string
+		opts        Options
+		wantLen     int
+		wantSkipped bool
+	}{
+		{"no limits", Options{Count: 6}, 6, false},
+		{"one per artist", Options{Count: 6, MaxPerArtist: 1}, 4, true},
+		{"two per artist", Options{Count: 6, MaxPerArtist: 2}, 5, true},
+		{"one per genre", Options{Count: 6, MaxPerGenre: 1}, 0, true},
+		{"count is reachable", Options{Count: 3, MaxPerArtist: 1}, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				got := Select(albums, tt.opts)
+
+				artists := make(map[string]int)
+				genres := make(map[string]int)
+				for _, a := range got.Albums {
+					artists[AlbumArtist(a)]++
+					for _, genre := range albumGenres(a) {
+						genres[genre]++
+					}
+				}
+				for artist, n := range artists {
+					if tt.opts.MaxPerArtist > 0 && n > tt.opts.MaxPerArtist {
+						t.Fatalf("Select() selected %d albums of %s", n, artist)
+					}
+				}
+				for genre, n := range genres {
+					if tt.opts.MaxPerGenre > 0 && n > tt.opts.MaxPerGenre {
+						t.Fatalf("Select() selected %d albums of %s", n, genre)
+					}
+				}
+
+				if tt.wantLen > 0 && len(got.Albums) != tt.wantLen {
+					t.Fatalf("Select() got %d albums, want %d", len(got.Albums), tt.wantLen)
+				}
+				skipped := got.SkippedByArtist+got.SkippedByGenre > 0
+				if tt.wantSkipped && !skipped {
+					t.Fatalf("Select() didn't report skipped albums")
+				}
+			}
+		})
+	}
+}
+
+func TestSelectSeed(t *testing.T) {
+	albums := testAlbums(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	paths := func(result Result) []string {
+		var p []string
+		for _, album := range result.Albums {
+			p = append(p, album.Path)
+		}
+		return p
+	}
+	weight := func(album library.Album) float64 {
+		return float64(album.Size())
+	}
+
+	for _, w := range []func(library.Album) float64{nil, weight} {
+		first := Select(albums, Options{Count: 5, Weight: w, Rand: NewRand(42)})
+		second := Select(albums, Options{Count: 5, Weight: w, Rand: NewRand(42)})
+		if !slices.Equal(paths(first), paths(second)) {
+			t.Errorf("Select() with the same seed got %v and %v", paths(first), paths(second))
+		}
+
+		// it's extremely unlikely that all of 10 different seeds give the same selection
+		different := false
+		for seed := range uint64(10) {
+			other := Select(albums, Options{Count: 5, Weight: w, Rand: NewRand(seed + 100)})
+			if !slices.Equal(paths(first), paths(other)) {
+				different = true
+				break
+			}
+		}
+		if !different {
+			t.Error("Select() with different seeds always got the same selection")
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package selector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nerten/albumpicker/pkg/library"
+)
+
+// Selection strategies
+const (
+	// StrategyUniform gives every album equal probability
+	StrategyUniform = "uniform"
+	// StrategyRating prefers albums with higher rating tags
+	StrategyRating = "rating"
+	// StrategyAge prefers albums that were not picked for a long time
+	StrategyAge = "age"
+	// StrategyArtist gives every artist equal probability, regardless of the number of their albums
+	StrategyArtist = "artist"
+	// StrategyFile takes weights from a weight file
+	StrategyFile = "file"
+)
+
+// Strategies lists all selection strategies
+var Strategies = []string{StrategyUniform, StrategyRating, StrategyAge, StrategyArtist, StrategyFile}
+
+// maxAge limits the weight of albums by age, so never picked albums
+// don't completely outweigh albums picked a year ago
+const maxAge = 365 * 24 * time.Hour
+
+// ratingScales are the RATING scales written by different players: 0..1, 0..5 stars, 0..10 and 0..100
+var ratingScales = []float64{1, 5, 10, 100}
+
+// RatingWeight weights albums by the average of their RATING or FMPS_RATING tags,
+// unrated albums get the weight of an average rating.
+// RATING tags are divided by scale, a zero scale is detected for every album from
+// the largest RATING of its files, so all files of an album share the same scale
+func RatingWeight(scale float64) func(library.Album) float64 {
+	return func(album library.Album) float64 {
+		var sum float64
+		var count int
+		for _, value := range album.Tag("FMPS_RATING") {
+			if r, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && r >= 0 && r <= 1 {
+				sum += r
+				count++
+			}
+		}
+		if count == 0 {
+			var ratings []float64
+			for _, value := range album.Tag("RATING") {
+				if r, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && r >= 0 {
+					ratings = append(ratings, r)
+				}
+			}
+			albumScale := scale
+			if albumScale <= 0 {
+				albumScale = detectRatingScale(ratings)
+			}
+			for _, r := range ratings {
+				if r <= albumScale {
+					sum += r / albumScale
+					count++
+				}
+			}
+		}
+
+		rating := 0.5
+		if count > 0 {
+			rating = sum / float64(count)
+		}
+
+		// from 1 for the worst albums to 5 for the best ones
+		return 1 + 4*rating
+	}
+}
+
+// detectRatingScale returns the smallest rating scale that fits the largest of ratings
+func detectRatingScale(ratings []float64) float64 {
+	var maxRating float64
+	for _, r := range ratings {
+		maxRating = max(maxRating, r)
+	}
+	for _, scale := range ratingScales {
+		if maxRating <= scale {
+			return scale
+		}
+	}
+	return 0
+}
+
+// AgeWeight weights albums by the time since they were picked last time
+func AgeWeight(lastPicked map[string]time.Time, now time.Time) func(library.Album) float64 {
+	return func(album library.Album) float64 {
+		age := maxAge
+		if picked, ok := lastPicked[album.Path]; ok && now.Sub(picked) < maxAge {
+			age = max(now.Sub(picked), 0)
+		}
+		// one day is added, so albums picked just now still have a chance
+		return (age + 24*time.Hour).Hours() / 24
+	}
+}
+
+// ArtistWeight weights albums inversely to the number of albums of their artist
+func ArtistWeight(albums []library.Album) func(library.Album) float64 {
+	counts := make(map[string]int)
+	for _, album := range albums {
+		counts[AlbumArtist(album)]++
+	}
+	return func(album library.Album) float64 {
+		return 1 / float64(max(counts[AlbumArtist(album)], 1))
+	}
+}
+
+// AlbumArtist returns the artist of the album, the name of the parent directory is used for untagged albums
+func AlbumArtist(album library.Album) string {
+	for _, name := range []string{"ALBUMARTIST", "ARTIST"} {
+		if values := album.Tag(name); len(values) > 0 {
+			return strings.ToLower(values[0])
+		}
+	}
+	return strings.ToLower(filepath.Base(filepath.Dir(album.Path)))
+}
+
+// LoadWeightFile reads album weights from a file, every line contains a weight and
+// an album path relative to the source directory or absolute:
+//
+//	# comments and empty lines are ignored
+//	5 Miles Davis/1959 - Kind of Blue
+//	0 Various Artists/2001 - Christmas Hits
+//
+// Albums missing in the file have weight 1.
+func LoadWeightFile(path, source string) (func(library.Album) float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening weight file: %s", err)
+	}
+	defer f.Close()
+
+	weights := make(map[string]float64)
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// the weight is separated by any run of spaces or tabs, spaces inside the path are kept
+		end := strings.IndexFunc(line, unicode.IsSpace)
+		if end < 0 {
+			return nil, fmt.Errorf("invalid line %d in weight file %s: %s", lineNumber, path, line)
+		}
+		weightValue, albumPath := line[:end], strings.TrimSpace(line[end:])
+		if albumPath == "" {
+			return nil, fmt.Errorf("invalid line %d in weight file %s: %s", lineNumber, path, line)
+		}
+		weight, err := strconv.ParseFloat(weightValue, 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight at line %d in weight file %s: %s", lineNumber, path, weightValue)
+		}
+
+		if !filepath.IsAbs(albumPath) {
+			albumPath = filepath.Join(source, albumPath)
+		}
+		weights[filepath.Clean(albumPath)] = weight
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading weight file: %s", err)
+	}
+
+	return func(album library.Album) float64 {
+		if weight, ok := weights[filepath.Clean(album.Path)]; ok {
+			return weight
+		}
+		return 1
+	}, nil
+}
@@ -0,0 +1,139 @@
+package selector
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nerten/albumpicker/pkg/library"
+)
+
+func TestRatingWeight(t *testing.T) {
+	tests := []struct {
+		name  string
+		scale float64
+		tags  map[string][]string
+		want  float64
+	}{
+		{"unrated", 0, nil, 3},
+		{"fmps rating", 0, map[string][]string{"FMPS_RATING": {"1.0"}}, 5},
+		{"fmps rating has priority", 0, map[string][]string{"FMPS_RATING": {"0"}, "RATING": {"5"}}, 1},
+		{"five stars", 0, map[string][]string{"RATING": {"5"}}, 5},
+		{"percents", 0, map[string][]string{"RATING": {"20", "30"}}, 2},
+		{"one scale for the album", 0, map[string][]string{"RATING": {"1", "100"}}, 3.02},
+		{"invalid rating", 0, map[string][]string{"RATING": {"great"}}, 3},
+		{"one of five stars", 5, map[string][]string{"RATING": {"1"}}, 1.8},
+		{"five of five stars", 5, map[string][]string{"RATING": {"5"}}, 5},
+		{"ten of five stars", 5, map[string][]string{"RATING": {"10"}}, 3},
+		{"one of 100 points", 100, map[string][]string{"RATING": {"1"}}, 1.04},
+		{"five of 100 points", 100, map[string][]string{"RATING": {"5"}}, 1.2},
+		{"ten of 100 points", 100, map[string][]string{"RATING": {"10"}}, 1.4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RatingWeight(tt.scale)(library.Album{Tags: tt.tags})
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("RatingWeight() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgeWeight(t *testing.T) {
+	now := time.Now()
+	weight := AgeWeight(map[string]time.Time{
+		"today":    now,
+		"week":     now.Add(-7 * 24 * time.Hour),
+		"long ago": now.Add(-1000 * 24 * time.Hour),
+	}, now)
+
+	tests := []struct {
+		path string
+		want float64
+	}{
+		{"today", 1},
+		{"week", 8},
+		{"long ago", 366},
+		{"never", 366},
+	}
+
+	for _, tt := range tests {
+		if got := weight(library.Album{Path: tt.path}); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("AgeWeight(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestArtistWeight(t *testing.T) {
+	albums := []library.Album{
+		{Path: filepath.Join("music", "Prolific", "album1"), Tags: map[string][]string{"ALBUMARTIST": {"Prolific"}}},
+		{Path: filepath.Join("music", "Prolific", "album2"), Tags: map[string][]string{"ALBUMARTIST": {"prolific"}}},
+		{Path: filepath.Join("music", "Prolific", "album3")},
+		{Path: filepath.Join("music", "Rare", "album1")},
+	}
+
+	weight := ArtistWeight(albums)
+	for i, want := range []float64{1.0 / 3, 1.0 / 3, 1.0 / 3, 1} {
+		if got := weight(albums[i]); math.Abs(got-want) > 1e-9 {
+			t.Errorf("ArtistWeight(%s) = %v, want %v", albums[i].Path, got, want)
+		}
+	}
+}
+
+func TestLoadWeightFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "albumpicker_weight_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	source := filepath.Join(tmpDir, "music")
+	weightFile := filepath.Join(tmpDir, "weights.txt")
+	content := "# favourite albums\n\n5 Artist/Album One\n0 " + filepath.Join(source, "Artist", "Christmas") + "\n" +
+		"2\tArtist/Tab  Separated\n3   Artist/Aligned\n"
+	if err := os.WriteFile(weightFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	weight, err := LoadWeightFile(weightFile, source)
+	if err != nil {
+		t.Fatalf("LoadWeightFile() error = %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want float64
+	}{
+		{filepath.Join(source, "Artist", "Album One"), 5},
+		{filepath.Join(source, "Artist", "Christmas"), 0},
+		{filepath.Join(source, "Artist", "Tab  Separated"), 2},
+		{filepath.Join(source, "Artist", "Aligned"), 3},
+		{filepath.Join(source, "Artist", "Other"), 1},
+	}
+	for _, tt := range tests {
+		if got := weight(library.Album{Path: tt.path}); got != tt.want {
+			t.Errorf("weight(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// invalid files
+	for name, content := range map[string]string{
+		"missing-path.txt":   "5\n",
+		"invalid-weight.txt": "five Artist/Album\n",
+		"negative.txt":       "-1 Artist/Album\n",
+	} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadWeightFile(path, source); err == nil {
+			t.Errorf("LoadWeightFile(%s) expected error", name)
+		}
+	}
+	if _, err := LoadWeightFile(filepath.Join(tmpDir, "missing.txt"), source); err == nil {
+		t.Error("LoadWeightFile() expected error for missing file")
+	}
+}
@@ -0,0 +1,109 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+)
+
+// ffmpegFormats are the ffmpeg codec and muxer of every format
+var ffmpegFormats = map[string]struct{ codec, muxer string }{
+	"opus":   {codec: "libopus", muxer: "opus"},
+	"vorbis": {codec: "libvorbis", muxer: "ogg"},
+	"mp3":    {codec: "libmp3lame", muxer: "mp3"},
+	"aac":    {codec: "aac", muxer: "ipod"},
+}
+
+// FFmpeg transcodes files with the ffmpeg command
+type FFmpeg struct {
+	path    string
+	profile Profile
+}
+
+// NewFFmpeg creates the ffmpeg encoder, ffmpeg must be in PATH
+func NewFFmpeg(profile Profile) (Encoder, error) {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg encoder is not available: %s", err)
+	}
+	return &FFmpeg{path: path, profile: profile}, nil
+}
+
+// Encode transcodes the file with ffmpeg
+func (e *FFmpeg) Encode(ctx context.Context, source, dest string, tags []audio.Tag) error {
+	return run(ctx, e.path, ffmpegArgs(e.profile, source, dest, tags))
+}
+
+// ffmpegArgs returns arguments of ffmpeg, pictures are dropped by mapping only audio streams
+// and the original metadata is replaced with the given tags
+func ffmpegArgs(profile Profile, source, dest string, tags []audio.Tag) []string {
+	format := ffmpegFormats[profile.Format.Name]
+	args := []string{
+		"-nostdin", "-hide_banner", "-loglevel", "error", "-y",
+		"-i", source,
+		"-map", "0:a", "-map_metadata", "-1",
+		"-c:a", format.codec, "-b:a", strconv.Itoa(profile.Bitrate) + "k",
+	}
+	for _, field := range targetTags(profile.Format, tags) {
+		args = append(args, "-metadata", field.Name+"="+field.Value)
+	}
+	// the muxer is set explicitly as dest may be a temporary file without the format extension
+	return append(args, "-f", format.muxer, dest)
+}
+
+// Opusenc transcodes files to Opus with the opusenc command of opus-tools
+type Opusenc struct {
+	path    string
+	profile Profile
+}
+
+// NewOpusenc creates the opusenc encoder, opusenc must be in PATH and the profile format must be Opus
+func NewOpusenc(profile Profile) (Encoder, error) {
+	if profile.Format.Name != "opus" {
+		return nil, fmt.Errorf("opusenc encoder supports only the opus format")
+	}
+	path, err := exec.LookPath("opusenc")
+	if err != nil {
+		return nil, fmt.Errorf("opusenc encoder is not available: %s", err)
+	}
+	return &Opusenc{path: path, profile: profile}, nil
+}
+
+// Encode transcodes the file with opusenc
+func (e *Opusenc) Encode(ctx context.Context, source, dest string, tags []audio.Tag) error {
+	return run(ctx, e.path, opusencArgs(e.profile, source, dest, tags))
+}
+
+// opusencArgs returns arguments of opusenc, which keeps tags of FLAC input unless they are discarded
+func opusencArgs(profile Profile, source, dest string, tags []audio.Tag) []string {
+	args := []string{
+		"--quiet", "--bitrate", strconv.Itoa(profile.Bitrate),
+		"--discard-comments", "--discard-pictures",
+	}
+	for _, tag := range tags {
+		args = append(args, "--comment", tag.Name+"="+tag.Value)
+	}
+	return append(args, source, dest)
+}
+
+// run runs the encoder command, its error output is included in the returned error
+func run(ctx context.Context, path string, args []string) error {
+	cmd := exec.CommandContext(ctx, path, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s failed: %s: %s", path, err, msg)
+		}
+		return fmt.Errorf("%s failed: %s", path, err)
+	}
+	return nil
+}
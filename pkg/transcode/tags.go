@@ -0,0 +1,80 @@
+package transcode
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+)
+
+// genericTagNames maps Vorbis comment names to ffmpeg metadata keys, which ffmpeg
+// writes as ID3v2 frames of MP3 files and atoms of MP4 files
+var genericTagNames = map[string]string{
+	"TITLE":        "title",
+	"ARTIST":       "artist",
+	"ALBUM":        "album",
+	"ALBUMARTIST":  "album_artist",
+	"ALBUM ARTIST": "album_artist",
+	"DATE":         "date",
+	"GENRE":        "genre",
+	"TRACKNUMBER":  "track",
+	"DISCNUMBER":   "disc",
+	"COMPOSER":     "composer",
+	"PERFORMER":    "performer",
+	"COMMENT":      "comment",
+	"DESCRIPTION":  "comment",
+	"COPYRIGHT":    "copyright",
+	"ORGANIZATION": "publisher",
+	"LABEL":        "publisher",
+	"LYRICS":       "lyrics",
+}
+
+// totalTagNames are Vorbis comments with the total number of tracks and discs,
+// they are merged into the track and disc fields as "n/total"
+var totalTagNames = map[string]string{
+	"TRACKTOTAL":  "track",
+	"TOTALTRACKS": "track",
+	"DISCTOTAL":   "disc",
+	"TOTALDISCS":  "disc",
+}
+
+// targetTags converts Vorbis comments to fields of the format, values of repeated
+// fields are joined as the encoders take a single value per field
+func targetTags(format Format, tags []audio.Tag) []audio.Tag {
+	var names []string
+	values := make(map[string][]string)
+	add := func(name, value string) {
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		if !slices.Contains(values[name], value) {
+			values[name] = append(values[name], value)
+		}
+	}
+
+	totals := make(map[string]string)
+	for _, tag := range tags {
+		upper := strings.ToUpper(tag.Name)
+		switch {
+		case format.VorbisComments:
+			add(upper, tag.Value)
+		case genericTagNames[upper] != "":
+			add(genericTagNames[upper], tag.Value)
+		case totalTagNames[upper] != "":
+			totals[totalTagNames[upper]] = tag.Value
+		case format.Name == "mp3":
+			// ffmpeg writes other fields as TXXX frames, MP4 has no place for them
+			add(upper, tag.Value)
+		}
+	}
+
+	fields := make([]audio.Tag, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(values[name], "; ")
+		if total, ok := totals[name]; ok && !strings.Contains(value, "/") {
+			value += "/" + total
+		}
+		fields = append(fields, audio.Tag{Name: name, Value: value})
+	}
+	return fields
+}
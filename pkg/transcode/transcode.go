@@ -0,0 +1,116 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+)
+
+// FLAC is the output format which keeps FLAC files, they are copied without transcoding
+const FLAC = "flac"
+
+// Format is a lossy output format
+type Format struct {
+	Name string
+	// Extension replaces the .flac extension of transcoded files
+	Extension string
+	// DefaultBitrate is the bitrate in kbit/s used when the profile doesn't set one
+	DefaultBitrate int
+	// VorbisComments is set for formats storing tags as Vorbis comments, other formats
+	// get tags mapped to their own fields
+	VorbisComments bool
+}
+
+// Formats are the supported lossy output formats
+var Formats = []Format{
+	{Name: "opus", Extension: ".opus", DefaultBitrate: 128, VorbisComments: true},
+	{Name: "vorbis", Extension: ".ogg", DefaultBitrate: 192, VorbisComments: true},
+	{Name: "mp3", Extension: ".mp3", DefaultBitrate: 256},
+	{Name: "aac", Extension: ".m4a", DefaultBitrate: 256},
+}
+
+// FormatNames returns names of all output formats including FLAC
+func FormatNames() []string {
+	names := []string{FLAC}
+	for _, f := range Formats {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Profile describes how FLAC files are transcoded
+type Profile struct {
+	Format Format
+	// Bitrate is the target bitrate in kbit/s
+	Bitrate int
+}
+
+// NewProfile creates the profile of the lossy format, zero bitrate means the format default
+func NewProfile(format string, bitrate int) (Profile, error) {
+	i := slices.IndexFunc(Formats, func(f Format) bool { return f.Name == strings.ToLower(format) })
+	if i < 0 {
+		return Profile{}, fmt.Errorf("unknown output format %q, available formats: %s", format, strings.Join(FormatNames(), ", "))
+	}
+	if bitrate < 0 {
+		return Profile{}, fmt.Errorf("bitrate must be positive")
+	}
+	if bitrate == 0 {
+		bitrate = Formats[i].DefaultBitrate
+	}
+	return Profile{Format: Formats[i], Bitrate: bitrate}, nil
+}
+
+// OutputName returns the name of the transcoded file
+func (p Profile) OutputName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + p.Format.Extension
+}
+
+// EstimateSize estimates the size of transcoded audio of the duration
+func (p Profile) EstimateSize(d time.Duration) int64 {
+	return int64(d.Seconds() * float64(p.Bitrate) * 1000 / 8)
+}
+
+// Encoder transcodes FLAC files according to its profile, implementations must be safe for concurrent use
+type Encoder interface {
+	// Encode transcodes the FLAC file source to dest without pictures, the file gets only the given tags,
+	// the encoder is stopped and an error is returned when ctx is cancelled
+	Encode(ctx context.Context, source, dest string, tags []audio.Tag) error
+}
+
+// NewEncoderFunc creates an encoder backend for the profile
+type NewEncoderFunc func(profile Profile) (Encoder, error)
+
+// backends are the registered encoder backends by name
+var backends = map[string]NewEncoderFunc{
+	"ffmpeg":  NewFFmpeg,
+	"opusenc": NewOpusenc,
+}
+
+// Register makes the encoder backend available by name, it must be called before encoders are created
+func Register(name string, newEncoder NewEncoderFunc) {
+	backends[name] = newEncoder
+}
+
+// Backends returns sorted names of the registered encoder backends
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// New creates the named encoder backend for the profile
+func New(backend string, profile Profile) (Encoder, error) {
+	newEncoder, ok := backends[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoder %q, available encoders: %s", backend, strings.Join(Backends(), ", "))
+	}
+	return newEncoder(profile)
+}
@@ -0,0 +1,139 @@
+package transcode
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerten/albumpicker/pkg/audio"
+)
+
+func TestNewProfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		bitrate     int
+		wantBitrate int
+		wantName    string
+		wantErr     bool
+	}{
+		{name: "default bitrate", format: "opus", wantBitrate: 128, wantName: "01 - track.opus"},
+		{name: "bitrate", format: "mp3", bitrate: 320, wantBitrate: 320, wantName: "01 - track.mp3"},
+		{name: "upper-case format", format: "AAC", wantBitrate: 256, wantName: "01 - track.m4a"},
+		{name: "vorbis", format: "vorbis", bitrate: 160, wantBitrate: 160, wantName: "01 - track.ogg"},
+		{name: "flac", format: "flac", wantErr: true},
+		{name: "unknown format", format: "wma", wantErr: true},
+		{name: "negative bitrate", format: "opus", bitrate: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := NewProfile(tt.format, tt.bitrate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if profile.Bitrate != tt.wantBitrate {
+				t.Errorf("NewProfile() bitrate = %d, want %d", profile.Bitrate, tt.wantBitrate)
+			}
+			if got := profile.OutputName("01 - track.flac"); got != tt.wantName {
+				t.Errorf("OutputName() = %s, want %s", got, tt.wantName)
+			}
+		})
+	}
+
+	profile, _ := NewProfile("opus", 128)
+	if got := profile.EstimateSize(time.Minute); got != 960_000 {
+		t.Errorf("EstimateSize() = %d, want 960000", got)
+	}
+}
+
+func TestTargetTags(t *testing.T) {
+	tags := []audio.Tag{
+		{Name: "Title", Value: "Song"},
+		{Name: "ARTIST", Value: "First"},
+		{Name: "ARTIST", Value: "Second"},
+		{Name: "TRACKNUMBER", Value: "3"},
+		{Name: "TRACKTOTAL", Value: "12"},
+		{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-7.5 dB"},
+	}
+
+	tests := []struct {
+		format string
+		want   []audio.Tag
+	}{
+		{
+			format: "opus",
+			want: []audio.Tag{
+				{Name: "TITLE", Value: "Song"},
+				{Name: "ARTIST", Value: "First; Second"},
+				{Name: "TRACKNUMBER", Value: "3"},
+				{Name: "TRACKTOTAL", Value: "12"},
+				{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-7.5 dB"},
+			},
+		},
+		{
+			format: "mp3",
+			want: []audio.Tag{
+				{Name: "title", Value: "Song"},
+				{Name: "artist", Value: "First; Second"},
+				{Name: "track", Value: "3/12"},
+				{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-7.5 dB"},
+			},
+		},
+		{
+			format: "aac",
+			want: []audio.Tag{
+				{Name: "title", Value: "Song"},
+				{Name: "artist", Value: "First; Second"},
+				{Name: "track", Value: "3/12"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			profile, err := NewProfile(tt.format, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := targetTags(profile.Format, tags); !slices.Equal(got, tt.want) {
+				t.Errorf("targetTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoderArgs(t *testing.T) {
+	tags := []audio.Tag{{Name: "ALBUM", Value: "Album"}}
+
+	mp3, _ := NewProfile("mp3", 320)
+	args := strings.Join(ffmpegArgs(mp3, "in.flac", "out.tmp", tags), " ")
+	for _, want := range []string{"-i in.flac", "-map 0:a", "-c:a libmp3lame -b:a 320k", "-metadata album=Album", "-f mp3 out.tmp"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("ffmpeg arguments %q don't contain %q", args, want)
+		}
+	}
+
+	opus, _ := NewProfile("opus", 96)
+	args = strings.Join(opusencArgs(opus, "in.flac", "out.opus", tags), " ")
+	for _, want := range []string{"--bitrate 96", "--discard-pictures", "--comment ALBUM=Album", "in.flac out.opus"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("opusenc arguments %q don't contain %q", args, want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	opus, _ := NewProfile("opus", 0)
+	if _, err := New("nonexistent", opus); err == nil {
+		t.Error("New() with unknown backend returned no error")
+	}
+	mp3, _ := NewProfile("mp3", 0)
+	if _, err := New("opusenc", mp3); err == nil {
+		t.Error("New() opusenc with mp3 profile returned no error")
+	}
+}